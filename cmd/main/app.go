@@ -1,22 +1,48 @@
 package main
 
 import (
+	"WeatherServiceAPI/internal/alert"
+	alertDB "WeatherServiceAPI/internal/alert/db"
 	weather3 "WeatherServiceAPI/internal/api"
 	"WeatherServiceAPI/internal/api/cityClient"
 	weatherApiClient2 "WeatherServiceAPI/internal/api/cityClient/db"
 	"WeatherServiceAPI/internal/api/weatherClient"
 	weather2 "WeatherServiceAPI/internal/api/weatherClient/db"
+	"WeatherServiceAPI/internal/apikey"
+	apiKeyDB "WeatherServiceAPI/internal/apikey/db"
 	"WeatherServiceAPI/internal/config"
+	"WeatherServiceAPI/internal/digest"
+	digestDB "WeatherServiceAPI/internal/digest/db"
+	"WeatherServiceAPI/internal/graph"
+	"WeatherServiceAPI/internal/health"
+	"WeatherServiceAPI/internal/notify"
+	notifyDB "WeatherServiceAPI/internal/notify/db"
+	"WeatherServiceAPI/internal/ratelimit"
+	rateLimitDB "WeatherServiceAPI/internal/ratelimit/db"
+	"WeatherServiceAPI/internal/rpc"
 	"WeatherServiceAPI/internal/user"
 	"WeatherServiceAPI/internal/user/db"
+	"WeatherServiceAPI/internal/webhook"
+	webhookDB "WeatherServiceAPI/internal/webhook/db"
+	"WeatherServiceAPI/internal/ws"
+	"WeatherServiceAPI/pkg/cache"
 	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/events"
+	"WeatherServiceAPI/pkg/i18n"
 	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/mail"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
 	"net"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -28,37 +54,269 @@ import (
 // @BasePath /api
 
 func main() {
+	cfg := config.GetConfig()
+	if err := logging.Init(cfg.Logging); err != nil {
+		logging.GetLogger().Fatalf("failed to configure logger. due to error: %v", err)
+	}
 	logger := logging.GetLogger()
 
 	logger.Info("create router")
 	router := httprouter.New()
 
-	cfg := config.GetConfig()
 	postgresSQLClient, err := postgresql.NewClient(context.TODO(), 3, cfg.Storage)
 	if err != nil {
 		logger.Fatalf("%v", err)
 	}
 
-	citiesService := AddCitiesData(postgresSQLClient, logger, cfg)
-	weatherService := AddWeatherData(postgresSQLClient, logger, cfg, citiesService)
+	logger.Info("apply database migrations")
+	migrationVersion, err := postgresql.Migrate(context.TODO(), postgresSQLClient, cfg.Storage.MigrationsDir)
+	if err != nil {
+		logger.Fatalf("failed to apply migrations. due to error: %v", err)
+	}
+	logger.Infof("database is at migration version %d", migrationVersion)
+
+	// readiness compares the database with the shipped migrations, not with
+	// what was applied on this start
+	latestMigrationVersion, err := postgresql.LatestMigrationVersion(cfg.Storage.MigrationsDir)
+	if err != nil {
+		logger.Fatalf("failed to read migrations. due to error: %v", err)
+	}
 
-	logger.Info("register user handler")
-	handler := weather3.NewHandler(logger, citiesService, weatherService)
-	handler.Register(router)
+	tracker := health.NewTracker()
+
+	citiesService, geocoder := AddCitiesData(postgresSQLClient, logger, cfg)
+	weatherService, refreshWeather := AddWeatherData(postgresSQLClient, logger, cfg, citiesService, tracker)
+
+	logger.Info("register health handler")
+	healthHandler := health.NewHandler(logger, postgresSQLClient, tracker, cfg.Health.RefreshStaleness, latestMigrationVersion)
+	healthHandler.Register(router)
+
+	responseCache := cache.New(tracker.NextRefresh, cfg.Cache.MaxEntries)
+	weatherService.Subscribe(func(ctx context.Context, cityID string, data weatherClient.WeatherData) {
+		responseCache.Invalidate()
+	})
+
+	broker := events.NewBroker(cfg.Stream.History, cfg.Stream.Buffer)
+	weatherService.Subscribe(func(ctx context.Context, cityID string, data weatherClient.WeatherData) {
+		broker.Publish(weatherClient.CityTopic(cityID), weatherClient.CityUpdateEvent, weatherClient.NewCityUpdate(cityID, data, time.Now()))
+	})
+
+	mailer := newMailer(logger, cfg)
+	if mailer == nil {
+		logger.Warn("emails are disabled, new emails are taken as verified and password reset is unavailable")
+	}
 
 	userStorage := db.NewStorage(postgresSQLClient, logger)
-	userService, err := user.NewService(userStorage, logger)
+	userService, err := user.NewService(userStorage, logger, mailer, user.Options{
+		MaxFailures:      cfg.Lockout.MaxFailures,
+		LockDuration:     cfg.Lockout.Duration,
+		MaxLockDuration:  cfg.Lockout.MaxDuration,
+		VerificationTTL:  cfg.Account.VerificationTTL,
+		PasswordResetTTL: cfg.Account.PasswordResetTTL,
+		BaseURL:          cfg.Email.BaseURL,
+	})
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	usersHandler := user.NewHandler(logger, userService)
+	logger.Info("register weather handler")
+	handler := weather3.NewHandler(logger, citiesService, weatherService, geocoder, userService, responseCache, broker, cfg.Stream.Heartbeat)
+	handler.Register(router)
+
+	logger.Info("create rate limiter")
+	var rateLimitStorage ratelimit.Storage
+	switch cfg.RateLimit.Store {
+	case "memory":
+		rateLimitStorage = ratelimit.NewMemoryStorage()
+	case "postgres":
+		rateLimitStorage = rateLimitDB.NewStorage(postgresSQLClient, logger)
+	default:
+		logger.Fatalf("unknown rate limit store %q. expected: memory or postgres", cfg.RateLimit.Store)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStorage, logger, ratelimit.Options{
+		Default:    ratelimit.Policy{Limit: cfg.RateLimit.Limit, Window: cfg.RateLimit.Window},
+		Auth:       ratelimit.Policy{Limit: cfg.RateLimit.AuthLimit, Window: cfg.RateLimit.AuthWindow},
+		Account:    ratelimit.Policy{Limit: cfg.RateLimit.AccountLimit, Window: cfg.RateLimit.AccountWindow},
+		TrustProxy: cfg.RateLimit.TrustProxy,
+	})
+	go limiter.Run(context.Background(), cfg.RateLimit.CleanupInterval)
+
+	usersHandler := user.NewHandler(logger, userService, limiter)
 	usersHandler.Register(router)
 
-	start(router, cfg)
+	logger.Info("register api key handler")
+	apiKeyService, err := apikey.NewService(apiKeyDB.NewStorage(postgresSQLClient, logger), logger, userService, limiter, apikey.Options{
+		DefaultRateLimit: cfg.APIKeys.RateLimit,
+		MaxRateLimit:     cfg.APIKeys.MaxRateLimit,
+		CacheTTL:         cfg.APIKeys.CacheTTL,
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+	go apiKeyService.Run(context.Background(), cfg.APIKeys.FlushInterval)
+	apiKeyHandler := apikey.NewHandler(logger, apiKeyService, limiter)
+	apiKeyHandler.Register(router)
+
+	logger.Info("register alert handler")
+	alertService, err := alert.NewService(alertDB.NewStorage(postgresSQLClient, logger), logger, userService, citiesService)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	weatherService.Subscribe(func(ctx context.Context, cityID string, data weatherClient.WeatherData) {
+		if err := alertService.Evaluate(ctx, cityID, data, time.Now()); err != nil {
+			logger.Errorf("failed to evaluate alert rules of city %s. error: %v", cityID, err)
+		}
+	})
+	alertService.Subscribe(func(ctx context.Context, a alert.Alert) {
+		broker.Publish(alert.Topic(a.UserUUID), alert.EventType, a)
+	})
+	alertHandler := alert.NewHandler(logger, alertService, limiter)
+	alertHandler.Register(router)
+
+	logger.Info("register webhook handler")
+	webhookStorage := webhookDB.NewStorage(postgresSQLClient, logger)
+	dispatcher := webhook.NewDispatcher(webhookStorage, logger, nil, webhook.Options{
+		MaxAttempts:          cfg.Webhooks.MaxAttempts,
+		Backoff:              cfg.Webhooks.Backoff,
+		MaxBackoff:           cfg.Webhooks.MaxBackoff,
+		Timeout:              cfg.Webhooks.Timeout,
+		PollInterval:         cfg.Webhooks.PollInterval,
+		BatchSize:            cfg.Webhooks.BatchSize,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+	})
+	go dispatcher.Run(context.Background())
+	webhookService, err := webhook.NewService(webhookStorage, logger, userService, dispatcher, cfg.Webhooks.AdminToken)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	alertService.Subscribe(func(ctx context.Context, a alert.Alert) {
+		if err := webhookService.PublishAlert(ctx, a); err != nil {
+			logger.Error(err)
+		}
+	})
+	weatherService.Subscribe(func(ctx context.Context, cityID string, data weatherClient.WeatherData) {
+		update := weatherClient.NewCityUpdate(cityID, data, time.Now())
+		if city, err := citiesService.Resolve(ctx, cityID); err == nil {
+			update.Name = city.Name
+		}
+		if err := webhookService.PublishForecast(ctx, update); err != nil {
+			logger.Error(err)
+		}
+	})
+	webhookHandler := webhook.NewHandler(logger, webhookService, limiter)
+	webhookHandler.Register(router)
+
+	logger.Info("register notifications handler")
+	notifyService, err := notify.NewService(notifyDB.NewStorage(postgresSQLClient, logger), logger, userService, mailer, notify.NewSigner(unsubscribeSecret(logger, cfg), cfg.Email.UnsubscribeTTL), cfg.Email.BaseURL)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	alertService.Subscribe(func(ctx context.Context, a alert.Alert) {
+		if err := notifyService.NotifyAlert(ctx, a); err != nil {
+			logger.Error(err)
+		}
+	})
+	notifyHandler := notify.NewHandler(logger, notifyService, limiter)
+	notifyHandler.Register(router)
+
+	logger.Info("register digest handler")
+	digestService, err := digest.NewService(digestDB.NewStorage(postgresSQLClient, logger), logger, userService, weatherService)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	digestService.Subscribe(func(ctx context.Context, d digest.Digest) {
+		if err := notifyService.NotifyDigest(ctx, d); err != nil {
+			logger.Error(err)
+		}
+	})
+	digestService.Subscribe(func(ctx context.Context, d digest.Digest) {
+		if err := webhookService.PublishDigest(ctx, d); err != nil {
+			logger.Error(err)
+		}
+	})
+	go digestService.Run(context.Background(), cfg.Digest.CheckInterval)
+	digestHandler := digest.NewHandler(logger, digestService, limiter)
+	digestHandler.Register(router)
+
+	logger.Info("register websocket handler")
+	hub := ws.NewHub(cfg.WebSocket.MaxConnections, cfg.WebSocket.PingInterval)
+	wsHandler := ws.NewHandler(logger, hub, broker, citiesService, userService, limiter)
+	wsHandler.Register(router)
+
+	logger.Info("register graphql handler")
+	graphHandler, err := graph.NewHandler(logger, citiesService, weatherService, userService, limiter, cfg.GraphQL.MaxDepth, cfg.GraphQL.MaxComplexity)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	graphHandler.Register(router)
+
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Enabled {
+		logger.Info("create grpc server")
+		grpcServer = rpc.NewServer(logger, citiesService, weatherService, userService, broker, limiter)
+	}
+
+	// the first refresh runs once every listener of weather changes is
+	// subscribed, so none of them misses it
+	refreshWeather()
+
+	start(apikey.Middleware(apiKeyService, limiter.Middleware(user.ClientMiddleware(limiter.ClientIP, router))), grpcServer, cfg)
 }
 
-func start(router *httprouter.Router, cfg *config.Config) {
+// newMailer returns the queue sending emails through the configured SMTP
+// server, or nil when emails are disabled.
+func newMailer(logger *logging.Logger, cfg *config.Config) mail.Sender {
+	if !cfg.Email.Enabled {
+		return nil
+	}
+
+	var sender mail.Sender
+	var err error
+	switch cfg.Email.Transport {
+	case "smtp":
+		sender, err = mail.NewSMTPSender(mail.SMTPConfig{
+			Host:     cfg.Email.Host,
+			Port:     cfg.Email.Port,
+			Username: cfg.Email.Username,
+			Password: cfg.Email.Password,
+			From:     cfg.Email.From,
+			Timeout:  cfg.Email.Timeout,
+		})
+		logger.Infof("emails are sent through %s:%s", cfg.Email.Host, cfg.Email.Port)
+	case "log":
+		sender = mail.NewLogSender(os.Stdout)
+		logger.Warn("emails are printed to stdout, use it only for local testing")
+	case "file":
+		sender, err = mail.NewFileSender(cfg.Email.Dir, cfg.Email.From)
+		logger.Warnf("emails are written to %s, use it only for local testing", cfg.Email.Dir)
+	default:
+		logger.Fatalf("unknown email transport %q. expected: smtp, log or file", cfg.Email.Transport)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	queue := mail.NewQueue(sender, logger, cfg.Email.QueueSize, cfg.Email.RateLimit, cfg.Email.RateWindow)
+	go queue.Run(context.Background())
+	return queue
+}
+
+func unsubscribeSecret(logger *logging.Logger, cfg *config.Config) string {
+	if cfg.Email.UnsubscribeSecret != "" {
+		return cfg.Email.UnsubscribeSecret
+	}
+
+	if cfg.Email.Enabled {
+		logger.Warn("email.unsubscribe_secret is not set, unsubscribe links will stop working after a restart")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		logger.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func start(router http.Handler, grpcServer *grpc.Server, cfg *config.Config) {
 	logger := logging.GetLogger()
 	logger.Info("start application")
 
@@ -73,8 +331,25 @@ func start(router *httprouter.Router, cfg *config.Config) {
 		logger.Fatal(listenErr)
 	}
 
+	var handler http.Handler = logging.Middleware(i18n.Middleware(router))
+	if grpcServer != nil {
+		if cfg.GRPC.Port == "" {
+			logger.Infof("grpc is served on port %s", cfg.Listen.Port)
+			handler = h2c.NewHandler(rpc.Mux(grpcServer, handler), &http2.Server{})
+		} else {
+			grpcListener, err := net.Listen("tcp", fmt.Sprintf("%s:%s", cfg.Listen.BindIp, cfg.GRPC.Port))
+			if err != nil {
+				logger.Fatal(err)
+			}
+			logger.Infof("grpc server is listening port %s:%s", cfg.Listen.BindIp, cfg.GRPC.Port)
+			go func() {
+				logger.Fatal(grpcServer.Serve(grpcListener))
+			}()
+		}
+	}
+
 	server := &http.Server{
-		Handler:      router,
+		Handler:      handler,
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
@@ -82,13 +357,13 @@ func start(router *httprouter.Router, cfg *config.Config) {
 	logger.Fatal(server.Serve(listener))
 }
 
-func AddCitiesData(postgreSQLClient *pgxpool.Pool, logger *logging.Logger, cfg *config.Config) cityClient.Service {
+func AddCitiesData(postgreSQLClient *pgxpool.Pool, logger *logging.Logger, cfg *config.Config) (cityClient.Service, cityClient.Geocoder) {
 	logger.Info("getting cities data from api source")
 
 	cClient := cityClient.NewClient(logger, *cfg)
 
 	citiesStorage := weatherApiClient2.NewStorage(postgreSQLClient, logger)
-	citiesService, err := cityClient.NewService(citiesStorage, logger)
+	citiesService, err := cityClient.NewService(citiesStorage, logger, cClient, cfg.Geocoding.ReversePrecision, cfg.Geocoding.ReverseTTL)
 	if err != nil {
 		panic(err)
 	}
@@ -99,37 +374,46 @@ func AddCitiesData(postgreSQLClient *pgxpool.Pool, logger *logging.Logger, cfg *
 		logger.Fatalf("failed to refresh cities data. due to error: %v", err)
 	}
 
-	return citiesService
+	return citiesService, cClient
 }
 
-func AddWeatherData(postgreSQLClient *pgxpool.Pool, logger *logging.Logger, cfg *config.Config, citiesService cityClient.Service) weatherClient.Service {
-	wClient := weatherClient.NewClient(logger, *cfg)
+// AddWeatherData creates the weather service. The returned function gets the
+// weather of all cities and keeps refreshing it every cfg.Refresh.Interval.
+func AddWeatherData(postgreSQLClient *pgxpool.Pool, logger *logging.Logger, cfg *config.Config, citiesService cityClient.Service, tracker *health.Tracker) (weatherClient.Service, func()) {
+	wClient := weatherClient.NewClient(logger, *cfg, tracker)
 	wStorage := weather2.NewStorage(postgreSQLClient, logger)
-	wService, err := weatherClient.NewService(wStorage, logger)
+	wService, err := weatherClient.NewService(wStorage, logger, wClient, cfg.Coordinates.Precision, cfg.Coordinates.TTL)
 	if err != nil {
 		panic(err)
 	}
-	logger.Info("getting weather data from api source")
-
-	refreshFunc := func() {
+	refreshFunc := func() error {
 		cities, err := citiesService.FindAll(context.TODO())
 		if err != nil {
-			logger.Fatalf("failed to get cities from database. due to error: %v", err)
+			return fmt.Errorf("failed to get cities from database. due to error: %w", err)
 		}
 
 		err = wClient.RefreshWeatherDataAsync(cities, wService)
 		if err != nil {
-			logger.Fatalf("failed to refresh weather data. due to error: %v", err)
+			return fmt.Errorf("failed to refresh weather data. due to error: %w", err)
 		}
+		return nil
 	}
-	refreshFunc()
 
-	go func() {
-		for {
-			time.Sleep(1 * time.Minute)
-			refreshFunc()
+	return wService, func() {
+		logger.Info("getting weather data from api source")
+		if err := refreshFunc(); err != nil {
+			logger.Error(err)
 		}
-	}()
+		tracker.ScheduleRefresh(time.Now().Add(cfg.Refresh.Interval))
 
-	return wService
+		go func() {
+			for {
+				time.Sleep(cfg.Refresh.Interval)
+				if err := refreshFunc(); err != nil {
+					logger.Error(err)
+				}
+				tracker.ScheduleRefresh(time.Now().Add(cfg.Refresh.Interval))
+			}
+		}()
+	}
 }
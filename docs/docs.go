@@ -16,9 +16,9 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/cities": {
-            "get": {
-                "description": "get cities",
+        "/auth/password-reset": {
+            "post": {
+                "description": "Email a single-use password reset token. The response is the same for unknown emails",
                 "consumes": [
                     "application/json"
                 ],
@@ -26,28 +26,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Weather"
+                    "Auth"
                 ],
-                "summary": "Available cities list",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                "summary": "Request password reset",
+                "parameters": [
+                    {
+                        "description": "User email",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/cityClient.CityData"
-                                }
-                            }
+                            "$ref": "#/definitions/user.EmailDTO"
                         }
                     }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    }
                 }
             }
         },
-        "/cities/{city}": {
-            "get": {
-                "description": "Get brief weather info for city",
+        "/auth/password-reset/confirm": {
+            "post": {
+                "description": "Set a new password with the emailed token. The token works once",
                 "consumes": [
                     "application/json"
                 ],
@@ -55,76 +57,56 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Weather"
+                    "Auth"
                 ],
-                "summary": "City brief weather info and dates with more details",
+                "summary": "Confirm password reset",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "weather info for city",
-                        "name": "city",
-                        "in": "path",
-                        "required": true
+                        "description": "Token and new password",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.PasswordResetDTO"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/weatherClient.BriefWeatherCity"
-                            }
-                        }
+                    "204": {
+                        "description": "No Content"
                     }
                 }
             }
         },
-        "/cities/{city}/{date}": {
+        "/auth/verify-email": {
             "get": {
-                "description": "Get city detailed weather by date",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Confirm the email with the token of the link sent to it. The token works once",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Weather"
+                    "Auth"
                 ],
-                "summary": "City detail weather info for date",
+                "summary": "Verify user email",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "weather info for city",
-                        "name": "city",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "date expected 2006-01-02 15:04:05 or 2006-01-02T15:04:05Z format",
-                        "name": "date",
-                        "in": "path",
+                        "description": "Verification token",
+                        "name": "token",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "string"
-                            }
-                        }
+                    "204": {
+                        "description": "No Content"
                     }
                 }
             }
         },
-        "/userfavs": {
-            "get": {
-                "description": "Get user favourite cities by email and password",
+        "/auth/verify-email/resend": {
+            "post": {
+                "description": "Send a new verification link to an unverified account. The response is the same for unknown emails",
                 "consumes": [
                     "application/json"
                 ],
@@ -132,44 +114,71 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users Favourite Cities"
+                    "Auth"
                 ],
-                "summary": "Get user favourite cities",
+                "summary": "Resend verification email",
                 "parameters": [
                     {
-                        "type": "string",
                         "description": "User email",
-                        "name": "email",
-                        "in": "query",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.EmailDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    }
+                }
+            }
+        },
+        "/calendar/{token}": {
+            "get": {
+                "description": "RFC 5545 calendar of the favourite cities of the user owning the secret token, see POST /users/{uuid}/calendar. The user's units are used unless units is given",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "Calendar"
+                ],
+                "summary": "Favourite cities forecast calendar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Calendar token followed by .ics",
+                        "name": "token",
+                        "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "User password",
-                        "name": "password",
-                        "in": "query",
-                        "required": true
+                        "description": "metric, imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/cityClient.CityData"
-                                }
-                            }
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/userfavs/{uid}": {
-            "post": {
-                "description": "Create new user favourite city by email and password",
+        "/cities": {
+            "get": {
+                "description": "get cities",
                 "consumes": [
                     "application/json"
                 ],
@@ -177,27 +186,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users Favourite Cities"
-                ],
-                "summary": "Create new user favourite city",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "User uuid",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "User with city",
-                        "name": "crUser",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.UserFavouriteCityDTO"
-                        }
-                    }
+                    "Weather"
                 ],
+                "summary": "Available cities list",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -214,46 +205,55 @@ const docTemplate = `{
                 }
             }
         },
-        "/userfavs/{uuid}": {
-            "delete": {
-                "consumes": [
-                    "application/json"
-                ],
+        "/cities.geojson": {
+            "get": {
+                "description": "FeatureCollection with a point per tracked city and the forecast slot closest to at (now by default)",
                 "produces": [
-                    "application/json"
+                    "application/geo+json"
                 ],
                 "tags": [
-                    "Users Favourite Cities"
+                    "Weather"
                 ],
-                "summary": "Delete city from user favourites",
+                "summary": "GeoJSON feed of all cities",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User uuid",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
+                        "description": "Time of the forecast, 2006-01-02T15:04:05Z. Defaults to now",
+                        "name": "at",
+                        "in": "query"
                     },
                     {
-                        "description": "User and city",
-                        "name": "crUser",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.UserFavouriteCityDTO"
-                        }
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pressure unit overriding the unit system: hPa, inHg or mmHg",
+                        "name": "pressure",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/geo.FeatureCollection"
+                        }
                     }
                 }
             }
         },
-        "/users": {
+        "/cities/search": {
             "get": {
-                "description": "Get user by email and password",
+                "description": "Autocomplete over tracked cities by name prefix or similarity, optionally completed with untracked cities from the geocoding api",
                 "consumes": [
                     "application/json"
                 ],
@@ -261,39 +261,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Weather"
                 ],
-                "summary": "Get user by email and password",
+                "summary": "Search cities",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User email",
-                        "name": "email",
+                        "description": "Part of a city name",
+                        "name": "q",
                         "in": "query",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "User password",
-                        "name": "password",
-                        "in": "query",
-                        "required": true
+                        "type": "integer",
+                        "description": "Maximum number of cities in each list (default 10, max 50, max 5 for untracked)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also search the OpenWeather geocoding api for untracked cities",
+                        "name": "external",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/user.User"
-                            }
+                            "$ref": "#/definitions/cityClient.SearchResult"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create new user by email and password",
+            }
+        },
+        "/cities/{city}": {
+            "get": {
+                "description": "Get brief weather info for city",
                 "consumes": [
                     "application/json"
                 ],
@@ -301,18 +305,40 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Weather"
                 ],
-                "summary": "Create new user",
+                "summary": "City brief weather info and dates with more details",
                 "parameters": [
                     {
-                        "description": "New user",
-                        "name": "crUser",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.CreateUserDTO"
-                        }
+                        "type": "string",
+                        "description": "City name (any case, native names allowed), name,country or city id",
+                        "name": "city",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pressure unit overriding the unit system: hPa, inHg or mmHg",
+                        "name": "pressure",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User uuid whose default units are used when units is not set",
+                        "name": "user",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -321,51 +347,129 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/cityClient.CityData"
-                                }
+                                "$ref": "#/definitions/weatherClient.BriefWeatherCity"
                             }
                         }
                     }
                 }
             }
         },
-        "/users/{uuid}": {
+        "/cities/{city}/forecast": {
             "get": {
-                "description": "Get user by uuid",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Stream the forecast of a city as CSV or NDJSON",
                 "produces": [
-                    "application/json"
+                    "text/csv",
+                    "application/x-ndjson"
                 ],
                 "tags": [
-                    "Users"
+                    "Export"
                 ],
-                "summary": "Get user by uuid",
+                "summary": "Export city forecast",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User uuid",
-                        "name": "uuid",
+                        "description": "City name (any case, native names allowed), name,country or city id",
+                        "name": "city",
                         "in": "path",
                         "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/user.User"
-                            }
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv or ndjson. Defaults to the Accept header, then ndjson",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns, all by default",
+                        "name": "columns",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the period, 2006-01-02 or 2006-01-02T15:04:05Z",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period (exclusive), 2006-01-02 or 2006-01-02T15:04:05Z",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pressure unit overriding the unit system: hPa, inHg or mmHg",
+                        "name": "pressure",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/cities/{city}/forecast.ics": {
+            "get": {
+                "description": "RFC 5545 calendar with an all-day event per forecast day. Event UIDs are stable, so subscribed calendars update events in place",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "Calendar"
+                ],
+                "summary": "City forecast calendar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "City name (any case, native names allowed), name,country or city id",
+                        "name": "city",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/cities/{city}/{date}": {
+            "get": {
+                "description": "Get city detailed weather by date",
                 "consumes": [
                     "application/json"
                 ],
@@ -373,25 +477,137 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Weather"
                 ],
-                "summary": "Delete user by uuid param",
+                "summary": "City detail weather info for date",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User uuid",
-                        "name": "uuid",
+                        "description": "City name (any case, native names allowed), name,country or city id",
+                        "name": "city",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "date expected 2006-01-02 15:04:05 or 2006-01-02T15:04:05Z format",
+                        "name": "date",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pressure unit overriding the unit system: hPa, inHg or mmHg",
+                        "name": "pressure",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User uuid whose default units are used when units is not set",
+                        "name": "user",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/weatherClient.Forecast"
+                        }
                     }
                 }
-            },
-            "patch": {
+            }
+        },
+        "/export": {
+            "get": {
+                "description": "Stream the forecasts of the given cities, or of all tracked cities, as CSV or NDJSON with the same columns for every city",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Export"
+                ],
+                "summary": "Export forecasts of several cities",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "City name, name,country or city id. Repeat for several cities, all tracked cities by default",
+                        "name": "city",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv or ndjson. Defaults to the Accept header, then ndjson",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns, all by default",
+                        "name": "columns",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the period, 2006-01-02 or 2006-01-02T15:04:05Z",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period (exclusive), 2006-01-02 or 2006-01-02T15:04:05Z",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pressure unit overriding the unit system: hPa, inHg or mmHg",
+                        "name": "pressure",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/geocode/reverse": {
+            "get": {
+                "description": "Names the places (city, state, country) around the coordinates using the OpenWeather geocoding api. Results are cached for nearby points.",
                 "consumes": [
                     "application/json"
                 ],
@@ -399,52 +615,2065 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Geocoding"
                 ],
-                "summary": "Partially user update",
+                "summary": "Reverse geocoding",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User uuid",
-                        "name": "uuid",
-                        "in": "path",
+                        "type": "number",
+                        "description": "Latitude",
+                        "name": "lat",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "description": "Updated user",
-                        "name": "crUser",
-                        "in": "body",
-                        "required": true,
+                        "type": "number",
+                        "description": "Longitude",
+                        "name": "lon",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/user.UpdateUserDTO"
+                            "$ref": "#/definitions/cityClient.ReverseGeocoding"
                         }
                     }
+                }
+            }
+        },
+        "/stream": {
+            "get": {
+                "description": "Server-Sent Events stream with a \"forecast\" event whenever the refresh stores new data for one of the cities. Send Last-Event-ID (or last_event_id) to replay missed events after a reconnect. Comment lines are sent as heartbeats",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Stream"
+                ],
+                "summary": "Stream of weather updates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated city names or ids, all tracked cities by default",
+                        "name": "cities",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "City name, name,country or id, may be repeated. Combined with cities",
+                        "name": "city",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Id of the last received event, same as the Last-Event-ID header",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/weatherClient.CityUpdate"
+                        }
+                    }
+                }
+            }
+        },
+        "/unsubscribe": {
+            "get": {
+                "description": "Page of the unsubscribe link of notification emails. Opening the link does not unsubscribe, so link scanners do not; the page submits the POST",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Unsubscribe confirmation page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed unsubscribe token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "post": {
+                "description": "Turns off the notifications of the signed token. Also serves one-click unsubscribe (RFC 8058) of the List-Unsubscribe header",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Unsubscribe from notification emails",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed unsubscribe token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/userfavs": {
+            "get": {
+                "description": "Get user favourite cities by email and password",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users Favourite Cities"
+                ],
+                "summary": "Get user favourite cities",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User email",
+                        "name": "email",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/cityClient.CityData"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/userfavs/{uid}": {
+            "post": {
+                "description": "Create new user favourite city by email and password",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users Favourite Cities"
+                ],
+                "summary": "Create new user favourite city",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User with city",
+                        "name": "crUser",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.UserFavouriteCityDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/cityClient.CityData"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/userfavs/{uuid}": {
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users Favourite Cities"
+                ],
+                "summary": "Delete city from user favourites",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User and city",
+                        "name": "crUser",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.UserFavouriteCityDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "description": "Get user by email and password",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get user by email and password",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User email",
+                        "name": "email",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/user.User"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create new user by email and password. A verification link is emailed to the user, until it is opened the account is limited",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Create new user",
+                "parameters": [
+                    {
+                        "description": "New user",
+                        "name": "crUser",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.CreateUserDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/cityClient.CityData"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}": {
+            "get": {
+                "description": "Get user by uuid",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get user by uuid",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/user.User"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Delete user by uuid param",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "patch": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Partially user update",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated user",
+                        "name": "crUser",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.UpdateUserDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/alerts": {
+            "get": {
+                "description": "Get alert rules of the user with the time they last fired",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Alerts"
+                ],
+                "summary": "Get user alert rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/alert.Rule"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create an alert rule of the user for a city. Metrics: temp, feels_like, wind_speed, wind_gust, pop, humidity, pressure, rain_3h, snow_3h; thresholds are in metric units, pop in percent. Operators: below, above. within_hours limits the checked forecast, 0 checks the whole forecast",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Alerts"
+                ],
+                "summary": "Create user alert rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Alert rule",
+                        "name": "rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/alert.CreateRuleDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/alert.Rule"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/alerts/{id}": {
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Alerts"
+                ],
+                "summary": "Delete user alert rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Alert rule id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User password",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/alert.DeleteRuleDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/auth-events": {
+            "get": {
+                "description": "Latest password checks of the account, newest first, with the client address and user agent. Failed checks lock the account for a growing time",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get user auth events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of events, 50 by default, at most 500",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/user.AuthEvent"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/calendar": {
+            "post": {
+                "description": "Create a secret URL of the iCalendar feed of the user's favourite cities. A new token replaces the previous one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Create calendar feed token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User password",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.CalendarTokenDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/user.CalendarToken"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/digest": {
+            "get": {
+                "description": "Forecast summary of the favourite cities of the user for the next hours, the same digest that is emailed daily. Times are in the timezone of the notification preferences, quantities in the units of the user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Get user forecast digest",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Period from 24 to 48 hours, digest_hours of the preferences by default",
+                        "name": "hours",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/digest.Digest"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/keys": {
+            "get": {
+                "description": "API keys of the user with their usage counters, including revoked ones. Secrets are never returned",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Get user API keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/apikey.Key"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create an API key for machine clients. Scopes: weather:read (read-only weather endpoints), users:admin (the user API of the key owner without the password, except for email and password changes and new keys). rate_limit is requests per minute. The key is only returned in this response",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Create user API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "API key",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apikey.CreateKeyDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/apikey.CreatedKey"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/keys/{id}": {
+            "delete": {
+                "description": "Revoked keys stop working at once on this instance and within api_keys.cache_ttl on others. Their usage is kept",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Revoke user API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User password",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/apikey.RevokeKeyDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/keys/{id}/usage": {
+            "get": {
+                "description": "Requests made with the key per UTC day, oldest first. Counters are stored every api_keys.flush_interval",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Get API key usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of days, 30 by default, at most 90",
+                        "name": "days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/apikey.Usage"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/notifications": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Get user notification preferences",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/notify.Preferences"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Turn alert and digest emails on or off. Omitted fields are not changed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Update user notification preferences",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Preferences",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/notify.UpdatePreferencesDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/notify.Preferences"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/webhooks": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get user webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.Webhook"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Register a URL receiving signed JSON payloads of the user's alerts (\"alert\") and refreshes of the user's favourite cities (\"forecast\"). The secret of the X-Webhook-Signature header is only returned here",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Create user webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Webhook",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhook.CreateWebhookDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.Webhook"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/webhooks/{id}": {
+            "delete": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Delete user webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User password",
+                        "name": "dto",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhook.DeleteWebhookDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/users/{uuid}/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Delivery log of a webhook, newest first: status (pending, delivered or dead), attempts, the last response status and error",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get user webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User uuid",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending, delivered or dead",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of deliveries, 50 by default, at most 200",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.Delivery"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/weather": {
+            "get": {
+                "description": "Forecast for a point that is not a tracked city. Points are rounded to a grid and cached for a while, nearby requests share the cached forecast.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Weather"
+                ],
+                "summary": "Weather for arbitrary coordinates",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "Latitude",
+                        "name": "lat",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude",
+                        "name": "lon",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/weatherClient.CoordinateWeather"
+                        }
+                    }
+                }
+            }
+        },
+        "/weather/nearest": {
+            "get": {
+                "description": "Finds the tracked city closest to the coordinates and returns the distance and its forecast. With radius lists all tracked cities within radius km instead.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Weather"
+                ],
+                "summary": "Weather of the nearest tracked city",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "Latitude",
+                        "name": "lat",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude",
+                        "name": "lon",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "List tracked cities within radius km",
+                        "name": "radius",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "metric (default), imperial or standard",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "with radius",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/cityClient.CityDistance"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "description": "Admin only, authorized with \"Authorization: Bearer \u003cwebhooks.admin_token\u003e\"",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get all webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.Webhook"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Admin only. Register a URL receiving signed JSON payloads of all alerts (\"alert\") and refreshes of all cities (\"forecast\")",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Create admin webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Webhook",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhook.CreateWebhookDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/webhook.Webhook"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "delete": {
+                "description": "Admin only, deletes webhooks of any user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Delete webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Admin only. Delivery log of any webhook, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer admin token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Webhook id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending, delivered or dead",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of deliveries, 50 by default, at most 200",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.Delivery"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "description": "Upgrade to a WebSocket. Authenticate with an auth message with email and password sent first, then send subscribe/unsubscribe messages with topics \"city:\u003ccity\u003e\", \"cities\" or \"alerts\" to receive forecast changes and alerts",
+                "tags": [
+                    "Stream"
+                ],
+                "summary": "WebSocket subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "metric, imperial or standard. Defaults to the units of the user",
+                        "name": "units",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response language: en or ru. Defaults to Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols"
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "alert.CreateRuleDTO": {
+            "type": "object",
+            "properties": {
+                "city": {
+                    "type": "string"
+                },
+                "metric": {
+                    "type": "string"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "threshold": {
+                    "type": "number"
+                },
+                "uuid": {
+                    "type": "string"
+                },
+                "within_hours": {
+                    "type": "integer"
+                }
+            }
+        },
+        "alert.DeleteRuleDTO": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "rule_id": {
+                    "type": "string"
+                },
+                "uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "alert.Rule": {
+            "type": "object",
+            "properties": {
+                "city_id": {
+                    "type": "string"
+                },
+                "city_name": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_fired_at": {
+                    "type": "string"
+                },
+                "metric": {
+                    "type": "string"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "threshold": {
+                    "type": "number"
+                },
+                "user_uuid": {
+                    "type": "string"
+                },
+                "within_hours": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.NearestCityWeather": {
+            "type": "object",
+            "properties": {
+                "country": {
+                    "type": "string"
+                },
+                "distance_km": {
+                    "type": "number"
+                },
+                "forecast": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/weatherClient.Forecast"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lat": {
+                    "type": "number"
+                },
+                "local_names": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "lon": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "units": {
+                    "$ref": "#/definitions/units.Labels"
+                }
+            }
+        },
+        "apikey.CreateKeyDTO": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "rate_limit": {
+                    "description": "RateLimit is the number of requests per minute, the configured\ndefault when zero.",
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "weather:read"
+                    ]
+                },
+                "uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "apikey.CreatedKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "wsk_3f9a1c2b7d4e_Qm9vZ..."
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "rate_limit": {
+                    "type": "integer"
+                },
+                "request_count": {
+                    "type": "integer"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "apikey.Key": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "rate_limit": {
+                    "type": "integer"
+                },
+                "request_count": {
+                    "type": "integer"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "apikey.RevokeKeyDTO": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "apikey.Usage": {
+            "type": "object",
+            "properties": {
+                "day": {
+                    "type": "string"
+                },
+                "requests": {
+                    "type": "integer"
+                }
+            }
+        },
+        "cityClient.CityData": {
+            "type": "object",
+            "properties": {
+                "country": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lat": {
+                    "type": "number"
+                },
+                "local_names": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "lon": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "cityClient.CityDistance": {
+            "type": "object",
+            "properties": {
+                "country": {
+                    "type": "string"
+                },
+                "distance_km": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lat": {
+                    "type": "number"
+                },
+                "local_names": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "lon": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "cityClient.ReverseGeocoding": {
+            "type": "object",
+            "properties": {
+                "cached": {
+                    "type": "boolean"
+                },
+                "fetched_at": {
+                    "type": "string"
+                },
+                "lat": {
+                    "type": "number"
+                },
+                "lon": {
+                    "type": "number"
+                },
+                "places": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cityClient.CityData"
+                    }
+                }
+            }
+        },
+        "cityClient.SearchResult": {
+            "type": "object",
+            "properties": {
+                "tracked": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cityClient.CityData"
+                    }
+                },
+                "untracked": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cityClient.CityData"
+                    }
+                }
+            }
+        },
+        "digest.City": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "boolean"
+                },
+                "condition": {
+                    "type": "string"
+                },
+                "condition_id": {
+                    "type": "integer"
+                },
+                "country": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "humidity": {
+                    "type": "integer"
+                },
+                "icon": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "pop": {
+                    "type": "number"
+                },
+                "rain": {
+                    "type": "number"
+                },
+                "snow": {
+                    "type": "number"
+                },
+                "temp_max": {
+                    "type": "number"
+                },
+                "temp_min": {
+                    "type": "number"
+                },
+                "wind_gust": {
+                    "type": "number"
+                },
+                "wind_speed": {
+                    "type": "number"
+                }
+            }
+        },
+        "digest.Digest": {
+            "type": "object",
+            "properties": {
+                "cities": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/digest.City"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "generated_at": {
+                    "type": "string"
+                },
+                "hours": {
+                    "type": "integer"
+                },
+                "timezone": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "units": {
+                    "$ref": "#/definitions/units.Labels"
+                },
+                "user_uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "geo.Feature": {
+            "type": "object",
+            "properties": {
+                "geometry": {
+                    "$ref": "#/definitions/geo.Point"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "properties": {},
+                "type": {
+                    "type": "string",
+                    "example": "Feature"
+                }
+            }
+        },
+        "geo.FeatureCollection": {
+            "type": "object",
+            "properties": {
+                "features": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/geo.Feature"
+                    }
+                },
+                "type": {
+                    "type": "string",
+                    "example": "FeatureCollection"
+                }
+            }
+        },
+        "geo.Point": {
+            "type": "object",
+            "properties": {
+                "coordinates": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
                     }
+                },
+                "type": {
+                    "type": "string",
+                    "example": "Point"
                 }
             }
-        }
-    },
-    "definitions": {
-        "cityClient.CityData": {
+        },
+        "notify.Preferences": {
             "type": "object",
             "properties": {
-                "country": {
+                "digest_hours": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "digest_time": {
+                    "description": "DigestTime is the local time, HH:MM in Timezone, the digest is sent\nat every day.",
+                    "type": "string",
+                    "example": "07:00"
+                },
+                "email_alerts": {
+                    "type": "boolean"
+                },
+                "email_digest": {
+                    "type": "boolean"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "notify.UpdatePreferencesDTO": {
+            "type": "object",
+            "properties": {
+                "digest_hours": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "digest_time": {
+                    "type": "string",
+                    "example": "07:00"
+                },
+                "email_alerts": {
+                    "type": "boolean"
+                },
+                "email_digest": {
+                    "type": "boolean"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                },
+                "uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "units.Labels": {
+            "type": "object",
+            "properties": {
+                "precipitation": {
+                    "type": "string"
+                },
+                "pressure": {
+                    "type": "string"
+                },
+                "system": {
+                    "type": "string"
+                },
+                "temperature": {
+                    "type": "string"
+                },
+                "visibility": {
+                    "type": "string"
+                },
+                "wind_speed": {
+                    "type": "string"
+                }
+            }
+        },
+        "user.AuthEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "created_at": {
                     "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
-                "lat": {
-                    "type": "number"
+                "ip": {
+                    "type": "string"
                 },
-                "lon": {
-                    "type": "number"
+                "reason": {
+                    "type": "string"
                 },
-                "name": {
+                "success": {
+                    "type": "boolean"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
+        "user.CalendarToken": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "user.CalendarTokenDTO": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "uuid": {
                     "type": "string"
                 }
             }
@@ -463,6 +2692,28 @@ const docTemplate = `{
                 }
             }
         },
+        "user.EmailDTO": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "user.PasswordResetDTO": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "repeat_password": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
         "user.UpdateUserDTO": {
             "type": "object",
             "properties": {
@@ -475,7 +2726,7 @@ const docTemplate = `{
                 "old_password": {
                     "type": "string"
                 },
-                "password": {
+                "units": {
                     "type": "string"
                 },
                 "uuid": {
@@ -489,6 +2740,13 @@ const docTemplate = `{
                 "email": {
                     "type": "string"
                 },
+                "email_verified_at": {
+                    "description": "EmailVerifiedAt is set when the user confirms the email, until then\nthe account is limited.",
+                    "type": "string"
+                },
+                "units": {
+                    "type": "string"
+                },
                 "uuid": {
                     "type": "string"
                 }
@@ -528,6 +2786,274 @@ const docTemplate = `{
                 },
                 "name": {
                     "type": "string"
+                },
+                "units": {
+                    "$ref": "#/definitions/units.Labels"
+                }
+            }
+        },
+        "weatherClient.CityUpdate": {
+            "type": "object",
+            "properties": {
+                "avg_temp": {
+                    "type": "number"
+                },
+                "city_id": {
+                    "type": "string"
+                },
+                "current": {
+                    "$ref": "#/definitions/weatherClient.Forecast"
+                },
+                "date_time_array": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "units": {
+                    "$ref": "#/definitions/units.Labels"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "weatherClient.CoordinateWeather": {
+            "type": "object",
+            "properties": {
+                "cached": {
+                    "type": "boolean"
+                },
+                "fetched_at": {
+                    "type": "string"
+                },
+                "forecast": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/weatherClient.Forecast"
+                    }
+                },
+                "lat": {
+                    "type": "number"
+                },
+                "lon": {
+                    "type": "number"
+                },
+                "units": {
+                    "$ref": "#/definitions/units.Labels"
+                }
+            }
+        },
+        "weatherClient.Forecast": {
+            "type": "object",
+            "properties": {
+                "clouds": {
+                    "type": "object",
+                    "properties": {
+                        "all": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "dt": {
+                    "type": "integer"
+                },
+                "dt_txt": {
+                    "type": "string"
+                },
+                "main": {
+                    "type": "object",
+                    "properties": {
+                        "feels_like": {
+                            "type": "number"
+                        },
+                        "grnd_level": {
+                            "type": "number"
+                        },
+                        "humidity": {
+                            "type": "integer"
+                        },
+                        "pressure": {
+                            "type": "number"
+                        },
+                        "sea_level": {
+                            "type": "number"
+                        },
+                        "temp": {
+                            "type": "number"
+                        },
+                        "temp_kf": {
+                            "type": "number"
+                        },
+                        "temp_max": {
+                            "type": "number"
+                        },
+                        "temp_min": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "pop": {
+                    "type": "number"
+                },
+                "rain": {
+                    "type": "object",
+                    "properties": {
+                        "3h": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "snow": {
+                    "type": "object",
+                    "properties": {
+                        "3h": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "sys": {
+                    "type": "object",
+                    "properties": {
+                        "pod": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "units": {
+                    "$ref": "#/definitions/units.Labels"
+                },
+                "visibility": {
+                    "type": "number"
+                },
+                "weather": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "description": {
+                                "type": "string"
+                            },
+                            "icon": {
+                                "type": "string"
+                            },
+                            "id": {
+                                "type": "integer"
+                            },
+                            "main": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "wind": {
+                    "type": "object",
+                    "properties": {
+                        "deg": {
+                            "type": "integer"
+                        },
+                        "gust": {
+                            "type": "number"
+                        },
+                        "speed": {
+                            "type": "number"
+                        }
+                    }
+                }
+            }
+        },
+        "webhook.CreateWebhookDTO": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "password": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.DeleteWebhookDTO": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "response_status": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "webhook.Webhook": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "user_uuid": {
+                    "type": "string"
                 }
             }
         }
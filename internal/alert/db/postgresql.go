@@ -0,0 +1,186 @@
+package db
+
+import (
+	"WeatherServiceAPI/internal/alert"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+var _ alert.Storage = &db{}
+
+type db struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func (d db) Create(ctx context.Context, rule alert.Rule) (string, error) {
+	q := `INSERT INTO alert_rules (user_id, city_id, metric, operator, threshold, within_hours, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err := d.client.QueryRow(ctx, q, rule.UserUUID, rule.CityID, rule.Metric, rule.Operator, rule.Threshold, rule.WithinHours, rule.CreatedAt).Scan(&rule.ID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return "", newErr
+		}
+		return "", err
+	}
+
+	return rule.ID, nil
+}
+
+func (d db) FindByUser(ctx context.Context, userUUID string) ([]alert.Rule, error) {
+	q := `SELECT r.id, r.user_id, r.city_id, c.name, r.metric, r.operator, r.threshold, r.within_hours, r.created_at,
+       (SELECT max(e.fired_at) FROM alert_events e WHERE e.rule_id = r.id)
+FROM alert_rules r JOIN cities c ON c.id = r.city_id
+WHERE r.user_id = $1 ORDER BY r.created_at;`
+
+	return d.findRules(ctx, q, userUUID)
+}
+
+func (d db) FindByCity(ctx context.Context, cityID string) ([]alert.Rule, error) {
+	q := `SELECT r.id, r.user_id, r.city_id, c.name, r.metric, r.operator, r.threshold, r.within_hours, r.created_at, NULL::timestamptz
+FROM alert_rules r JOIN cities c ON c.id = r.city_id
+WHERE r.city_id = $1;`
+
+	return d.findRules(ctx, q, cityID)
+}
+
+func (d db) findRules(ctx context.Context, q string, arg string) ([]alert.Rule, error) {
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, arg)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]alert.Rule, 0)
+
+	for rows.Next() {
+		var rule alert.Rule
+		if err = rows.Scan(&rule.ID, &rule.UserUUID, &rule.CityID, &rule.CityName, &rule.Metric, &rule.Operator, &rule.Threshold, &rule.WithinHours, &rule.CreatedAt, &rule.LastFiredAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (d db) Delete(ctx context.Context, userUUID, ruleID string) error {
+	q := `DELETE FROM alert_rules WHERE id = $1 AND user_id = $2;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	tag, err := d.client.Exec(ctx, q, ruleID, userUUID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (d db) FindLastEvent(ctx context.Context, ruleID string) (event alert.Event, err error) {
+	q := `SELECT id, rule_id, slot_time, until, value, fired_at FROM alert_events WHERE rule_id = $1 ORDER BY until DESC LIMIT 1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err = d.client.QueryRow(ctx, q, ruleID).Scan(&event.ID, &event.RuleID, &event.SlotTime, &event.Until, &event.Value, &event.FiredAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return event, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return event, newErr
+		}
+		return event, err
+	}
+
+	return event, nil
+}
+
+func (d db) CreateEvent(ctx context.Context, event alert.Event) (alert.Event, bool, error) {
+	q := `INSERT INTO alert_events (rule_id, slot_time, until, value, fired_at) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (rule_id, slot_time) DO NOTHING RETURNING id;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err := d.client.QueryRow(ctx, q, event.RuleID, event.SlotTime, event.Until, event.Value, event.FiredAt).Scan(&event.ID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// the rule already fired for this slot
+			return event, false, nil
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return event, false, newErr
+		}
+		return event, false, err
+	}
+
+	return event, true, nil
+}
+
+func (d db) ExtendEvent(ctx context.Context, eventID string, until time.Time) error {
+	q := `UPDATE alert_events SET until = $2 WHERE id = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	_, err := d.client.Exec(ctx, q, eventID, until)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func NewStorage(client postgresql.Client, logger *logging.Logger) alert.Storage {
+	return &db{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,154 @@
+package alert
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+const (
+	alertsURL = "/api/users/:uuid/alerts"
+	alertURL  = "/api/users/:uuid/alerts/:id"
+)
+
+type handler struct {
+	Logger       *logging.Logger
+	AlertService Service
+	Limiter      *ratelimit.Limiter
+}
+
+func NewHandler(logger *logging.Logger, alertService Service, limiter *ratelimit.Limiter) handlers.Handler {
+	return &handler{
+		Logger:       logger,
+		AlertService: alertService,
+		Limiter:      limiter,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, alertsURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.GetRules)))
+	router.HandlerFunc(http.MethodPost, alertsURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.CreateRule)))
+	router.HandlerFunc(http.MethodDelete, alertURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.DeleteRule)))
+}
+
+// GetRules godoc
+// @Summary      Get user alert rules
+// @Description  Get alert rules of the user with the time they last fired
+// @Tags         Alerts
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true  "User uuid"
+// @Param        password    query    string  true  "User password"
+// @Success      200  {array}  Rule
+// @Router       /users/{uuid}/alerts [get]
+func (h *handler) GetRules(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER ALERT RULES")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	userUUID := params.ByName("uuid")
+
+	logger.Debug("get password from URL")
+	password := r.URL.Query().Get("password")
+	if password == "" && user.APIKeyOwner(r.Context()) == "" {
+		return apperror.NewAppError(nil, "invalid query parameter password", "password is required", "WeatherService-000004")
+	}
+
+	rules, err := h.AlertService.FindByUser(r.Context(), userUUID, password)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal alert rules")
+	rulesBytes, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshall alert rules. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(rulesBytes)
+	return nil
+}
+
+// CreateRule godoc
+// @Summary      Create user alert rule
+// @Description  Create an alert rule of the user for a city. Metrics: temp, feels_like, wind_speed, wind_gust, pop, humidity, pressure, rain_3h, snow_3h; thresholds are in metric units, pop in percent. Operators: below, above. within_hours limits the checked forecast, 0 checks the whole forecast
+// @Tags         Alerts
+// @Accept       json
+// @Produce      json
+// @Param        uuid    path     string  true  "User uuid"
+// @Param        rule    body     CreateRuleDTO  true  "Alert rule"
+// @Success      201  {object}  Rule
+// @Router       /users/{uuid}/alerts [post]
+func (h *handler) CreateRule(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("CREATE USER ALERT RULE")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("decode create alert rule dto")
+	var dto CreateRuleDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme. check swagger API")
+	}
+	dto.UUID = params.ByName("uuid")
+
+	rule, err := h.AlertService.Create(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal alert rule")
+	ruleBytes, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to marshall alert rule. error: %w", err)
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%s/alerts/%s", rule.UserUUID, rule.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(ruleBytes)
+	return nil
+}
+
+// DeleteRule godoc
+// @Summary      Delete user alert rule
+// @Tags         Alerts
+// @Accept       json
+// @Produce      json
+// @Param        uuid    path     string  true  "User uuid"
+// @Param        id      path     string  true  "Alert rule id"
+// @Param        dto     body     DeleteRuleDTO  true  "User password"
+// @Success      204
+// @Router       /users/{uuid}/alerts/{id} [delete]
+func (h *handler) DeleteRule(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("DELETE USER ALERT RULE")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("decode delete alert rule dto")
+	var dto DeleteRuleDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme")
+	}
+	dto.UUID = params.ByName("uuid")
+	dto.RuleID = params.ByName("id")
+
+	if err := h.AlertService.Delete(r.Context(), dto); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package alert
+
+import (
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"fmt"
+	"time"
+)
+
+// Metric is the forecast quantity a rule watches. Thresholds are in metric
+// units: °C, m/s, hPa, mm and percent.
+type Metric string
+
+const (
+	Temp      Metric = "temp"
+	FeelsLike Metric = "feels_like"
+	WindSpeed Metric = "wind_speed"
+	WindGust  Metric = "wind_gust"
+	Pop       Metric = "pop"
+	Humidity  Metric = "humidity"
+	Pressure  Metric = "pressure"
+	Rain      Metric = "rain_3h"
+	Snow      Metric = "snow_3h"
+)
+
+var metrics = map[Metric]func(f weatherClient.Forecast) float64{
+	Temp:      func(f weatherClient.Forecast) float64 { return f.Main.Temp },
+	FeelsLike: func(f weatherClient.Forecast) float64 { return f.Main.FeelsLike },
+	WindSpeed: func(f weatherClient.Forecast) float64 { return f.Wind.Speed },
+	WindGust:  func(f weatherClient.Forecast) float64 { return f.Wind.Gust },
+	Pop:       func(f weatherClient.Forecast) float64 { return f.Pop * 100 },
+	Humidity:  func(f weatherClient.Forecast) float64 { return float64(f.Main.Humidity) },
+	Pressure:  func(f weatherClient.Forecast) float64 { return f.Main.Pressure },
+	Rain:      func(f weatherClient.Forecast) float64 { return f.Rain.ThreeH },
+	Snow:      func(f weatherClient.Forecast) float64 { return f.Snow.ThreeH },
+}
+
+// Unit returns the unit of the metric.
+func (m Metric) Unit() string {
+	switch m {
+	case Temp, FeelsLike:
+		return "°C"
+	case WindSpeed, WindGust:
+		return " m/s"
+	case Pop, Humidity:
+		return "%"
+	case Pressure:
+		return " hPa"
+	case Rain, Snow:
+		return " mm"
+	}
+	return ""
+}
+
+type Operator string
+
+const (
+	Below Operator = "below"
+	Above Operator = "above"
+)
+
+const (
+	// EventType is the type of the events published when a rule fires.
+	EventType = "alert"
+
+	topicPrefix = "alert:"
+
+	// slotDuration is the length of a forecast slot.
+	slotDuration = 3 * time.Hour
+)
+
+// Topic is the events topic of the alerts of a user.
+func Topic(userUUID string) string {
+	return topicPrefix + userUUID
+}
+
+// Rule fires when the metric of a forecast slot of the city is below or above
+// the threshold. With WithinHours only slots starting in the next WithinHours
+// hours are checked, otherwise the whole forecast.
+type Rule struct {
+	ID          string     `json:"id"`
+	UserUUID    string     `json:"user_uuid"`
+	CityID      string     `json:"city_id"`
+	CityName    string     `json:"city_name,omitempty"`
+	Metric      Metric     `json:"metric"`
+	Operator    Operator   `json:"operator"`
+	Threshold   float64    `json:"threshold"`
+	WithinHours int        `json:"within_hours"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastFiredAt *time.Time `json:"last_fired_at,omitempty"`
+}
+
+func (r Rule) String() string {
+	s := fmt.Sprintf("%s %s %g", r.Metric, r.Operator, r.Threshold)
+	if r.WithinHours > 0 {
+		s += fmt.Sprintf(" in next %dh", r.WithinHours)
+	}
+	return s
+}
+
+// matches reports whether slot meets the condition of the rule and the value
+// of the metric.
+func (r Rule) matches(slot weatherClient.Forecast) (bool, float64) {
+	value := metrics[r.Metric](slot)
+	if r.Operator == Below {
+		return value < r.Threshold, value
+	}
+	return value > r.Threshold, value
+}
+
+// Event is a firing of a rule: the first matching slot and the end of the run
+// of consecutive matching slots starting with it. While Until has not passed,
+// new data matching the same run extends the event instead of firing again.
+type Event struct {
+	ID       string    `json:"id"`
+	RuleID   string    `json:"rule_id"`
+	SlotTime time.Time `json:"slot_time"`
+	Until    time.Time `json:"until"`
+	Value    float64   `json:"value"`
+	FiredAt  time.Time `json:"fired_at"`
+}
+
+// Alert is the payload of EventType events.
+type Alert struct {
+	RuleID    string    `json:"rule_id"`
+	UserUUID  string    `json:"user_uuid"`
+	Rule      string    `json:"rule"`
+	CityID    string    `json:"city_id"`
+	CityName  string    `json:"city_name,omitempty"`
+	Metric    Metric    `json:"metric"`
+	Operator  Operator  `json:"operator"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	SlotTime  time.Time `json:"slot_time"`
+	Until     time.Time `json:"until"`
+	FiredAt   time.Time `json:"fired_at"`
+}
+
+type CreateRuleDTO struct {
+	UUID        string   `json:"uuid,omitempty"`
+	Password    string   `json:"password"`
+	City        string   `json:"city"`
+	Metric      Metric   `json:"metric"`
+	Operator    Operator `json:"operator"`
+	Threshold   *float64 `json:"threshold"`
+	WithinHours int      `json:"within_hours"`
+}
+
+type DeleteRuleDTO struct {
+	UUID     string `json:"uuid,omitempty"`
+	RuleID   string `json:"rule_id,omitempty"`
+	Password string `json:"password"`
+}
@@ -0,0 +1,259 @@
+package alert
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRulesPerUser limits the rules of a user.
+	maxRulesPerUser = 50
+
+	// maxWithinHours is the length of the stored forecast.
+	maxWithinHours = 120
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Listener is notified of every alert fired by Evaluate.
+type Listener func(ctx context.Context, a Alert)
+
+type service struct {
+	storage     Storage
+	logger      *logging.Logger
+	userService user.Service
+	cityService cityClient.Service
+
+	mu        sync.RWMutex
+	listeners []Listener
+}
+
+func NewService(storage Storage, logger *logging.Logger, userService user.Service, cityService cityClient.Service) (Service, error) {
+	return &service{
+		storage:     storage,
+		logger:      logger,
+		userService: userService,
+		cityService: cityService,
+	}, nil
+}
+
+type Service interface {
+	Create(ctx context.Context, dto CreateRuleDTO) (Rule, error)
+	FindByUser(ctx context.Context, userUUID, password string) ([]Rule, error)
+	Delete(ctx context.Context, dto DeleteRuleDTO) error
+	Evaluate(ctx context.Context, cityID string, data weatherClient.WeatherData, now time.Time) error
+	Subscribe(l Listener)
+}
+
+// authenticate checks the password of the user through the user service, so
+// failed checks count towards the lockout of the account.
+func (s *service) authenticate(ctx context.Context, userUUID, password string) (user.User, error) {
+	return s.userService.Authenticate(ctx, userUUID, password, user.AuthActionAlerts)
+}
+
+func (s *service) Create(ctx context.Context, dto CreateRuleDTO) (Rule, error) {
+	logger := logging.FromContext(ctx)
+
+	if _, ok := metrics[dto.Metric]; !ok {
+		return Rule{}, apperror.NewAppError(nil, "invalid alert metric", fmt.Sprintf("unknown metric %q. expected: temp, feels_like, wind_speed, wind_gust, pop, humidity, pressure, rain_3h or snow_3h", dto.Metric), "WeatherService-000004")
+	}
+	if dto.Operator != Below && dto.Operator != Above {
+		return Rule{}, apperror.NewAppError(nil, "invalid alert operator", fmt.Sprintf("unknown operator %q. expected: below or above", dto.Operator), "WeatherService-000004")
+	}
+	if dto.Threshold == nil {
+		return Rule{}, apperror.NewAppError(nil, "alert threshold is required", "", "WeatherService-000004")
+	}
+	if dto.WithinHours < 0 || dto.WithinHours > maxWithinHours {
+		return Rule{}, apperror.NewAppError(nil, "invalid alert period", fmt.Sprintf("within_hours must be from 0 to %d", maxWithinHours), "WeatherService-000004")
+	}
+
+	u, err := s.authenticate(ctx, dto.UUID, dto.Password)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	city, err := s.cityService.Resolve(ctx, dto.City)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	rules, err := s.storage.FindByUser(ctx, u.UUID)
+	if err != nil {
+		return Rule{}, fmt.Errorf("failed to find alert rules. error: %w", err)
+	}
+	if len(rules) >= maxRulesPerUser {
+		return Rule{}, apperror.NewAppError(nil, "too many alert rules", fmt.Sprintf("a user can have at most %d alert rules", maxRulesPerUser), "WeatherService-000004")
+	}
+
+	rule := Rule{
+		UserUUID:    u.UUID,
+		CityID:      city.Id,
+		CityName:    city.Name,
+		Metric:      dto.Metric,
+		Operator:    dto.Operator,
+		Threshold:   *dto.Threshold,
+		WithinHours: dto.WithinHours,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	logger.Debug("create alert rule")
+	rule.ID, err = s.storage.Create(ctx, rule)
+	if err != nil {
+		return Rule{}, fmt.Errorf("failed to create alert rule. error: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (s *service) FindByUser(ctx context.Context, userUUID, password string) ([]Rule, error) {
+	u, err := s.authenticate(ctx, userUUID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.storage.FindByUser(ctx, u.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find alert rules. error: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *service) Delete(ctx context.Context, dto DeleteRuleDTO) error {
+	if !uuidRegexp.MatchString(dto.RuleID) {
+		return apperror.ErrNotFound
+	}
+
+	u, err := s.authenticate(ctx, dto.UUID, dto.Password)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.Delete(ctx, u.UUID, dto.RuleID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete alert rule. error: %w", err)
+	}
+	return nil
+}
+
+// Evaluate checks the rules of the city against freshly stored data. A rule
+// fires for the first run of consecutive matching slots; later refreshes that
+// still see the same run, possibly longer, only extend its event, so a
+// lasting condition is reported once.
+func (s *service) Evaluate(ctx context.Context, cityID string, data weatherClient.WeatherData, now time.Time) error {
+	logger := logging.FromContext(ctx)
+
+	rules, err := s.storage.FindByCity(ctx, cityID)
+	if err != nil {
+		return fmt.Errorf("failed to find alert rules of city. error: %w", err)
+	}
+
+	for _, rule := range rules {
+		event, ok := firstRun(rule, data.List, now)
+		if !ok {
+			continue
+		}
+
+		last, err := s.storage.FindLastEvent(ctx, rule.ID)
+		if err != nil && !errors.Is(err, apperror.ErrNotFound) {
+			return fmt.Errorf("failed to find last alert event. error: %w", err)
+		}
+		if err == nil && last.Until.After(now) && !event.SlotTime.After(last.Until) {
+			if event.Until.After(last.Until) {
+				if err = s.storage.ExtendEvent(ctx, last.ID, event.Until); err != nil {
+					return fmt.Errorf("failed to extend alert event. error: %w", err)
+				}
+			}
+			continue
+		}
+
+		event, created, err := s.storage.CreateEvent(ctx, event)
+		if err != nil {
+			return fmt.Errorf("failed to create alert event. error: %w", err)
+		}
+		if !created {
+			continue
+		}
+
+		logger.Infof("alert rule %s fired: %s", rule.ID, rule)
+		s.notify(ctx, Alert{
+			RuleID:    rule.ID,
+			UserUUID:  rule.UserUUID,
+			Rule:      rule.String(),
+			CityID:    rule.CityID,
+			CityName:  rule.CityName,
+			Metric:    rule.Metric,
+			Operator:  rule.Operator,
+			Threshold: rule.Threshold,
+			Value:     event.Value,
+			SlotTime:  event.SlotTime,
+			Until:     event.Until,
+			FiredAt:   event.FiredAt,
+		})
+	}
+
+	return nil
+}
+
+// firstRun finds the first slot matching the rule within its period and the
+// end of the run of consecutive matching slots starting with it.
+func firstRun(rule Rule, forecast []weatherClient.Forecast, now time.Time) (Event, bool) {
+	event := Event{RuleID: rule.ID, FiredAt: now.UTC()}
+	found := false
+
+	for _, slot := range forecast {
+		start := slot.Time()
+		matches, value := rule.matches(slot)
+
+		if found {
+			if !matches || !start.Equal(event.Until) {
+				break
+			}
+			event.Until = start.Add(slotDuration)
+			continue
+		}
+
+		if !start.Add(slotDuration).After(now) {
+			continue
+		}
+		if rule.WithinHours > 0 && !start.Before(now.Add(time.Duration(rule.WithinHours)*time.Hour)) {
+			break
+		}
+		if matches {
+			found = true
+			event.SlotTime = start
+			event.Until = start.Add(slotDuration)
+			event.Value = value
+		}
+	}
+
+	return event, found
+}
+
+func (s *service) notify(ctx context.Context, a Alert) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+
+	for _, l := range listeners {
+		l(ctx, a)
+	}
+}
+
+func (s *service) Subscribe(l Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, l)
+}
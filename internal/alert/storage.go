@@ -0,0 +1,21 @@
+package alert
+
+import (
+	"context"
+	"time"
+)
+
+type Storage interface {
+	Create(ctx context.Context, rule Rule) (string, error)
+	FindByUser(ctx context.Context, userUUID string) ([]Rule, error)
+	FindByCity(ctx context.Context, cityID string) ([]Rule, error)
+	Delete(ctx context.Context, userUUID, ruleID string) error
+
+	// FindLastEvent returns the event of the rule with the latest Until, or
+	// apperror.ErrNotFound.
+	FindLastEvent(ctx context.Context, ruleID string) (Event, error)
+	// CreateEvent stores the event unless the rule already fired for the
+	// slot, reporting whether it was stored.
+	CreateEvent(ctx context.Context, event Event) (Event, bool, error)
+	ExtendEvent(ctx context.Context, eventID string, until time.Time) error
+}
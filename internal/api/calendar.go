@@ -0,0 +1,182 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/ical"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/units"
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	calendarContentType = "text/calendar"
+	calendarProdID      = "-//WeatherServiceAPI//Weather forecast//EN"
+	calendarSuffix      = ".ics"
+
+	// calendarDays is the number of days covered by a feed, the length of the
+	// OpenWeather forecast plus the current day.
+	calendarDays = 6
+)
+
+// GetCityCalendar godoc
+// @Summary      City forecast calendar
+// @Description  RFC 5545 calendar with an all-day event per forecast day. Event UIDs are stable, so subscribed calendars update events in place
+// @Tags         Calendar
+// @Produce      text/calendar
+// @Param        city      path     string  true   "City name (any case, native names allowed), name,country or city id"
+// @Param        units     query    string  false  "metric (default), imperial or standard"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {string}  string
+// @Router       /cities/{city}/forecast.ics [get]
+func (h *handler) GetCityCalendar(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET CITY CALENDAR")
+
+	params := httprouter.ParamsFromContext(r.Context())
+	city, err := h.cityService.Resolve(r.Context(), params.ByName("city"))
+	if err != nil {
+		return err
+	}
+
+	u, err := h.requestUnits(r)
+	if err != nil {
+		return err
+	}
+	lang := i18n.FromContext(r.Context())
+
+	events, err := h.calendarEvents(r.Context(), city, u, lang, false)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf(i18n.Text(lang, "calendar.name"), city.Name)
+	return writeCalendar(w, ical.Calendar{ProdID: calendarProdID, Name: name, Events: events})
+}
+
+// GetUserCalendar godoc
+// @Summary      Favourite cities forecast calendar
+// @Description  RFC 5545 calendar of the favourite cities of the user owning the secret token, see POST /users/{uuid}/calendar. The user's units are used unless units is given
+// @Tags         Calendar
+// @Produce      text/calendar
+// @Param        token     path     string  true   "Calendar token followed by .ics"
+// @Param        units     query    string  false  "metric, imperial or standard"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {string}  string
+// @Router       /calendar/{token} [get]
+func (h *handler) GetUserCalendar(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER CALENDAR")
+
+	params := httprouter.ParamsFromContext(r.Context())
+	token := params.ByName("token")
+	if !strings.HasSuffix(token, calendarSuffix) {
+		return apperror.ErrNotFound
+	}
+
+	owner, cities, err := h.userService.GetCalendarFeed(r.Context(), strings.TrimSuffix(token, calendarSuffix))
+	if err != nil {
+		return err
+	}
+
+	system := r.URL.Query().Get("units")
+	if system == "" {
+		system = owner.Units
+	}
+	u, err := units.Parse(system, r.URL.Query().Get("pressure"))
+	if err != nil {
+		return apperror.NewAppError(err, err.Error(), "", "WeatherService-000004")
+	}
+	lang := i18n.FromContext(r.Context())
+
+	events := make([]ical.Event, 0)
+	for _, city := range cities {
+		cityEvents, err := h.calendarEvents(r.Context(), city, u, lang, true)
+		if err != nil {
+			return err
+		}
+		events = append(events, cityEvents...)
+	}
+
+	return writeCalendar(w, ical.Calendar{ProdID: calendarProdID, Name: i18n.Text(lang, "calendar.favourites"), Events: events})
+}
+
+// calendarEvents builds an all-day event per forecast day of the city. The
+// summary is prefixed with the city name when withCity is set.
+func (h *handler) calendarEvents(ctx context.Context, city cityClient.CityData, u units.Units, lang string, withCity bool) ([]ical.Event, error) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	forecast, err := h.weatherService.FindForecast(ctx, city.Id, from, from.AddDate(0, 0, calendarDays))
+	if err != nil {
+		return nil, err
+	}
+	forecast = weatherClient.LocalizeForecast(weatherClient.ConvertForecastUnits(forecast, u), lang)
+
+	labels := u.Labels()
+	days := weatherClient.Daily(forecast)
+	events := make([]ical.Event, 0, len(days))
+	for _, day := range days {
+		summary := fmt.Sprintf("%s %.0f–%.0f%s, %.0f%% %s",
+			conditionSymbol(day.ConditionID), math.Round(day.TempMin), math.Round(day.TempMax), labels.Temperature,
+			day.Pop*100, i18n.Text(lang, "calendar.rain"))
+		if withCity {
+			summary = fmt.Sprintf("%s: %s", city.Name, summary)
+		}
+
+		description := fmt.Sprintf("%s\n%s: %d%%\n%s: %.1f %s",
+			day.Description,
+			i18n.Text(lang, "calendar.humidity"), day.Humidity,
+			i18n.Text(lang, "calendar.wind"), day.WindSpeed, labels.WindSpeed)
+
+		events = append(events, ical.Event{
+			UID:         fmt.Sprintf("%s-%s@weatherserviceapi", city.Id, day.Date.Format("20060102")),
+			Date:        day.Date,
+			Summary:     summary,
+			Description: description,
+		})
+	}
+
+	return events, nil
+}
+
+func writeCalendar(w http.ResponseWriter, calendar ical.Calendar) error {
+	w.Header().Set("Content-Type", calendarContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="forecast.ics"`)
+	w.WriteHeader(http.StatusOK)
+
+	if err := calendar.Encode(w); err != nil {
+		logging.GetLogger().Errorf("failed to write calendar. error: %v", err)
+	}
+	return nil
+}
+
+// conditionSymbol maps an OpenWeather condition id to a weather symbol.
+func conditionSymbol(id int) string {
+	switch {
+	case id >= 200 && id < 300:
+		return "⛈"
+	case id >= 300 && id < 400:
+		return "🌦"
+	case id >= 500 && id < 600:
+		return "🌧"
+	case id >= 600 && id < 700:
+		return "❄"
+	case id >= 700 && id < 800:
+		return "🌫"
+	case id == 800:
+		return "☀"
+	case id == 801 || id == 802:
+		return "⛅"
+	default:
+		return "☁"
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 var cities = [20]string{
@@ -33,39 +35,94 @@ var cities = [20]string{
 	"Istanbul",
 }
 
+// Geocoder looks up cities known to the OpenWeather geocoding api.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string, limit int) ([]CityData, error)
+	ReverseGeocode(ctx context.Context, lat, lon float64, limit int) ([]CityData, error)
+}
+
 type client struct {
-	logger *logging.Logger
-	cfg    config.Config
+	logger     *logging.Logger
+	cfg        config.Config
+	httpClient *http.Client
 }
 
 func NewClient(logger *logging.Logger, cfg config.Config) *client {
 	return &client{
-		logger: logger,
-		cfg:    cfg,
+		logger:     logger,
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *client) Geocode(ctx context.Context, query string, limit int) ([]CityData, error) {
+	geocodingURL := fmt.Sprintf("http://api.openweathermap.org/geo/1.0/direct?q=%s&limit=%d&appid=%s", url.QueryEscape(query), limit, c.cfg.ApiID)
+
+	var cities []CityData
+	if err := c.getJSON(ctx, geocodingURL, &cities); err != nil {
+		return nil, fmt.Errorf("failed to geocode %q. error: %w", query, err)
+	}
+
+	return cities, nil
+}
+
+func (c *client) ReverseGeocode(ctx context.Context, lat, lon float64, limit int) ([]CityData, error) {
+	geocodingURL := fmt.Sprintf("http://api.openweathermap.org/geo/1.0/reverse?lat=%f&lon=%f&limit=%d&appid=%s", lat, lon, limit, c.cfg.ApiID)
+
+	var cities []CityData
+	if err := c.getJSON(ctx, geocodingURL, &cities); err != nil {
+		return nil, fmt.Errorf("failed to reverse geocode %f,%f. error: %w", lat, lon, err)
+	}
+
+	return cities, nil
+}
+
+func (c *client) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	r, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status %s", r.Status)
 	}
+
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+type geocodingResponse struct {
+	query    string
+	response *http.Response
 }
 
 func (c *client) RefreshCitiesCoordinatesAsync(citiesService Service) error {
-	responseChan := make(chan *http.Response, len(cities))
+	responseChan := make(chan geocodingResponse, len(cities))
 
 	for _, city := range cities {
 		city := city
 		go func() {
-			url := fmt.Sprintf("http://api.openweathermap.org/geo/1.0/direct?q=%s&limit=1&appid=%s", city, c.cfg.ApiID)
+			geocodingURL := fmt.Sprintf("http://api.openweathermap.org/geo/1.0/direct?q=%s&limit=1&appid=%s", url.QueryEscape(city), c.cfg.ApiID)
 
-			r, err := http.Get(url)
+			r, err := http.Get(geocodingURL)
 			if err != nil {
 				c.logger.Fatal(err)
 			}
 
-			responseChan <- r
+			responseChan <- geocodingResponse{query: city, response: r}
 		}()
 	}
 
-	var s []CityData
 	count := 0
 
-	for response := range responseChan {
+	for geocoding := range responseChan {
+		var s []CityData
+		response := geocoding.response
 		err := json.NewDecoder(response.Body).Decode(&s)
 		if err != nil {
 			log.Fatal(err)
@@ -81,7 +138,12 @@ func (c *client) RefreshCitiesCoordinatesAsync(citiesService Service) error {
 			close(responseChan)
 		}
 
-		err = citiesService.Create(context.TODO(), s[0])
+		if len(s) == 0 {
+			c.logger.Errorf("city %s not found by geocoding api", geocoding.query)
+			continue
+		}
+
+		err = citiesService.Create(context.TODO(), s[0], geocoding.query)
 		if err != nil {
 			panic(err)
 		}
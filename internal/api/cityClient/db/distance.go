@@ -0,0 +1,59 @@
+package weatherApiClient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Distance from ($1, $2) to the city in kilometres. PostGIS and earthdistance
+// are used when installed, otherwise the haversine formula is computed in SQL,
+// with the argument of asin clamped to 1 as rounding can push it over for
+// antipodal points.
+const (
+	postgisDistance       = `ST_DistanceSphere(ST_MakePoint(c.lon, c.lat), ST_MakePoint($2, $1)) / 1000`
+	earthDistance         = `earth_distance(ll_to_earth($1, $2), ll_to_earth(c.lat, c.lon)) / 1000`
+	haversineDistance     = `6371 * 2 * asin(least(1, sqrt(power(sin(radians(c.lat - $1) / 2), 2) + cos(radians($1)) * cos(radians(c.lat)) * power(sin(radians(c.lon - $2) / 2), 2))))`
+	distanceExtensionsSQL = `SELECT extname FROM pg_extension WHERE extname IN ('postgis', 'earthdistance');`
+)
+
+type distanceFunction struct {
+	once sync.Once
+	sql  string
+}
+
+// distanceExpression picks the most precise distance function available in the
+// database. The result is detected once per storage.
+func (d db) distanceExpression(ctx context.Context) string {
+	e := d.distance
+	e.once.Do(func() {
+		e.sql = haversineDistance
+
+		rows, err := d.client.Query(ctx, distanceExtensionsSQL)
+		if err != nil {
+			d.logger.Warnf("failed to detect distance extensions, using haversine. error: %v", err)
+			return
+		}
+		defer rows.Close()
+
+		installed := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err = rows.Scan(&name); err != nil {
+				d.logger.Warnf("failed to detect distance extensions, using haversine. error: %v", err)
+				return
+			}
+			installed[name] = true
+		}
+
+		switch {
+		case installed["postgis"]:
+			e.sql = postgisDistance
+		case installed["earthdistance"]:
+			e.sql = earthDistance
+		}
+		d.logger.Debug(fmt.Sprintf("distance expression: %s", e.sql))
+	})
+
+	return e.sql
+}
@@ -2,43 +2,235 @@ package weatherApiClient
 
 import (
 	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/apperror"
 	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/geo"
 	"WeatherServiceAPI/pkg/logging"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
 	_ "github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
 )
 
 type db struct {
-	client postgresql.Client
-	logger *logging.Logger
+	client   postgresql.Client
+	logger   *logging.Logger
+	distance *distanceFunction
 }
 
-func (d db) Create(ctx context.Context, data cityClient.CityData) error {
-	q := `INSERT INTO cities (name, lat, lon, country) VALUES ($1, $2, $3, $4) ON CONFLICT (name, country) DO NOTHING;`
+func (d db) Create(ctx context.Context, data cityClient.CityData) (cityID string, err error) {
+	q := `INSERT INTO cities (name, lat, lon, country) VALUES ($1, $2, $3, $4) ON CONFLICT (name, country) DO UPDATE SET lat = excluded.lat, lon = excluded.lon RETURNING id;`
 
 	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
-	_, err := d.client.Exec(ctx, q, data.Name, data.Lat, data.Lon, data.Country)
+	err = d.client.QueryRow(ctx, q, data.Name, data.Lat, data.Lon, data.Country).Scan(&cityID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			d.logger.Error(newErr)
-			return newErr
+			return "", newErr
+		}
+		return "", err
+	}
+
+	return cityID, nil
+}
+
+func (d db) CreateAliases(ctx context.Context, cityID string, aliases []cityClient.CityAlias) error {
+	q := `INSERT INTO city_aliases (city_id, alias, normalized, lang) VALUES ($1, $2, $3, $4) ON CONFLICT (city_id, normalized) DO NOTHING;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	for _, alias := range aliases {
+		normalized := cityClient.NormalizeName(alias.Alias)
+		if normalized == "" {
+			continue
+		}
+
+		_, err := d.client.Exec(ctx, q, cityID, alias.Alias, normalized, alias.Lang)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				d.logger.Error(newErr)
+				return newErr
+			}
+			return err
 		}
-		return err
 	}
 
 	return nil
 }
 
+func (d db) FindOne(ctx context.Context, id string) (cty cityClient.CityData, err error) {
+	q := `SELECT id, name, lat, lon, country FROM cities WHERE id = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	err = d.client.QueryRow(ctx, q, id).Scan(&cty.Id, &cty.Name, &cty.Lat, &cty.Lon, &cty.Country)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return cty, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return cty, newErr
+		}
+		return cty, err
+	}
+
+	return cty, nil
+}
+
+func (d db) FindByName(ctx context.Context, normalizedName, country string) ([]cityClient.CityData, error) {
+	q := `SELECT DISTINCT c.id, c.name, c.lat, c.lon, c.country FROM city_aliases a JOIN cities c ON c.id = a.city_id WHERE a.normalized = $1 AND ($2 = '' OR lower(c.country) = lower($2));`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	return d.queryCities(ctx, q, normalizedName, country)
+}
+
+func (d db) FindSimilar(ctx context.Context, normalizedName string, limit int) ([]cityClient.CityData, error) {
+	q := `SELECT c.id, c.name, c.lat, c.lon, c.country FROM (SELECT city_id, max(similarity(normalized, $1)) AS score FROM city_aliases WHERE normalized % $1 GROUP BY city_id) a JOIN cities c ON c.id = a.city_id ORDER BY a.score DESC, c.name LIMIT $2;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	return d.queryCities(ctx, q, normalizedName, limit)
+}
+
 func (d db) FindAll(ctx context.Context) ([]cityClient.CityData, error) {
 	q := `SELECT id, name, lat, lon, country FROM cities;`
 
-	rows, err := d.client.Query(ctx, q)
+	return d.queryCities(ctx, q)
+}
+
+func (d db) Search(ctx context.Context, normalizedQuery string, limit int) ([]cityClient.CityData, error) {
+	q := `SELECT c.id, c.name, c.lat, c.lon, c.country FROM (SELECT city_id, bool_or(normalized LIKE $2) AS prefix, max(similarity(normalized, $1)) AS score FROM city_aliases WHERE normalized LIKE $2 OR normalized % $1 GROUP BY city_id) a JOIN cities c ON c.id = a.city_id ORDER BY a.prefix DESC, a.score DESC, c.name LIMIT $3;`
+
+	prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(normalizedQuery) + "%"
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	return d.queryCities(ctx, q, normalizedQuery, prefix, limit)
+}
+
+func (d db) FindNearest(ctx context.Context, lat, lon, radiusKm float64, limit int) ([]cityClient.CityDistance, error) {
+	q := fmt.Sprintf(`SELECT id, name, lat, lon, country, distance FROM (SELECT c.id, c.name, c.lat, c.lon, c.country, %s AS distance FROM cities c) d WHERE ($3::float8 <= 0 OR distance <= $3::float8) ORDER BY distance LIMIT NULLIF($4::int, 0);`, d.distanceExpression(ctx))
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	rows, err := d.client.Query(ctx, q, lat, lon, radiusKm, limit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	cities := make([]cityClient.CityDistance, 0)
+
+	for rows.Next() {
+		var cty cityClient.CityDistance
+
+		err = rows.Scan(&cty.Id, &cty.Name, &cty.Lat, &cty.Lon, &cty.Country, &cty.DistanceKm)
+		if err != nil {
+			return nil, err
+		}
+
+		cities = append(cities, cty)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cities, nil
+}
+
+func (d db) FindReverseGeocoding(ctx context.Context, cell geo.Cell, fetchedAfter time.Time) (places []cityClient.CityData, fetchedAt time.Time, err error) {
+	q := `SELECT data_json, fetched_at FROM reverse_geocoding WHERE precision = $1 AND lat_key = $2 AND lon_key = $3 AND fetched_at > $4;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	var dataJson []byte
+	err = d.client.QueryRow(ctx, q, cell.Precision, cell.LatKey, cell.LonKey, fetchedAfter).Scan(&dataJson, &fetchedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fetchedAt, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, fetchedAt, newErr
+		}
+		return nil, fetchedAt, err
+	}
+
+	if err = json.Unmarshal(dataJson, &places); err != nil {
+		return nil, fetchedAt, fmt.Errorf("failed to unmarshal reverse geocoding. error: %w", err)
+	}
+
+	return places, fetchedAt, nil
+}
+
+func (d db) SaveReverseGeocoding(ctx context.Context, cell geo.Cell, places []cityClient.CityData, fetchedAt time.Time) error {
+	q := `INSERT INTO reverse_geocoding (precision, lat_key, lon_key, fetched_at, data_json) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (precision, lat_key, lon_key) DO UPDATE SET fetched_at = excluded.fetched_at, data_json = excluded.data_json;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	bytes, err := json.Marshal(places)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.Exec(ctx, q, cell.Precision, cell.LatKey, cell.LonKey, fetchedAt, bytes)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) DeleteReverseGeocodings(ctx context.Context, fetchedBefore time.Time) error {
+	q := `DELETE FROM reverse_geocoding WHERE fetched_at < $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	_, err := d.client.Exec(ctx, q, fetchedBefore)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) queryCities(ctx context.Context, q string, args ...interface{}) ([]cityClient.CityData, error) {
+	rows, err := d.client.Query(ctx, q, args...)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -73,7 +265,8 @@ func (d db) FindAll(ctx context.Context) ([]cityClient.CityData, error) {
 
 func NewStorage(client postgresql.Client, logger *logging.Logger) cityClient.Storage {
 	return &db{
-		client: client,
-		logger: logger,
+		client:   client,
+		logger:   logger,
+		distance: &distanceFunction{},
 	}
 }
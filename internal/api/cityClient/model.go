@@ -1,9 +1,41 @@
 package cityClient
 
+import "time"
+
 type CityData struct {
-	Id      string  `json:"id"`
-	Name    string  `json:"name"`
-	Lat     float64 `json:"lat"`
-	Lon     float64 `json:"lon"`
-	Country string  `json:"country"`
+	Id         string            `json:"id"`
+	Name       string            `json:"name"`
+	Lat        float64           `json:"lat"`
+	Lon        float64           `json:"lon"`
+	Country    string            `json:"country"`
+	State      string            `json:"state,omitempty"`
+	LocalNames map[string]string `json:"local_names,omitempty"`
+}
+
+// CityAlias is an alternative name a city can be looked up by.
+type CityAlias struct {
+	Alias string `json:"alias"`
+	Lang  string `json:"lang,omitempty"`
+}
+
+// SearchResult lists tracked cities matching a search query and, if requested,
+// cities known to the geocoding api that are not tracked yet.
+type SearchResult struct {
+	Tracked   []CityData `json:"tracked"`
+	Untracked []CityData `json:"untracked,omitempty"`
+}
+
+// CityDistance is a tracked city with its distance from a requested point.
+type CityDistance struct {
+	CityData
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// ReverseGeocoding lists the places found around a point.
+type ReverseGeocoding struct {
+	Lat       float64    `json:"lat"`
+	Lon       float64    `json:"lon"`
+	FetchedAt time.Time  `json:"fetched_at"`
+	Cached    bool       `json:"cached"`
+	Places    []CityData `json:"places"`
 }
@@ -0,0 +1,26 @@
+package cityClient
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// NormalizeName folds a city name for comparison: diacritics are stripped,
+// letters are lower-cased and separators (spaces, '+', '-', '_') collapse
+// into a single space, so "Naberezhnye+Chelny " matches "naberezhnye chelny".
+func NormalizeName(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, name)
+	if err != nil {
+		folded = name
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(folded), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '+' || r == '-' || r == '_'
+	})
+
+	return strings.Join(fields, " ")
+}
@@ -1,36 +1,213 @@
 package cityClient
 
 import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/geo"
 	"WeatherServiceAPI/pkg/logging"
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 )
 
+const (
+	suggestionsLimit    = 3
+	reverseGeocodeLimit = 5
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 type service struct {
 	storage Storage
 	logger  *logging.Logger
+
+	geocoder          Geocoder
+	reversePrecision  int
+	reverseGeocodeTTL time.Duration
 }
 
-func NewService(storage Storage, logger *logging.Logger) (Service, error) {
+// NewService creates the city service. Reverse geocoding results are cached per
+// grid cell of reversePrecision decimal places for reverseGeocodeTTL.
+func NewService(storage Storage, logger *logging.Logger, geocoder Geocoder, reversePrecision int, reverseGeocodeTTL time.Duration) (Service, error) {
+	if reversePrecision < 0 || reversePrecision > 6 {
+		return nil, fmt.Errorf("invalid reverse geocoding precision %d. expected 0 to 6 decimal places", reversePrecision)
+	}
+
 	return &service{
-		storage: storage,
-		logger:  logger,
+		storage:           storage,
+		logger:            logger,
+		geocoder:          geocoder,
+		reversePrecision:  reversePrecision,
+		reverseGeocodeTTL: reverseGeocodeTTL,
 	}, nil
 }
 
 type Service interface {
-	Create(ctx context.Context, data CityData) error
+	Create(ctx context.Context, data CityData, aliases ...string) error
 	FindAll(ctx context.Context) ([]CityData, error)
+	Resolve(ctx context.Context, query string) (CityData, error)
+	Search(ctx context.Context, query string, limit int) ([]CityData, error)
+	FindNearest(ctx context.Context, lat, lon float64) (CityDistance, error)
+	FindWithinRadius(ctx context.Context, lat, lon, radiusKm float64) ([]CityDistance, error)
+	ReverseGeocode(ctx context.Context, lat, lon float64) (ReverseGeocoding, error)
 }
 
-func (s service) Create(ctx context.Context, data CityData) error {
-	err := s.storage.Create(ctx, data)
+// Create stores the city together with the names it can be looked up by: its
+// own name, the names passed in aliases and the local names from geocoding.
+func (s service) Create(ctx context.Context, data CityData, aliases ...string) error {
+	cityID, err := s.storage.Create(ctx, data)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	cityAliases := []CityAlias{{Alias: data.Name}}
+	for _, alias := range aliases {
+		cityAliases = append(cityAliases, CityAlias{Alias: alias})
+	}
+	for lang, name := range data.LocalNames {
+		cityAliases = append(cityAliases, CityAlias{Alias: name, Lang: lang})
+	}
+
+	return s.storage.CreateAliases(ctx, cityID, cityAliases)
 }
 
 func (s service) FindAll(ctx context.Context) ([]CityData, error) {
 	return s.storage.FindAll(ctx)
 }
+
+// Resolve finds a tracked city by its id, by "name,country" or by any of its
+// names ignoring case, whitespace and diacritics.
+func (s service) Resolve(ctx context.Context, query string) (CityData, error) {
+	logger := logging.FromContext(ctx)
+	query = strings.TrimSpace(query)
+
+	if uuidRegexp.MatchString(query) {
+		logger.Debug("resolve city by id")
+		city, err := s.storage.FindOne(ctx, query)
+		if err != nil {
+			if errors.Is(err, apperror.ErrNotFound) {
+				return city, cityNotFound(query, nil)
+			}
+			return city, err
+		}
+		return city, nil
+	}
+
+	name, country := query, ""
+	if i := strings.LastIndex(query, ","); i >= 0 {
+		name, country = query[:i], strings.TrimSpace(query[i+1:])
+	}
+	normalized := NormalizeName(name)
+	if normalized == "" {
+		return CityData{}, apperror.NewAppError(nil, "city name is empty", "", "WeatherService-000004")
+	}
+
+	logger.Debugf("resolve city by name %q", normalized)
+	cities, err := s.storage.FindByName(ctx, normalized, country)
+	if err != nil {
+		return CityData{}, err
+	}
+
+	switch len(cities) {
+	case 1:
+		return cities[0], nil
+	case 0:
+		suggestions, err := s.storage.FindSimilar(ctx, normalized, suggestionsLimit)
+		if err != nil {
+			logger.Warnf("failed to find similar cities. error: %v", err)
+		}
+		return CityData{}, cityNotFound(query, suggestions)
+	default:
+		options := make([]string, 0, len(cities))
+		for _, c := range cities {
+			options = append(options, fmt.Sprintf("%s,%s", c.Name, c.Country))
+		}
+		return CityData{}, apperror.NewAppError(nil,
+			fmt.Sprintf("city %q is ambiguous. specify one of: %s", query, strings.Join(options, "; ")),
+			"use name,country or the city id", "WeatherService-000005")
+	}
+}
+
+// Search returns tracked cities whose names start with or are similar to query,
+// prefix matches first.
+func (s service) Search(ctx context.Context, query string, limit int) ([]CityData, error) {
+	normalized := NormalizeName(query)
+	if normalized == "" {
+		return []CityData{}, nil
+	}
+
+	return s.storage.Search(ctx, normalized, limit)
+}
+
+// FindNearest returns the tracked city closest to the point.
+func (s service) FindNearest(ctx context.Context, lat, lon float64) (CityDistance, error) {
+	cities, err := s.storage.FindNearest(ctx, lat, lon, 0, 1)
+	if err != nil {
+		return CityDistance{}, err
+	}
+	if len(cities) == 0 {
+		return CityDistance{}, apperror.ErrNotFound
+	}
+
+	return cities[0], nil
+}
+
+// FindWithinRadius returns tracked cities within radiusKm of the point, closest
+// first.
+func (s service) FindWithinRadius(ctx context.Context, lat, lon, radiusKm float64) ([]CityDistance, error) {
+	return s.storage.FindNearest(ctx, lat, lon, radiusKm, 0)
+}
+
+// ReverseGeocode names the places around the point using the geocoding api.
+// Results are stored in the database and reused for nearby points.
+func (s service) ReverseGeocode(ctx context.Context, lat, lon float64) (ReverseGeocoding, error) {
+	logger := logging.FromContext(ctx)
+
+	cell := geo.NewCell(lat, lon, s.reversePrecision)
+	var result ReverseGeocoding
+	result.Lat, result.Lon = cell.Center()
+
+	places, fetchedAt, err := s.storage.FindReverseGeocoding(ctx, cell, time.Now().Add(-s.reverseGeocodeTTL))
+	if err == nil {
+		logger.Debug("serve reverse geocoding from cache")
+		result.Cached = true
+		result.FetchedAt = fetchedAt
+		result.Places = places
+		return result, nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return result, err
+	}
+
+	logger.Debug("reverse geocode with geocoding api")
+	places, err = s.geocoder.ReverseGeocode(ctx, result.Lat, result.Lon, reverseGeocodeLimit)
+	if err != nil {
+		return result, err
+	}
+
+	result.FetchedAt = time.Now()
+	result.Places = places
+	if err = s.storage.SaveReverseGeocoding(ctx, cell, places, result.FetchedAt); err != nil {
+		return result, err
+	}
+	if err = s.storage.DeleteReverseGeocodings(ctx, result.FetchedAt.Add(-s.reverseGeocodeTTL)); err != nil {
+		logger.Warnf("failed to delete expired reverse geocodings. error: %v", err)
+	}
+
+	return result, nil
+}
+
+func cityNotFound(query string, suggestions []CityData) error {
+	message := fmt.Sprintf("city %q not found", query)
+	if len(suggestions) > 0 {
+		names := make([]string, 0, len(suggestions))
+		for _, c := range suggestions {
+			names = append(names, fmt.Sprintf("%s,%s", c.Name, c.Country))
+		}
+		message = fmt.Sprintf("%s. did you mean: %s?", message, strings.Join(names, "; "))
+	}
+
+	return apperror.NewAppError(apperror.ErrNotFound, message, "", "WeatherService-000003")
+}
@@ -1,10 +1,22 @@
 package cityClient
 
 import (
+	"WeatherServiceAPI/pkg/geo"
 	"context"
+	"time"
 )
 
 type Storage interface {
-	Create(ctx context.Context, city CityData) error
+	Create(ctx context.Context, city CityData) (string, error)
+	CreateAliases(ctx context.Context, cityID string, aliases []CityAlias) error
 	FindAll(ctx context.Context) ([]CityData, error)
+	FindOne(ctx context.Context, id string) (CityData, error)
+	FindByName(ctx context.Context, normalizedName, country string) ([]CityData, error)
+	FindSimilar(ctx context.Context, normalizedName string, limit int) ([]CityData, error)
+	Search(ctx context.Context, normalizedQuery string, limit int) ([]CityData, error)
+	FindNearest(ctx context.Context, lat, lon, radiusKm float64, limit int) ([]CityDistance, error)
+
+	FindReverseGeocoding(ctx context.Context, cell geo.Cell, fetchedAfter time.Time) (places []CityData, fetchedAt time.Time, err error)
+	SaveReverseGeocoding(ctx context.Context, cell geo.Cell, places []CityData, fetchedAt time.Time) error
+	DeleteReverseGeocodings(ctx context.Context, fetchedBefore time.Time) error
 }
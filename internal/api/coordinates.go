@@ -0,0 +1,57 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetCoordinatesWeather godoc
+// @Summary      Weather for arbitrary coordinates
+// @Description  Forecast for a point that is not a tracked city. Points are rounded to a grid and cached for a while, nearby requests share the cached forecast.
+// @Tags         Weather
+// @Accept       json
+// @Produce      json
+// @Param        lat  query    number  true  "Latitude"
+// @Param        lon  query    number  true  "Longitude"
+// @Param        units  query  string  false  "metric (default), imperial or standard"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {object}  weatherClient.CoordinateWeather
+// @Router       /weather [get]
+func (h *handler) GetCoordinatesWeather(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET WEATHER FOR COORDINATES")
+	w.Header().Set("Content-Type", "application/json")
+
+	lat, lon, err := parseCoordinates(r)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.requestUnits(r)
+	if err != nil {
+		return err
+	}
+
+	weather, err := h.weatherService.FindByCoordinates(r.Context(), lat, lon)
+	if err != nil {
+		return err
+	}
+	weather.Forecast = weatherClient.LocalizeForecast(weatherClient.ConvertForecastUnits(weather.Forecast, u), i18n.FromContext(r.Context()))
+	labels := u.Labels()
+	weather.Units = &labels
+
+	logger.Debug("marshal coordinates weather")
+	weatherBytes, err := json.Marshal(weather)
+	if err != nil {
+		return fmt.Errorf("failed to marshall coordinates weather. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(weatherBytes)
+
+	return nil
+}
@@ -0,0 +1,428 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	csvContentType    = "text/csv"
+	ndjsonContentType = "application/x-ndjson"
+
+	// exportFlushRows is the number of rows written between flushes.
+	exportFlushRows = 100
+	// exportWriteTimeout is how long a client may take to read a batch of
+	// rows. The stream holds a database connection, so clients that stop
+	// reading are cut off instead of keeping it.
+	exportWriteTimeout = 15 * time.Second
+)
+
+// exportRow is a single forecast slot of a city.
+type exportRow struct {
+	city cityClient.CityData
+	slot weatherClient.Forecast
+}
+
+type exportColumn struct {
+	name  string
+	value func(row exportRow) interface{}
+}
+
+// exportColumns is the schema of exported rows. Every row has every selected
+// column, in this order, whatever the city.
+var exportColumns = []exportColumn{
+	{"city_id", func(row exportRow) interface{} { return row.city.Id }},
+	{"city", func(row exportRow) interface{} { return row.city.Name }},
+	{"country", func(row exportRow) interface{} { return row.city.Country }},
+	{"lat", func(row exportRow) interface{} { return row.city.Lat }},
+	{"lon", func(row exportRow) interface{} { return row.city.Lon }},
+	{"time", func(row exportRow) interface{} { return row.slot.Time() }},
+	{"temp", func(row exportRow) interface{} { return row.slot.Main.Temp }},
+	{"feels_like", func(row exportRow) interface{} { return row.slot.Main.FeelsLike }},
+	{"temp_min", func(row exportRow) interface{} { return row.slot.Main.TempMin }},
+	{"temp_max", func(row exportRow) interface{} { return row.slot.Main.TempMax }},
+	{"pressure", func(row exportRow) interface{} { return row.slot.Main.Pressure }},
+	{"humidity", func(row exportRow) interface{} { return row.slot.Main.Humidity }},
+	{"wind_speed", func(row exportRow) interface{} { return row.slot.Wind.Speed }},
+	{"wind_deg", func(row exportRow) interface{} { return row.slot.Wind.Deg }},
+	{"wind_gust", func(row exportRow) interface{} { return row.slot.Wind.Gust }},
+	{"clouds", func(row exportRow) interface{} { return row.slot.Clouds.All }},
+	{"visibility", func(row exportRow) interface{} { return row.slot.Visibility }},
+	{"pop", func(row exportRow) interface{} { return row.slot.Pop }},
+	{"rain_3h", func(row exportRow) interface{} { return row.slot.Rain.ThreeH }},
+	{"snow_3h", func(row exportRow) interface{} { return row.slot.Snow.ThreeH }},
+	{"condition_id", func(row exportRow) interface{} {
+		if len(row.slot.Weather) == 0 {
+			return 0
+		}
+		return row.slot.Weather[0].ID
+	}},
+	{"condition", func(row exportRow) interface{} {
+		if len(row.slot.Weather) == 0 {
+			return ""
+		}
+		return row.slot.Weather[0].Main
+	}},
+	{"description", func(row exportRow) interface{} {
+		if len(row.slot.Weather) == 0 {
+			return ""
+		}
+		return row.slot.Weather[0].Description
+	}},
+}
+
+// rowWriter writes exported rows in one output format.
+type rowWriter interface {
+	WriteHeader(columns []exportColumn) error
+	WriteRow(columns []exportColumn, row exportRow) error
+	Flush() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (c csvRowWriter) WriteHeader(columns []exportColumn) error {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.name
+	}
+	return c.w.Write(names)
+}
+
+func (c csvRowWriter) WriteRow(columns []exportColumn, row exportRow) error {
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		record[i] = formatCSVValue(col.value(row))
+	}
+	return c.w.Write(record)
+}
+
+func (c csvRowWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatCSVValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(value)
+	case time.Time:
+		return value.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+type ndjsonRowWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (n *ndjsonRowWriter) WriteHeader([]exportColumn) error {
+	return nil
+}
+
+// WriteRow writes the row as a JSON object keeping the column order.
+func (n *ndjsonRowWriter) WriteRow(columns []exportColumn, row exportRow) error {
+	n.buf.Reset()
+	n.buf.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			n.buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(col.name)
+		value, err := json.Marshal(col.value(row))
+		if err != nil {
+			return err
+		}
+		n.buf.Write(key)
+		n.buf.WriteByte(':')
+		n.buf.Write(value)
+	}
+	n.buf.WriteString("}\n")
+
+	_, err := n.w.Write(n.buf.Bytes())
+	return err
+}
+
+func (n *ndjsonRowWriter) Flush() error {
+	return nil
+}
+
+// ExportCityForecast godoc
+// @Summary      Export city forecast
+// @Description  Stream the forecast of a city as CSV or NDJSON
+// @Tags         Export
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        city      path     string  true   "City name (any case, native names allowed), name,country or city id"
+// @Param        format    query    string  false  "csv or ndjson. Defaults to the Accept header, then ndjson"
+// @Param        columns   query    string  false  "Comma separated columns, all by default"
+// @Param        from      query    string  false  "Start of the period, 2006-01-02 or 2006-01-02T15:04:05Z"
+// @Param        to        query    string  false  "End of the period (exclusive), 2006-01-02 or 2006-01-02T15:04:05Z"
+// @Param        units     query    string  false  "metric (default), imperial or standard"
+// @Param        pressure  query    string  false  "Pressure unit overriding the unit system: hPa, inHg or mmHg"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {string}  string
+// @Router       /cities/{city}/forecast [get]
+func (h *handler) ExportCityForecast(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("EXPORT CITY FORECAST")
+
+	params := httprouter.ParamsFromContext(r.Context())
+	city, err := h.cityService.Resolve(r.Context(), params.ByName("city"))
+	if err != nil {
+		return err
+	}
+
+	return h.exportForecast(w, r, []cityClient.CityData{city})
+}
+
+// ExportForecast godoc
+// @Summary      Export forecasts of several cities
+// @Description  Stream the forecasts of the given cities, or of all tracked cities, as CSV or NDJSON with the same columns for every city
+// @Tags         Export
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        city      query    []string  false  "City name, name,country or city id. Repeat for several cities, all tracked cities by default"  collectionFormat(multi)
+// @Param        format    query    string    false  "csv or ndjson. Defaults to the Accept header, then ndjson"
+// @Param        columns   query    string    false  "Comma separated columns, all by default"
+// @Param        from      query    string    false  "Start of the period, 2006-01-02 or 2006-01-02T15:04:05Z"
+// @Param        to        query    string    false  "End of the period (exclusive), 2006-01-02 or 2006-01-02T15:04:05Z"
+// @Param        units     query    string    false  "metric (default), imperial or standard"
+// @Param        pressure  query    string    false  "Pressure unit overriding the unit system: hPa, inHg or mmHg"
+// @Param        lang      query    string    false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {string}  string
+// @Router       /export [get]
+func (h *handler) ExportForecast(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("EXPORT FORECAST")
+
+	var cities []cityClient.CityData
+	if queries := r.URL.Query()["city"]; len(queries) > 0 {
+		for _, query := range queries {
+			city, err := h.cityService.Resolve(r.Context(), query)
+			if err != nil {
+				return err
+			}
+			cities = append(cities, city)
+		}
+	} else {
+		var err error
+		cities, err = h.cityService.FindAll(r.Context())
+		if err != nil {
+			return err
+		}
+	}
+
+	return h.exportForecast(w, r, cities)
+}
+
+// exportForecast streams the forecasts of cities row by row. Errors found
+// before the first row are returned to the caller; once the response has
+// started they can only be logged.
+func (h *handler) exportForecast(w http.ResponseWriter, r *http.Request, cities []cityClient.CityData) error {
+	logger := logging.FromContext(r.Context())
+
+	contentType, err := exportContentType(r)
+	if err != nil {
+		return err
+	}
+
+	columns, err := parseExportColumns(r.URL.Query().Get("columns"))
+	if err != nil {
+		return err
+	}
+
+	from, err := parseExportTime(r, "from", time.Time{})
+	if err != nil {
+		return err
+	}
+	to, err := parseExportTime(r, "to", time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return err
+	}
+
+	u, err := h.requestUnits(r)
+	if err != nil {
+		return err
+	}
+	lang := i18n.FromContext(r.Context())
+
+	byID := make(map[string]cityClient.CityData, len(cities))
+	cityIDs := make([]string, 0, len(cities))
+	for _, city := range cities {
+		if _, ok := byID[city.Id]; ok {
+			continue
+		}
+		byID[city.Id] = city
+		cityIDs = append(cityIDs, city.Id)
+	}
+
+	var writer rowWriter
+	if contentType == csvContentType {
+		writer = csvRowWriter{w: csv.NewWriter(w)}
+	} else {
+		writer = &ndjsonRowWriter{w: w}
+	}
+	// large exports outlive the server write timeout, the deadline is
+	// extended for every batch instead
+	rc := http.NewResponseController(w)
+	extendDeadline := func() {
+		if err := rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil {
+			logger.Debugf("failed to extend write deadline. error: %v", err)
+		}
+	}
+	extendDeadline()
+
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		return writer.WriteHeader(columns)
+	}
+
+	rows := 0
+	err = h.weatherService.StreamForecast(r.Context(), cityIDs, from, to, func(cityID string, slot weatherClient.Forecast) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		slot = slot.ConvertUnits(u).Localize(lang)
+		if err := writer.WriteRow(columns, exportRow{city: byID[cityID], slot: slot}); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			extendDeadline()
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			// a client past the deadline stops the stream here
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			return err
+		}
+		logger.Errorf("failed to export forecast after %d rows. error: %v", rows, err)
+		return nil
+	}
+
+	if !started {
+		if err = start(); err != nil {
+			logger.Errorf("failed to write export header. error: %v", err)
+			return nil
+		}
+	}
+	extendDeadline()
+	if err = writer.Flush(); err != nil {
+		logger.Errorf("failed to flush export. error: %v", err)
+	}
+	logger.Debugf("exported %d rows", rows)
+
+	return nil
+}
+
+// exportContentType picks the output format from ?format= or the Accept
+// header. NDJSON is the default.
+func exportContentType(r *http.Request) (string, error) {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "csv":
+		return csvContentType, nil
+	case "ndjson":
+		return ndjsonContentType, nil
+	case "":
+	default:
+		return "", apperror.NewAppError(nil, "invalid query parameter format", "expected: csv or ndjson", "WeatherService-000004")
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case csvContentType:
+			return csvContentType, nil
+		case ndjsonContentType:
+			return ndjsonContentType, nil
+		}
+	}
+
+	return ndjsonContentType, nil
+}
+
+func parseExportColumns(s string) ([]exportColumn, error) {
+	if s == "" {
+		return exportColumns, nil
+	}
+
+	columns := make([]exportColumn, 0)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, col := range exportColumns {
+			if col.name == name {
+				columns = append(columns, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(exportColumns))
+			for i, col := range exportColumns {
+				names[i] = col.name
+			}
+			return nil, apperror.NewAppError(nil, "invalid query parameter columns",
+				fmt.Sprintf("unknown column %q. expected: %s", name, strings.Join(names, ", ")), "WeatherService-000004")
+		}
+	}
+
+	return columns, nil
+}
+
+func parseExportTime(r *http.Request, name string, fallback time.Time) (time.Time, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return fallback, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			return t, apperror.NewAppError(err, fmt.Sprintf("invalid query parameter %s", name), "expected 2006-01-02 or 2006-01-02T15:04:05Z format", "WeatherService-000004")
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ReverseGeocode godoc
+// @Summary      Reverse geocoding
+// @Description  Names the places (city, state, country) around the coordinates using the OpenWeather geocoding api. Results are cached for nearby points.
+// @Tags         Geocoding
+// @Accept       json
+// @Produce      json
+// @Param        lat  query    number  true  "Latitude"
+// @Param        lon  query    number  true  "Longitude"
+// @Success      200  {object}  cityClient.ReverseGeocoding
+// @Router       /geocode/reverse [get]
+func (h *handler) ReverseGeocode(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("REVERSE GEOCODE")
+	w.Header().Set("Content-Type", "application/json")
+
+	lat, lon, err := parseCoordinates(r)
+	if err != nil {
+		return err
+	}
+
+	places, err := h.cityService.ReverseGeocode(r.Context(), lat, lon)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal reverse geocoding")
+	placesBytes, err := json.Marshal(places)
+	if err != nil {
+		return fmt.Errorf("failed to marshall reverse geocoding. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(placesBytes)
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/geo"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/units"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	geoJSONContentType = "application/geo+json"
+	iconURL            = "https://openweathermap.org/img/wn/%s@2x.png"
+)
+
+// CityFeatureProperties are the properties of a city point in the GeoJSON
+// feed. Forecast fields are null for cities without a forecast yet.
+type CityFeatureProperties struct {
+	CityID      string        `json:"city_id"`
+	Name        string        `json:"name"`
+	Country     string        `json:"country"`
+	Time        *time.Time    `json:"time"`
+	Temp        *float64      `json:"temp"`
+	FeelsLike   *float64      `json:"feels_like"`
+	Condition   *string       `json:"condition"`
+	Description *string       `json:"description"`
+	Icon        *string       `json:"icon"`
+	IconURL     *string       `json:"icon_url"`
+	WindSpeed   *float64      `json:"wind_speed"`
+	WindDeg     *int          `json:"wind_deg"`
+	WindGust    *float64      `json:"wind_gust"`
+	Units       *units.Labels `json:"units"`
+}
+
+// GetCitiesGeoJSON godoc
+// @Summary      GeoJSON feed of all cities
+// @Description  FeatureCollection with a point per tracked city and the forecast slot closest to at (now by default)
+// @Tags         Weather
+// @Produce      application/geo+json
+// @Param        at        query    string  false  "Time of the forecast, 2006-01-02T15:04:05Z. Defaults to now"
+// @Param        units     query    string  false  "metric (default), imperial or standard"
+// @Param        pressure  query    string  false  "Pressure unit overriding the unit system: hPa, inHg or mmHg"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {object}  geo.FeatureCollection
+// @Router       /cities.geojson [get]
+func (h *handler) GetCitiesGeoJSON(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET CITIES GEOJSON")
+	w.Header().Set("Content-Type", geoJSONContentType)
+
+	at := time.Now()
+	if atString := r.URL.Query().Get("at"); atString != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, atString)
+		if err != nil {
+			return apperror.NewAppError(err, "invalid query parameter at", "expected 2006-01-02T15:04:05Z format", "WeatherService-000004")
+		}
+	}
+
+	u, err := h.requestUnits(r)
+	if err != nil {
+		return err
+	}
+	labels := u.Labels()
+	lang := i18n.FromContext(r.Context())
+
+	cities, err := h.cityService.FindAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	slots, err := h.weatherService.FindNearestSlots(r.Context(), at)
+	if err != nil {
+		return err
+	}
+
+	features := make([]geo.Feature, 0, len(cities))
+	for _, city := range cities {
+		properties := CityFeatureProperties{
+			CityID:  city.Id,
+			Name:    city.Name,
+			Country: city.Country,
+		}
+
+		if slot, ok := slots[city.Id]; ok {
+			slot = slot.ConvertUnits(u).Localize(lang)
+			slotTime := slot.Time()
+			properties.Time = &slotTime
+			properties.Temp = &slot.Main.Temp
+			properties.FeelsLike = &slot.Main.FeelsLike
+			properties.WindSpeed = &slot.Wind.Speed
+			properties.WindDeg = &slot.Wind.Deg
+			properties.WindGust = &slot.Wind.Gust
+			properties.Units = &labels
+			if len(slot.Weather) > 0 {
+				condition := slot.Weather[0]
+				icon := fmt.Sprintf(iconURL, condition.Icon)
+				properties.Condition = &condition.Main
+				properties.Description = &condition.Description
+				properties.Icon = &condition.Icon
+				properties.IconURL = &icon
+			}
+		}
+
+		features = append(features, geo.NewPointFeature(city.Id, city.Lat, city.Lon, properties))
+	}
+
+	logger.Debug("marshal cities geojson")
+	collectionBytes, err := json.Marshal(geo.NewFeatureCollection(features))
+	if err != nil {
+		return fmt.Errorf("failed to marshall cities geojson. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(collectionBytes)
+
+	return nil
+}
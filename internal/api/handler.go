@@ -6,6 +6,10 @@ import (
 	"WeatherServiceAPI/internal/api/weatherClient"
 	"WeatherServiceAPI/internal/apperror"
 	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/cache"
+	"WeatherServiceAPI/pkg/events"
+	"WeatherServiceAPI/pkg/i18n"
 	"WeatherServiceAPI/pkg/logging"
 	"encoding/json"
 	"fmt"
@@ -19,32 +23,97 @@ import (
 
 const (
 	citiesUrl       = "/api/cities"
+	citiesGeoJSON   = "/api/cities.geojson"
 	cityInfoUrl     = "/api/cities/:city"
 	cityDateInfoURL = "/api/cities/:city/:date"
+	weatherURL      = "/api/weather"
+	nearestURL      = "/api/weather/nearest"
+	reverseGeoURL   = "/api/geocode/reverse"
+	exportURL       = "/api/export"
+	calendarURL     = "/api/calendar/:token"
+	streamURL       = "/api/stream"
+
+	// searchSegment is served through cityInfoUrl and forecastSegment through
+	// cityDateInfoURL, see handlers.ParamRoutes
+	searchSegment           = "search"
+	forecastSegment         = "forecast"
+	forecastCalendarSegment = "forecast.ics"
 )
 
 type handler struct {
 	logger         *logging.Logger
 	cityService    cityClient.Service
 	weatherService weatherClient.Service
+	geocoder       cityClient.Geocoder
+	userService    user.Service
+	cache          *cache.Cache
+	broker         *events.Broker
+	heartbeat      time.Duration
 }
 
-func NewHandler(logger *logging.Logger, cityService cityClient.Service, weatherService weatherClient.Service) handlers.Handler {
+func NewHandler(logger *logging.Logger, cityService cityClient.Service, weatherService weatherClient.Service, geocoder cityClient.Geocoder, userService user.Service, responseCache *cache.Cache, broker *events.Broker, heartbeat time.Duration) handlers.Handler {
 	return &handler{
 		logger:         logger,
 		cityService:    cityService,
 		weatherService: weatherService,
+		geocoder:       geocoder,
+		userService:    userService,
+		cache:          responseCache,
+		broker:         broker,
+		heartbeat:      heartbeat,
 	}
 }
 
 func (h *handler) Register(router *httprouter.Router) {
-	router.HandlerFunc(http.MethodGet, citiesUrl, apperror.Middleware(h.GetAvailableCities))
-	router.HandlerFunc(http.MethodGet, cityInfoUrl, apperror.Middleware(h.GetBriefWeatherInfo))
-	router.HandlerFunc(http.MethodGet, cityDateInfoURL, apperror.Middleware(h.GetCityTimeInfo))
+	router.HandlerFunc(http.MethodGet, citiesUrl, h.cached(h.GetAvailableCities))
+	router.HandlerFunc(http.MethodGet, citiesGeoJSON, h.geoJSONHandler())
+	router.HandlerFunc(http.MethodGet, cityInfoUrl, handlers.ParamRoutes("city", map[string]http.HandlerFunc{
+		searchSegment: apperror.Middleware(h.SearchCities),
+	}, h.cached(h.GetBriefWeatherInfo)))
+	router.HandlerFunc(http.MethodGet, cityDateInfoURL, handlers.ParamRoutes("date", map[string]http.HandlerFunc{
+		forecastSegment:         apperror.Middleware(h.ExportCityForecast),
+		forecastCalendarSegment: apperror.Middleware(h.GetCityCalendar),
+	}, h.cached(h.GetCityTimeInfo)))
+	router.HandlerFunc(http.MethodGet, weatherURL, apperror.Middleware(h.GetCoordinatesWeather))
+	router.HandlerFunc(http.MethodGet, nearestURL, apperror.Middleware(h.GetNearestCityWeather))
+	router.HandlerFunc(http.MethodGet, reverseGeoURL, apperror.Middleware(h.ReverseGeocode))
+	router.HandlerFunc(http.MethodGet, exportURL, apperror.Middleware(h.ExportForecast))
+	router.HandlerFunc(http.MethodGet, calendarURL, apperror.Middleware(h.GetUserCalendar))
+	router.HandlerFunc(http.MethodGet, streamURL, apperror.Middleware(h.StreamUpdates))
 
 	router.HandlerFunc(http.MethodGet, "/doc/:any", swaggerHandler)
 }
 
+// cached serves next through the response cache. Responses for ?user= are
+// not cached, they are in the units of the user, which can change at any time.
+func (h *handler) cached(next func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	cached := h.cache.Middleware(apperror.Middleware(next))
+	uncached := apperror.Middleware(next)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("user") {
+			uncached(w, r)
+			return
+		}
+		cached(w, r)
+	}
+}
+
+// geoJSONHandler caches the feed of the current forecast only, ?at= would
+// make the number of cached responses unbounded.
+func (h *handler) geoJSONHandler() http.HandlerFunc {
+	cached := h.cached(h.GetCitiesGeoJSON)
+	uncached := apperror.Middleware(h.GetCitiesGeoJSON)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("at") {
+			uncached(w, r)
+			return
+		}
+		cached(w, r)
+	}
+}
+
 func swaggerHandler(res http.ResponseWriter, req *http.Request) {
 	httpSwagger.WrapHandler(res, req)
 }
@@ -58,7 +127,8 @@ func swaggerHandler(res http.ResponseWriter, req *http.Request) {
 // @Success      200  {array}   []cityClient.CityData
 // @Router       /cities [get]
 func (h *handler) GetAvailableCities(w http.ResponseWriter, r *http.Request) error {
-	h.logger.Info("GET CITIES")
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET CITIES")
 	w.Header().Set("Content-Type", "application/json")
 
 	citiesData, err := h.cityService.FindAll(r.Context())
@@ -70,7 +140,7 @@ func (h *handler) GetAvailableCities(w http.ResponseWriter, r *http.Request) err
 		return citiesData[i].Name < citiesData[j].Name
 	})
 
-	h.logger.Debug("marshal cities")
+	logger.Debug("marshal cities")
 	citiesBytes, err := json.Marshal(citiesData)
 	if err != nil {
 		return err
@@ -88,25 +158,40 @@ func (h *handler) GetAvailableCities(w http.ResponseWriter, r *http.Request) err
 // @Tags         Weather
 // @Accept       json
 // @Produce      json
-// @Param        city    path     string  true  "weather info for city"  "City name"
+// @Param        city    path     string  true  "City name (any case, native names allowed), name,country or city id"
+// @Param        units     query    string  false  "metric (default), imperial or standard"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Param        pressure  query    string  false  "Pressure unit overriding the unit system: hPa, inHg or mmHg"
+// @Param        user      query    string  false  "User uuid whose default units are used when units is not set"
 // @Success      200  {array}    weatherClient.BriefWeatherCity
 // @Router       /cities/{city} [get]
 func (h *handler) GetBriefWeatherInfo(w http.ResponseWriter, r *http.Request) error {
-	h.logger.Info("GET BRIEF WEATHER INFO FOR CITY")
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET BRIEF WEATHER INFO FOR CITY")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.logger.Debug("get city from context")
+	logger.Debug("get city from context")
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
-	cityName := params.ByName("city")
+	city, err := h.cityService.Resolve(r.Context(), params.ByName("city"))
+	if err != nil {
+		return err
+	}
 
-	briefInfo, err := h.weatherService.FindBriefInfo(r.Context(), cityName)
+	u, err := h.requestUnits(r)
 	if err != nil {
 		return err
 	}
 
-	briefInfo.AvgTemp = math.Round(briefInfo.AvgTemp*100) / 100
+	briefInfo, err := h.weatherService.FindBriefInfo(r.Context(), city.Id)
+	if err != nil {
+		return err
+	}
 
-	h.logger.Debug("marshal api brief info")
+	briefInfo.AvgTemp = math.Round(u.Temperature(briefInfo.AvgTemp)*100) / 100
+	labels := u.Labels()
+	briefInfo.Units = &labels
+
+	logger.Debug("marshal api brief info")
 	briefInfoBytes, err := json.Marshal(briefInfo)
 	if err != nil {
 		return fmt.Errorf("failed to marshall api brief info. error: %w", err)
@@ -124,17 +209,21 @@ func (h *handler) GetBriefWeatherInfo(w http.ResponseWriter, r *http.Request) er
 // @Tags         Weather
 // @Accept       json
 // @Produce      json
-// @Param        city    path     string  true  "weather info for city"  "City name"
+// @Param        city    path     string  true  "City name (any case, native names allowed), name,country or city id"
 // @Param        date    path     string  true  "date expected 2006-01-02 15:04:05 or 2006-01-02T15:04:05Z format"  "Date"
-// @Success      200  {array}    string
+// @Param        units     query    string  false  "metric (default), imperial or standard"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Param        pressure  query    string  false  "Pressure unit overriding the unit system: hPa, inHg or mmHg"
+// @Param        user      query    string  false  "User uuid whose default units are used when units is not set"
+// @Success      200  {object}   weatherClient.Forecast
 // @Router       /cities/{city}/{date} [get]
 func (h *handler) GetCityTimeInfo(w http.ResponseWriter, r *http.Request) error {
-	h.logger.Info("GET DETAILED WEATHER INFO FOR CITY ON DATE")
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET DETAILED WEATHER INFO FOR CITY ON DATE")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.logger.Debug("get city and date from context")
+	logger.Debug("get city and date from context")
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
-	cityName := params.ByName("city")
 	dateString := params.ByName("date")
 
 	date, err := time.Parse(time.RFC3339, dateString)
@@ -145,18 +234,37 @@ func (h *handler) GetCityTimeInfo(w http.ResponseWriter, r *http.Request) error
 		}
 	}
 
-	weatherByCityAndDate, err := h.weatherService.FindInfoByCityAndDate(r.Context(), cityName, date)
+	city, err := h.cityService.Resolve(r.Context(), params.ByName("city"))
 	if err != nil {
 		return err
 	}
 
-	h.logger.Debug("marshal api brief info")
+	u, err := h.requestUnits(r)
 	if err != nil {
-		return fmt.Errorf("failed to marshall api brief info. error: %w", err)
+		return err
+	}
+
+	weatherByCityAndDate, err := h.weatherService.FindInfoByCityAndDate(r.Context(), city.Id, date)
+	if err != nil {
+		return err
+	}
+
+	var forecast weatherClient.Forecast
+	if err = json.Unmarshal([]byte(weatherByCityAndDate), &forecast); err != nil {
+		return fmt.Errorf("failed to unmarshall forecast. error: %w", err)
+	}
+	forecast = forecast.ConvertUnits(u).Localize(i18n.FromContext(r.Context()))
+	labels := u.Labels()
+	forecast.Units = &labels
+
+	logger.Debug("marshal forecast")
+	forecastBytes, err := json.Marshal(forecast)
+	if err != nil {
+		return fmt.Errorf("failed to marshall forecast. error: %w", err)
 	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(weatherByCityAndDate))
+	w.Write(forecastBytes)
 
 	return nil
 }
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/units"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NearestCityWeather is the tracked city closest to the requested point with its
+// upcoming forecast.
+type NearestCityWeather struct {
+	cityClient.CityDistance
+	Units    *units.Labels            `json:"units,omitempty"`
+	Forecast []weatherClient.Forecast `json:"forecast"`
+}
+
+// GetNearestCityWeather godoc
+// @Summary      Weather of the nearest tracked city
+// @Description  Finds the tracked city closest to the coordinates and returns the distance and its forecast. With radius lists all tracked cities within radius km instead.
+// @Tags         Weather
+// @Accept       json
+// @Produce      json
+// @Param        lat     query    number  true   "Latitude"
+// @Param        lon     query    number  true   "Longitude"
+// @Param        radius  query    number  false  "List tracked cities within radius km"
+// @Param        units   query    string  false  "metric (default), imperial or standard"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {object}  NearestCityWeather  "without radius"
+// @Success      200  {array}   cityClient.CityDistance  "with radius"
+// @Router       /weather/nearest [get]
+func (h *handler) GetNearestCityWeather(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET NEAREST CITY WEATHER")
+	w.Header().Set("Content-Type", "application/json")
+
+	lat, lon, err := parseCoordinates(r)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.requestUnits(r)
+	if err != nil {
+		return err
+	}
+
+	var response interface{}
+	if radiusString := r.URL.Query().Get("radius"); radiusString != "" {
+		radius, err := strconv.ParseFloat(radiusString, 64)
+		if err != nil || radius <= 0 {
+			return apperror.NewAppError(err, "invalid query parameter radius", "expected a positive number of kilometres", "WeatherService-000004")
+		}
+
+		cities, err := h.cityService.FindWithinRadius(r.Context(), lat, lon, radius)
+		if err != nil {
+			return err
+		}
+		response = cities
+	} else {
+		city, err := h.cityService.FindNearest(r.Context(), lat, lon)
+		if err != nil {
+			return err
+		}
+
+		from := time.Now().UTC().Truncate(3 * time.Hour)
+		forecast, err := h.weatherService.FindForecast(r.Context(), city.Id, from, from.AddDate(0, 0, 6))
+		if err != nil {
+			return err
+		}
+		labels := u.Labels()
+		response = NearestCityWeather{CityDistance: city, Units: &labels, Forecast: weatherClient.LocalizeForecast(weatherClient.ConvertForecastUnits(forecast, u), i18n.FromContext(r.Context()))}
+	}
+
+	logger.Debug("marshal nearest city weather")
+	responseBytes, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshall nearest city weather. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBytes)
+
+	return nil
+}
+
+func parseCoordinates(r *http.Request) (lat, lon float64, err error) {
+	lat, err = strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, apperror.NewAppError(err, "invalid query parameter lat", "expected latitude between -90 and 90", "WeatherService-000004")
+	}
+
+	lon, err = strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, apperror.NewAppError(err, "invalid query parameter lon", "expected longitude between -180 and 180", "WeatherService-000004")
+	}
+
+	return lat, lon, nil
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	searchLimitDefault = 10
+	searchLimitMax     = 50
+	// geocodeLimitMax is the most cities the geocoding api returns.
+	geocodeLimitMax = 5
+)
+
+// SearchCities godoc
+// @Summary      Search cities
+// @Description  Autocomplete over tracked cities by name prefix or similarity, optionally completed with untracked cities from the geocoding api
+// @Tags         Weather
+// @Accept       json
+// @Produce      json
+// @Param        q         query    string  true   "Part of a city name"
+// @Param        limit     query    int     false  "Maximum number of cities in each list (default 10, max 50, max 5 for untracked)"
+// @Param        external  query    bool    false  "Also search the OpenWeather geocoding api for untracked cities"
+// @Success      200  {object}  cityClient.SearchResult
+// @Router       /cities/search [get]
+func (h *handler) SearchCities(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("SEARCH CITIES")
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		return apperror.NewAppError(nil, "query parameter q is required", "", "WeatherService-000004")
+	}
+
+	limit := searchLimitDefault
+	if limitString := r.URL.Query().Get("limit"); limitString != "" {
+		var err error
+		limit, err = strconv.Atoi(limitString)
+		if err != nil || limit < 1 {
+			return apperror.NewAppError(err, "invalid query parameter limit", "expected a positive integer", "WeatherService-000004")
+		}
+		if limit > searchLimitMax {
+			limit = searchLimitMax
+		}
+	}
+
+	tracked, err := h.cityService.Search(r.Context(), query, limit)
+	if err != nil {
+		return err
+	}
+	result := cityClient.SearchResult{Tracked: tracked}
+
+	if external, _ := strconv.ParseBool(r.URL.Query().Get("external")); external {
+		logger.Debug("search geocoding api")
+		geocodeLimit := limit
+		if geocodeLimit > geocodeLimitMax {
+			geocodeLimit = geocodeLimitMax
+		}
+		found, err := h.geocoder.Geocode(r.Context(), query, geocodeLimit)
+		if err != nil {
+			return err
+		}
+
+		known := make(map[string]bool, len(tracked))
+		for _, c := range tracked {
+			known[cityClient.NormalizeName(c.Name)+","+c.Country] = true
+		}
+		for _, c := range found {
+			if known[cityClient.NormalizeName(c.Name)+","+c.Country] {
+				continue
+			}
+			c.LocalNames = nil
+			result.Untracked = append(result.Untracked, c)
+		}
+	}
+
+	logger.Debug("marshal search result")
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshall search result. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultBytes)
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/events"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+
+	// streamRetry is the reconnection delay suggested to clients, ms.
+	streamRetry = 5000
+)
+
+// StreamUpdates godoc
+// @Summary      Stream of weather updates
+// @Description  Server-Sent Events stream with a "forecast" event whenever the refresh stores new data for one of the cities. Send Last-Event-ID (or last_event_id) to replay missed events after a reconnect. Comment lines are sent as heartbeats
+// @Tags         Stream
+// @Produce      text/event-stream
+// @Param        cities         query    string  false  "Comma separated city names or ids, all tracked cities by default"
+// @Param        city           query    []string  false  "City name, name,country or id, may be repeated. Combined with cities"  collectionFormat(multi)
+// @Param        last_event_id  query    int     false  "Id of the last received event, same as the Last-Event-ID header"
+// @Param        units          query    string  false  "metric (default), imperial or standard"
+// @Param        lang           query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {object}  weatherClient.CityUpdate
+// @Router       /stream [get]
+func (h *handler) StreamUpdates(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("STREAM WEATHER UPDATES")
+
+	names, topics, err := h.streamTopics(r)
+	if err != nil {
+		return err
+	}
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		return err
+	}
+	if lastID > h.broker.LastID() {
+		// the id was issued before a restart of the service
+		lastID = 0
+	}
+
+	u, err := h.requestUnits(r)
+	if err != nil {
+		return err
+	}
+	lang := i18n.FromContext(r.Context())
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming is not supported by the connection")
+	}
+
+	// the stream outlives the server write timeout
+	if err = http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warnf("failed to clear write deadline. error: %v", err)
+	}
+
+	sub := h.broker.Subscribe(topics...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+
+	write := func(e events.Event) error {
+		update, ok := e.Payload.(weatherClient.CityUpdate)
+		if !ok {
+			return nil
+		}
+		update = update.Present(u, lang)
+		update.Name = names[update.CityID]
+
+		data, err := json.Marshal(update)
+		if err != nil {
+			return fmt.Errorf("failed to marshall city update. error: %w", err)
+		}
+		if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+			return err
+		}
+		lastID = e.ID
+		return nil
+	}
+
+	if lastID > 0 {
+		missed, complete := h.broker.Replay(lastID, topics...)
+		if !complete {
+			logger.Debugf("events after %d are no longer kept, replaying %d", lastID, len(missed))
+		}
+		for _, e := range missed {
+			if err = write(e); err != nil {
+				logger.Debugf("stream closed. error: %v", err)
+				return nil
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case e, ok := <-sub.C():
+			if !ok {
+				// dropped for lagging behind, the client resumes from lastID
+				logger.Warn("stream subscriber lagged behind and was dropped")
+				return nil
+			}
+			if e.ID <= lastID {
+				continue
+			}
+			if err = write(e); err != nil {
+				logger.Debugf("stream closed. error: %v", err)
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// streamTopics resolves ?cities= and ?city= to event topics and names by city
+// id. cities is split on commas, so "name,country" only works in city.
+func (h *handler) streamTopics(r *http.Request) (map[string]string, []string, error) {
+	names := make(map[string]string)
+
+	var queries []string
+	if cities := r.URL.Query().Get("cities"); cities != "" {
+		queries = strings.Split(cities, ",")
+	}
+	queries = append(queries, r.URL.Query()["city"]...)
+	if len(queries) == 0 {
+		cities, err := h.cityService.FindAll(r.Context())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, city := range cities {
+			names[city.Id] = city.Name
+		}
+		return names, []string{weatherClient.AllCitiesTopic}, nil
+	}
+
+	topics := make([]string, 0)
+	for _, query := range queries {
+		city, err := h.cityService.Resolve(r.Context(), query)
+		if err != nil {
+			return nil, nil, err
+		}
+		names[city.Id] = city.Name
+		topics = append(topics, weatherClient.CityTopic(city.Id))
+	}
+
+	return names, topics, nil
+}
+
+func lastEventID(r *http.Request) (uint64, error) {
+	s := r.Header.Get(lastEventIDHeader)
+	if s == "" {
+		s = r.URL.Query().Get("last_event_id")
+	}
+	if s == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, apperror.NewAppError(err, "invalid last event id", "expected a positive integer", "WeatherService-000004")
+	}
+	return id, nil
+}
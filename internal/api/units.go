@@ -0,0 +1,30 @@
+package api
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/units"
+	"net/http"
+)
+
+// requestUnits reads the unit system from ?units= and ?pressure=. Without
+// ?units= the default of the user given by ?user= (uuid) is used, otherwise
+// metric.
+func (h *handler) requestUnits(r *http.Request) (units.Units, error) {
+	system := r.URL.Query().Get("units")
+	if system == "" {
+		if userUUID := r.URL.Query().Get("user"); userUUID != "" {
+			u, err := h.userService.GetOne(r.Context(), userUUID)
+			if err != nil {
+				return units.Units{}, err
+			}
+			system = u.Units
+		}
+	}
+
+	u, err := units.Parse(system, r.URL.Query().Get("pressure"))
+	if err != nil {
+		return u, apperror.NewAppError(err, err.Error(), "", "WeatherService-000004")
+	}
+
+	return u, nil
+}
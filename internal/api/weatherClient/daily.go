@@ -0,0 +1,98 @@
+package weatherClient
+
+import "time"
+
+// DailyForecast summarises the forecast slots of one UTC day.
+type DailyForecast struct {
+	Date        time.Time `json:"date"`
+	TempMin     float64   `json:"temp_min"`
+	TempMax     float64   `json:"temp_max"`
+	Pop         float64   `json:"pop"`
+	Humidity    int       `json:"humidity"`
+	WindSpeed   float64   `json:"wind_speed"`
+	ConditionID int       `json:"condition_id"`
+	Condition   string    `json:"condition"`
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+}
+
+// Daily groups chronologically ordered slots by UTC day. The condition of a
+// day is the most frequent one among its daytime slots, or among all slots
+// when there are no daytime ones; the wind is the strongest of the day.
+func Daily(forecast []Forecast) []DailyForecast {
+	days := make([]DailyForecast, 0)
+
+	for start := 0; start < len(forecast); {
+		date := truncateDay(forecast[start].Time())
+		end := start
+		for end < len(forecast) && truncateDay(forecast[end].Time()).Equal(date) {
+			end++
+		}
+		days = append(days, summariseDay(date, forecast[start:end]))
+		start = end
+	}
+
+	return days
+}
+
+// Summary summarises chronologically ordered slots of any period the same way
+// as a day of Daily. Date is the start of the first slot. The slots must not
+// be empty.
+func Summary(slots []Forecast) DailyForecast {
+	return summariseDay(slots[0].Time(), slots)
+}
+
+func summariseDay(date time.Time, slots []Forecast) DailyForecast {
+	day := DailyForecast{
+		Date:    date,
+		TempMin: slots[0].Main.TempMin,
+		TempMax: slots[0].Main.TempMax,
+	}
+
+	humidity := 0
+	for _, slot := range slots {
+		if slot.Main.TempMin < day.TempMin {
+			day.TempMin = slot.Main.TempMin
+		}
+		if slot.Main.TempMax > day.TempMax {
+			day.TempMax = slot.Main.TempMax
+		}
+		if slot.Pop > day.Pop {
+			day.Pop = slot.Pop
+		}
+		if slot.Wind.Speed > day.WindSpeed {
+			day.WindSpeed = slot.Wind.Speed
+		}
+		humidity += slot.Main.Humidity
+	}
+	day.Humidity = humidity / len(slots)
+
+	counts := make(map[int]int)
+	best := -1
+	for _, daytime := range []bool{true, false} {
+		for _, slot := range slots {
+			if daytime && slot.Sys.Pod != "d" || len(slot.Weather) == 0 {
+				continue
+			}
+			condition := slot.Weather[0]
+			counts[condition.ID]++
+			if counts[condition.ID] > best {
+				best = counts[condition.ID]
+				day.ConditionID = condition.ID
+				day.Condition = condition.Main
+				day.Description = condition.Description
+				day.Icon = condition.Icon
+			}
+		}
+		if best > 0 {
+			break
+		}
+	}
+
+	return day
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
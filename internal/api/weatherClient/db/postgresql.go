@@ -2,13 +2,16 @@ package weather
 
 import (
 	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
 	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/geo"
 	"WeatherServiceAPI/pkg/logging"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"time"
 )
 
@@ -17,15 +20,18 @@ type db struct {
 	logger *logging.Logger
 }
 
-func (d db) FindInfoByCityAndDate(ctx context.Context, city string, date time.Time) (weatherDataJson string, err error) {
-	q := `SELECT w.data_json FROM weather as w join cities c on c.id = w.city_id where c.name = $1 AND w.date = $2;`
+func (d db) FindInfoByCityAndDate(ctx context.Context, cityID string, date time.Time) (weatherDataJson string, err error) {
+	q := `SELECT w.data_json FROM weather as w where w.city_id = $1 AND w.date = $2;`
 
 	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
 
-	rows := d.client.QueryRow(ctx, q, city, date.Format("2006-01-02 15:04:05"))
+	rows := d.client.QueryRow(ctx, q, cityID, date.Format("2006-01-02 15:04:05"))
 
 	err = rows.Scan(&weatherDataJson)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return weatherDataJson, apperror.ErrNotFound
+		}
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
@@ -39,21 +45,145 @@ func (d db) FindInfoByCityAndDate(ctx context.Context, city string, date time.Ti
 	return weatherDataJson, nil
 }
 
-func (d db) FindBriefInfo(ctx context.Context, city string) (wthr weatherClient.BriefWeatherCity, err error) {
-	q := `SELECT c.country, c.name, AVG(w.temp), ARRAY(select innerW.date from weather as innerW where innerW.city_id = w.city_id order by innerW.date) FROM weather as w join cities c on c.id = w.city_id group by c.name, c.country, w.city_id having c.name = $1;`
+func (d db) FindBriefInfo(ctx context.Context, cityID string) (wthr weatherClient.BriefWeatherCity, err error) {
+	q := `SELECT c.country, c.name, AVG(w.temp), ARRAY(select innerW.date from weather as innerW where innerW.city_id = w.city_id order by innerW.date) FROM weather as w join cities c on c.id = w.city_id group by c.name, c.country, w.city_id having w.city_id = $1;`
 
 	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
 
-	rows := d.client.QueryRow(ctx, q, city)
+	rows := d.client.QueryRow(ctx, q, cityID)
 
 	err = rows.Scan(&wthr.Country, &wthr.Name, &wthr.AvgTemp, &wthr.DateTimeArray)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return wthr, apperror.ErrNotFound
+		}
 		return wthr, err
 	}
 
 	return wthr, nil
 }
 
+func (d db) FindForecast(ctx context.Context, cityID string, from, to time.Time) ([]weatherClient.Forecast, error) {
+	q := `SELECT w.data_json FROM weather as w WHERE w.city_id = $1 AND w.date >= $2 AND w.date < $3 ORDER BY w.date;`
+
+	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, cityID, from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	forecast := make([]weatherClient.Forecast, 0)
+
+	for rows.Next() {
+		var dataJson []byte
+		if err = rows.Scan(&dataJson); err != nil {
+			return nil, err
+		}
+
+		var slot weatherClient.Forecast
+		if err = json.Unmarshal(dataJson, &slot); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal forecast. error: %w", err)
+		}
+
+		forecast = append(forecast, slot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return forecast, nil
+}
+
+func (d db) FindNearestSlots(ctx context.Context, at time.Time) (map[string]weatherClient.Forecast, error) {
+	q := `SELECT DISTINCT ON (w.city_id) w.city_id, w.data_json FROM weather as w ORDER BY w.city_id, abs(extract(epoch from w.date - $1::timestamp)), w.date;`
+
+	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, at.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := make(map[string]weatherClient.Forecast)
+
+	for rows.Next() {
+		var cityID string
+		var dataJson []byte
+		if err = rows.Scan(&cityID, &dataJson); err != nil {
+			return nil, err
+		}
+
+		var slot weatherClient.Forecast
+		if err = json.Unmarshal(dataJson, &slot); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal forecast. error: %w", err)
+		}
+
+		slots[cityID] = slot
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return slots, nil
+}
+
+func (d db) StreamForecast(ctx context.Context, cityIDs []string, from, to time.Time, fn func(cityID string, slot weatherClient.Forecast) error) error {
+	q := `SELECT w.city_id, w.data_json FROM weather as w WHERE w.city_id = ANY($1::uuid[]) AND w.date >= $2 AND w.date < $3 ORDER BY array_position($1::uuid[], w.city_id), w.date;`
+
+	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, cityIDs, from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cityID string
+		var dataJson []byte
+		if err = rows.Scan(&cityID, &dataJson); err != nil {
+			return err
+		}
+
+		var slot weatherClient.Forecast
+		if err = json.Unmarshal(dataJson, &slot); err != nil {
+			return fmt.Errorf("failed to unmarshal forecast. error: %w", err)
+		}
+
+		if err = fn(cityID, slot); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func (d db) Create(ctx context.Context, cityId string, weather weatherClient.WeatherData) error {
 	q := `INSERT INTO weather (city_id, temp, date, data_json) VALUES ($1, $2, $3, $4) ON CONFLICT (city_id, date) DO UPDATE SET city_id = excluded.city_id,temp = $2, data_json = $4;`
 
@@ -79,6 +209,79 @@ func (d db) Create(ctx context.Context, cityId string, weather weatherClient.Wea
 	return nil
 }
 
+func (d db) FindCellForecast(ctx context.Context, cell geo.Cell, fetchedAfter time.Time) (data weatherClient.WeatherData, fetchedAt time.Time, err error) {
+	q := `SELECT data_json, fetched_at FROM coordinate_weather WHERE precision = $1 AND lat_key = $2 AND lon_key = $3 AND fetched_at > $4;`
+
+	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
+
+	var dataJson []byte
+	err = d.client.QueryRow(ctx, q, cell.Precision, cell.LatKey, cell.LonKey, fetchedAfter).Scan(&dataJson, &fetchedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return data, fetchedAt, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return data, fetchedAt, newErr
+		}
+		return data, fetchedAt, err
+	}
+
+	if err = json.Unmarshal(dataJson, &data); err != nil {
+		return data, fetchedAt, fmt.Errorf("failed to unmarshal coordinate forecast. error: %w", err)
+	}
+
+	return data, fetchedAt, nil
+}
+
+func (d db) SaveCellForecast(ctx context.Context, cell geo.Cell, data weatherClient.WeatherData, fetchedAt time.Time) error {
+	q := `INSERT INTO coordinate_weather (precision, lat_key, lon_key, fetched_at, data_json) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (precision, lat_key, lon_key) DO UPDATE SET fetched_at = excluded.fetched_at, data_json = excluded.data_json;`
+
+	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.Exec(ctx, q, cell.Precision, cell.LatKey, cell.LonKey, fetchedAt, bytes)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) DeleteCellForecasts(ctx context.Context, fetchedBefore time.Time) error {
+	q := `DELETE FROM coordinate_weather WHERE fetched_at < $1;`
+
+	d.logger.Debug(fmt.Sprintf("SQL Query: %s", q))
+
+	_, err := d.client.Exec(ctx, q, fetchedBefore)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
 func NewStorage(client postgresql.Client, logger *logging.Logger) weatherClient.Storage {
 	return &db{
 		client: client,
@@ -1,49 +1,18 @@
 package weatherClient
 
-import "time"
+import (
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/units"
+	"math"
+	"time"
+)
 
 type WeatherData struct {
-	Cod     string `json:"cod"`
-	Message int    `json:"message"`
-	Cnt     int    `json:"cnt"`
-	List    []struct {
-		Dt   int `json:"dt"`
-		Main struct {
-			Temp      float64 `json:"temp"`
-			FeelsLike float64 `json:"feels_like"`
-			TempMin   float64 `json:"temp_min"`
-			TempMax   float64 `json:"temp_max"`
-			Pressure  int     `json:"pressure"`
-			SeaLevel  int     `json:"sea_level"`
-			GrndLevel int     `json:"grnd_level"`
-			Humidity  int     `json:"humidity"`
-			TempKf    float64 `json:"temp_kf"`
-		} `json:"main"`
-		Weather []struct {
-			ID          int    `json:"id"`
-			Main        string `json:"main"`
-			Description string `json:"description"`
-			Icon        string `json:"icon"`
-		} `json:"weather"`
-		Clouds struct {
-			All int `json:"all"`
-		} `json:"clouds"`
-		Wind struct {
-			Speed float64 `json:"speed"`
-			Deg   int     `json:"deg"`
-			Gust  float64 `json:"gust"`
-		} `json:"wind"`
-		Visibility int     `json:"visibility"`
-		Pop        float64 `json:"pop"`
-		Sys        struct {
-			Pod string `json:"pod"`
-		} `json:"sys"`
-		DtTxt string `json:"dt_txt"`
-		Rain  struct {
-			ThreeH float64 `json:"3h"`
-		} `json:"rain,omitempty"`
-	} `json:"list"`
-	City struct {
+	Cod     string     `json:"cod"`
+	Message int        `json:"message"`
+	Cnt     int        `json:"cnt"`
+	List    []Forecast `json:"list"`
+	City    struct {
 		ID    int    `json:"id"`
 		Name  string `json:"name"`
 		Coord struct {
@@ -58,9 +27,181 @@ type WeatherData struct {
 	} `json:"cityClient"`
 }
 
+// Forecast is a single 3-hour forecast slot. It is stored as is in
+// weather.data_json.
+type Forecast struct {
+	Dt   int `json:"dt"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Pressure  float64 `json:"pressure"`
+		SeaLevel  float64 `json:"sea_level"`
+		GrndLevel float64 `json:"grnd_level"`
+		Humidity  int     `json:"humidity"`
+		TempKf    float64 `json:"temp_kf"`
+	} `json:"main"`
+	Weather []struct {
+		ID          int    `json:"id"`
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	} `json:"weather"`
+	Clouds struct {
+		All int `json:"all"`
+	} `json:"clouds"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+		Gust  float64 `json:"gust"`
+	} `json:"wind"`
+	Visibility float64 `json:"visibility"`
+	Pop        float64 `json:"pop"`
+	Sys        struct {
+		Pod string `json:"pod"`
+	} `json:"sys"`
+	DtTxt string `json:"dt_txt"`
+	Rain  struct {
+		ThreeH float64 `json:"3h"`
+	} `json:"rain,omitempty"`
+	Snow struct {
+		ThreeH float64 `json:"3h"`
+	} `json:"snow,omitempty"`
+	Units *units.Labels `json:"units,omitempty"`
+}
+
+// Time returns the start of the forecast slot.
+func (f Forecast) Time() time.Time {
+	return time.Unix(int64(f.Dt), 0).UTC()
+}
+
+// ConvertUnits returns the slot with all quantities converted from metric.
+func (f Forecast) ConvertUnits(u units.Units) Forecast {
+	f.Main.Temp = u.Temperature(f.Main.Temp)
+	f.Main.FeelsLike = u.Temperature(f.Main.FeelsLike)
+	f.Main.TempMin = u.Temperature(f.Main.TempMin)
+	f.Main.TempMax = u.Temperature(f.Main.TempMax)
+	f.Main.Pressure = u.Pressure(f.Main.Pressure)
+	f.Main.SeaLevel = u.Pressure(f.Main.SeaLevel)
+	f.Main.GrndLevel = u.Pressure(f.Main.GrndLevel)
+	f.Wind.Speed = u.WindSpeed(f.Wind.Speed)
+	f.Wind.Gust = u.WindSpeed(f.Wind.Gust)
+	f.Visibility = u.Visibility(f.Visibility)
+	f.Rain.ThreeH = u.Precipitation(f.Rain.ThreeH)
+	f.Snow.ThreeH = u.Precipitation(f.Snow.ThreeH)
+
+	return f
+}
+
+// ConvertForecastUnits converts every slot of the forecast.
+func ConvertForecastUnits(forecast []Forecast, u units.Units) []Forecast {
+	converted := make([]Forecast, len(forecast))
+	for i, f := range forecast {
+		converted[i] = f.ConvertUnits(u)
+	}
+	return converted
+}
+
+// Localize returns the slot with condition descriptions translated to lang.
+func (f Forecast) Localize(lang string) Forecast {
+	weather := f.Weather
+	f.Weather = append(weather[:0:0], weather...)
+	for i, w := range f.Weather {
+		f.Weather[i].Description = i18n.Condition(lang, w.ID, w.Description)
+	}
+
+	return f
+}
+
+// LocalizeForecast translates every slot of the forecast.
+func LocalizeForecast(forecast []Forecast, lang string) []Forecast {
+	localized := make([]Forecast, len(forecast))
+	for i, f := range forecast {
+		localized[i] = f.Localize(lang)
+	}
+	return localized
+}
+
 type BriefWeatherCity struct {
-	Country       string      `json:"country"`
-	Name          string      `json:"name"`
-	AvgTemp       float64     `json:"avg_temp"`
-	DateTimeArray []time.Time `json:"date_time_array"`
+	Country       string        `json:"country"`
+	Name          string        `json:"name"`
+	AvgTemp       float64       `json:"avg_temp"`
+	DateTimeArray []time.Time   `json:"date_time_array"`
+	Units         *units.Labels `json:"units,omitempty"`
+}
+
+// CoordinateWeather is the forecast for an arbitrary point, fetched on demand
+// and shared by all points in the same grid cell.
+type CoordinateWeather struct {
+	Lat       float64       `json:"lat"`
+	Lon       float64       `json:"lon"`
+	FetchedAt time.Time     `json:"fetched_at"`
+	Cached    bool          `json:"cached"`
+	Units     *units.Labels `json:"units,omitempty"`
+	Forecast  []Forecast    `json:"forecast"`
+}
+
+const (
+	// CityUpdateEvent is the type of events published after the refresh
+	// stored new data for a city.
+	CityUpdateEvent = "forecast"
+
+	cityTopicPrefix = "city:"
+)
+
+// CityTopic is the events topic of a city.
+func CityTopic(cityID string) string {
+	return cityTopicPrefix + cityID
+}
+
+// AllCitiesTopic matches the topics of all cities.
+const AllCitiesTopic = cityTopicPrefix + "*"
+
+// CityUpdate is the payload of CityUpdateEvent: a brief view of the fresh
+// forecast and its current slot.
+type CityUpdate struct {
+	CityID        string        `json:"city_id"`
+	Name          string        `json:"name,omitempty"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	AvgTemp       float64       `json:"avg_temp"`
+	DateTimeArray []time.Time   `json:"date_time_array"`
+	Current       *Forecast     `json:"current,omitempty"`
+	Units         *units.Labels `json:"units,omitempty"`
+}
+
+// NewCityUpdate summarises data fetched for the city at updatedAt.
+func NewCityUpdate(cityID string, data WeatherData, updatedAt time.Time) CityUpdate {
+	update := CityUpdate{
+		CityID:        cityID,
+		UpdatedAt:     updatedAt.UTC(),
+		DateTimeArray: make([]time.Time, 0, len(data.List)),
+	}
+
+	sum := 0.0
+	for i, slot := range data.List {
+		sum += slot.Main.Temp
+		update.DateTimeArray = append(update.DateTimeArray, slot.Time())
+		if update.Current == nil && !slot.Time().Add(3*time.Hour).Before(updatedAt) {
+			update.Current = &data.List[i]
+		}
+	}
+	if len(data.List) > 0 {
+		update.AvgTemp = math.Round(sum/float64(len(data.List))*100) / 100
+	}
+
+	return update
+}
+
+// Present returns the update converted to u and translated to lang.
+func (c CityUpdate) Present(u units.Units, lang string) CityUpdate {
+	c.AvgTemp = u.Temperature(c.AvgTemp)
+	if c.Current != nil {
+		current := c.Current.ConvertUnits(u).Localize(lang)
+		c.Current = &current
+	}
+	labels := u.Labels()
+	c.Units = &labels
+
+	return c
 }
@@ -1,37 +1,164 @@
 package weatherClient
 
 import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/geo"
 	"WeatherServiceAPI/pkg/logging"
 	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"sync"
 	"time"
 )
 
+// Listener is called after forecast data of a city has been stored.
+type Listener func(ctx context.Context, cityID string, data WeatherData)
+
 type service struct {
 	storage Storage
 	logger  *logging.Logger
+
+	fetcher       Fetcher
+	gridPrecision int
+	gridTTL       time.Duration
+	fetches       singleflight.Group
+
+	mu        sync.RWMutex
+	listeners []Listener
+}
+
+func (s *service) FindInfoByCityAndDate(ctx context.Context, cityID string, date time.Time) (weatherDataJson string, err error) {
+	return s.storage.FindInfoByCityAndDate(ctx, cityID, date)
+}
+
+func (s *service) FindBriefInfo(ctx context.Context, cityID string) (BriefWeatherCity, error) {
+	return s.storage.FindBriefInfo(ctx, cityID)
+}
+
+// FindForecast returns the forecast slots of the city starting in [from, to)
+// in chronological order.
+func (s *service) FindForecast(ctx context.Context, cityID string, from, to time.Time) ([]Forecast, error) {
+	return s.storage.FindForecast(ctx, cityID, from, to)
+}
+
+// FindNearestSlots returns, for every city with a forecast, the slot closest to
+// at, keyed by city id.
+func (s *service) FindNearestSlots(ctx context.Context, at time.Time) (map[string]Forecast, error) {
+	return s.storage.FindNearestSlots(ctx, at)
+}
+
+// StreamForecast calls fn for every forecast slot of the cities starting in
+// [from, to), city by city in the given order and chronologically within a
+// city. Rows are read from the database one at a time, so the result set is
+// never held in memory. Iteration stops at the first error returned by fn.
+func (s *service) StreamForecast(ctx context.Context, cityIDs []string, from, to time.Time, fn func(cityID string, slot Forecast) error) error {
+	return s.storage.StreamForecast(ctx, cityIDs, from, to, fn)
 }
 
-func (s service) FindInfoByCityAndDate(ctx context.Context, city string, date time.Time) (weatherDataJson string, err error) {
-	return s.storage.FindInfoByCityAndDate(ctx, city, date)
+// FindByCoordinates returns the forecast for an arbitrary point. Points are
+// snapped to a grid; the forecast of a grid cell is fetched from OpenWeather
+// once and served from the database until it is older than the grid TTL.
+func (s *service) FindByCoordinates(ctx context.Context, lat, lon float64) (CoordinateWeather, error) {
+	logger := logging.FromContext(ctx)
+
+	cell := geo.NewCell(lat, lon, s.gridPrecision)
+	var result CoordinateWeather
+	result.Lat, result.Lon = cell.Center()
+
+	data, fetchedAt, err := s.storage.FindCellForecast(ctx, cell, time.Now().Add(-s.gridTTL))
+	if err == nil {
+		logger.Debug("serve coordinate forecast from cache")
+		result.Cached = true
+		result.FetchedAt = fetchedAt
+		result.Forecast = data.List
+		return result, nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return result, err
+	}
+
+	logger.Debug("fetch coordinate forecast")
+	v, err, _ := s.fetches.Do(cell.String(), func() (interface{}, error) {
+		// the fetch is shared with concurrent requests for the same cell, so it
+		// must not be cancelled together with the request that started it
+		ctx, cancel := context.WithTimeout(logging.ContextWithLogger(context.Background(), logger), time.Minute)
+		defer cancel()
+
+		data, err := s.fetcher.FetchForecast(ctx, result.Lat, result.Lon)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get weather for coordinates. error: %w", err)
+		}
+
+		fetchedAt := time.Now()
+		if err = s.storage.SaveCellForecast(ctx, cell, data, fetchedAt); err != nil {
+			return nil, err
+		}
+		if err = s.storage.DeleteCellForecasts(ctx, fetchedAt.Add(-s.gridTTL)); err != nil {
+			logger.Warnf("failed to delete expired coordinate forecasts. error: %v", err)
+		}
+
+		return CoordinateWeather{FetchedAt: fetchedAt, Forecast: data.List}, nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	fetched := v.(CoordinateWeather)
+	result.FetchedAt = fetched.FetchedAt
+	result.Forecast = fetched.Forecast
+
+	return result, nil
 }
 
-func (s service) FindBriefInfo(ctx context.Context, city string) (BriefWeatherCity, error) {
-	return s.storage.FindBriefInfo(ctx, city)
+func (s *service) Create(ctx context.Context, cityID string, data WeatherData) error {
+	err := s.storage.Create(ctx, cityID, data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+
+	for _, l := range listeners {
+		l(ctx, cityID, data)
+	}
+
+	return nil
 }
 
-func (s service) Create(ctx context.Context, cityID string, data WeatherData) error {
-	return s.storage.Create(ctx, cityID, data)
+func (s *service) Subscribe(l Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, l)
 }
 
-func NewService(storage Storage, logger *logging.Logger) (Service, error) {
+// NewService creates the weather service. fetcher is used for points that are
+// not tracked cities; their forecasts are cached per grid cell of gridPrecision
+// decimal places for gridTTL.
+func NewService(storage Storage, logger *logging.Logger, fetcher Fetcher, gridPrecision int, gridTTL time.Duration) (Service, error) {
+	if gridPrecision < 0 || gridPrecision > 4 {
+		return nil, fmt.Errorf("invalid coordinates precision %d. expected 0 to 4 decimal places", gridPrecision)
+	}
+
 	return &service{
-		storage: storage,
-		logger:  logger,
+		storage:       storage,
+		logger:        logger,
+		fetcher:       fetcher,
+		gridPrecision: gridPrecision,
+		gridTTL:       gridTTL,
 	}, nil
 }
 
 type Service interface {
 	Create(ctx context.Context, cityID string, data WeatherData) error
-	FindBriefInfo(ctx context.Context, city string) (BriefWeatherCity, error)
-	FindInfoByCityAndDate(ctx context.Context, city string, date time.Time) (weatherDataJson string, err error)
+	FindBriefInfo(ctx context.Context, cityID string) (BriefWeatherCity, error)
+	FindInfoByCityAndDate(ctx context.Context, cityID string, date time.Time) (weatherDataJson string, err error)
+	FindForecast(ctx context.Context, cityID string, from, to time.Time) ([]Forecast, error)
+	FindNearestSlots(ctx context.Context, at time.Time) (map[string]Forecast, error)
+	StreamForecast(ctx context.Context, cityIDs []string, from, to time.Time, fn func(cityID string, slot Forecast) error) error
+	FindByCoordinates(ctx context.Context, lat, lon float64) (CoordinateWeather, error)
+	Subscribe(l Listener)
 }
@@ -1,12 +1,20 @@
 package weatherClient
 
 import (
+	"WeatherServiceAPI/pkg/geo"
 	"context"
 	"time"
 )
 
 type Storage interface {
 	Create(ctx context.Context, cityID string, data WeatherData) error
-	FindBriefInfo(ctx context.Context, city string) (BriefWeatherCity, error)
-	FindInfoByCityAndDate(ctx context.Context, city string, date time.Time) (weatherDataJson string, err error)
+	FindBriefInfo(ctx context.Context, cityID string) (BriefWeatherCity, error)
+	FindInfoByCityAndDate(ctx context.Context, cityID string, date time.Time) (weatherDataJson string, err error)
+	FindForecast(ctx context.Context, cityID string, from, to time.Time) ([]Forecast, error)
+	FindNearestSlots(ctx context.Context, at time.Time) (map[string]Forecast, error)
+	StreamForecast(ctx context.Context, cityIDs []string, from, to time.Time, fn func(cityID string, slot Forecast) error) error
+
+	FindCellForecast(ctx context.Context, cell geo.Cell, fetchedAfter time.Time) (data WeatherData, fetchedAt time.Time, err error)
+	SaveCellForecast(ctx context.Context, cell geo.Cell, data WeatherData, fetchedAt time.Time) error
+	DeleteCellForecasts(ctx context.Context, fetchedBefore time.Time) error
 }
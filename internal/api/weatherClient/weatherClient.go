@@ -8,23 +8,40 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
+// RefreshObserver is told about the outcome of the refresh of every city.
+type RefreshObserver interface {
+	RefreshSucceeded(city cityClient.CityData, at time.Time)
+	RefreshFailed(city cityClient.CityData, err error)
+}
+
+// Fetcher gets the 5 day forecast for a point from OpenWeather.
+type Fetcher interface {
+	FetchForecast(ctx context.Context, lat, lon float64) (WeatherData, error)
+}
+
 type client struct {
-	logger *logging.Logger
-	cfg    config.Config
+	logger     *logging.Logger
+	cfg        config.Config
+	httpClient *http.Client
+	observer   RefreshObserver
 }
 
-func NewClient(logger *logging.Logger, cfg config.Config) *client {
+func NewClient(logger *logging.Logger, cfg config.Config, observer RefreshObserver) *client {
 	return &client{
-		logger: logger,
-		cfg:    cfg,
+		logger:     logger,
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		observer:   observer,
 	}
 }
 
 type cwStruct struct {
-	cityId  string
+	city    cityClient.CityData
 	weather WeatherData
+	err     error
 }
 
 func (c *client) RefreshWeatherDataAsync(cities []cityClient.CityData, wService Service) error {
@@ -33,42 +50,65 @@ func (c *client) RefreshWeatherDataAsync(cities []cityClient.CityData, wService
 	for _, city := range cities {
 		city := city
 		go func() {
-			var weather WeatherData
-			url := fmt.Sprintf("https://api.openweathermap.org/data/2.5/forecast?lat=%f&lon=%f&appid=%s&units=metric", city.Lat, city.Lon, c.cfg.ApiID)
-
-			r, err := http.Get(url)
-			if err != nil {
-				c.logger.Fatal(err)
-			}
-			defer r.Body.Close()
-
-			err = json.NewDecoder(r.Body).Decode(&weather)
-			if err != nil {
-				c.logger.Fatalf("%v", err)
-			}
+			weather, err := c.FetchForecast(context.TODO(), city.Lat, city.Lon)
 
 			cwChan <- cwStruct{
-				cityId:  city.Id,
+				city:    city,
 				weather: weather,
+				err:     err,
 			}
 		}()
 	}
 
-	counter := 0
-	for cw := range cwChan {
-		counter++
+	failed := 0
+	for range cities {
+		cw := <-cwChan
 
-		if counter == len(cities) {
-			close(cwChan)
+		if cw.err != nil {
+			failed++
+			c.logger.Errorf("failed to get weather for city %s. due to error: %v", cw.city.Name, cw.err)
+			c.observer.RefreshFailed(cw.city, cw.err)
+			continue
 		}
 
-		err := wService.Create(context.TODO(), cw.cityId, cw.weather)
+		err := wService.Create(context.TODO(), cw.city.Id, cw.weather)
 		if err != nil {
 			return err
 		}
+		c.observer.RefreshSucceeded(cw.city, time.Now())
+	}
+
+	if failed > 0 && failed == len(cities) {
+		return fmt.Errorf("failed to get weather for all %d cities", failed)
 	}
 
 	c.logger.Info("weather data refreshed")
 
 	return nil
 }
+
+func (c *client) FetchForecast(ctx context.Context, lat, lon float64) (weather WeatherData, err error) {
+	url := fmt.Sprintf("https://api.openweathermap.org/data/2.5/forecast?lat=%f&lon=%f&appid=%s&units=metric", lat, lon, c.cfg.ApiID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return weather, err
+	}
+
+	r, err := c.httpClient.Do(req)
+	if err != nil {
+		return weather, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return weather, fmt.Errorf("unexpected response status %s", r.Status)
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&weather)
+	if err != nil {
+		return weather, fmt.Errorf("failed to decode weather data. error: %w", err)
+	}
+
+	return weather, nil
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"WeatherServiceAPI/internal/apikey"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+var _ apikey.Storage = &db{}
+
+const keyColumns = `id, user_id, name, prefix, key_hash, scopes, rate_limit, request_count, last_used_at, created_at, revoked_at`
+
+type db struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func (d db) Create(ctx context.Context, key apikey.Key) (string, error) {
+	q := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, rate_limit, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err := d.client.QueryRow(ctx, q, key.UserUUID, key.Name, key.Prefix, key.Hash, scopeStrings(key.Scopes), key.RateLimit, key.CreatedAt).Scan(&key.ID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return "", newErr
+		}
+		return "", err
+	}
+
+	return key.ID, nil
+}
+
+func (d db) FindByUser(ctx context.Context, userUUID string) ([]apikey.Key, error) {
+	q := `SELECT ` + keyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, userUUID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]apikey.Key, 0)
+
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (d db) FindByPrefix(ctx context.Context, prefix string) (apikey.Key, error) {
+	q := `SELECT ` + keyColumns + ` FROM api_keys WHERE prefix = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	key, err := scanKey(d.client.QueryRow(ctx, q, prefix))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return key, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return key, newErr
+		}
+		return key, err
+	}
+
+	return key, nil
+}
+
+func (d db) Revoke(ctx context.Context, userUUID, id string, at time.Time) error {
+	q := `UPDATE api_keys SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	tag, err := d.client.Exec(ctx, q, id, userUUID, at)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (d db) AddUsage(ctx context.Context, id string, day time.Time, requests int64, lastUsedAt time.Time) error {
+	q := `WITH counted AS (
+    UPDATE api_keys SET request_count = request_count + $3, last_used_at = GREATEST(last_used_at, $4)
+    WHERE id = $1 RETURNING id
+)
+INSERT INTO api_key_usage (key_id, day, requests) SELECT id, $2::date, $3 FROM counted
+ON CONFLICT (key_id, day) DO UPDATE SET requests = api_key_usage.requests + EXCLUDED.requests;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	_, err := d.client.Exec(ctx, q, id, day, requests, lastUsedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) FindUsage(ctx context.Context, userUUID, id string, since time.Time) ([]apikey.Usage, error) {
+	q := `SELECT u.day, u.requests FROM api_key_usage u JOIN api_keys k ON k.id = u.key_id
+WHERE k.id = $1 AND k.user_id = $2 AND u.day >= $3::date ORDER BY u.day;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, id, userUUID, since)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make([]apikey.Usage, 0)
+
+	for rows.Next() {
+		var u apikey.Usage
+		if err = rows.Scan(&u.Day, &u.Requests); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+func scanKey(row pgx.Row) (apikey.Key, error) {
+	var key apikey.Key
+	var scopes []string
+	err := row.Scan(&key.ID, &key.UserUUID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.RateLimit, &key.RequestCount, &key.LastUsedAt, &key.CreatedAt, &key.RevokedAt)
+	for _, scope := range scopes {
+		key.Scopes = append(key.Scopes, apikey.Scope(scope))
+	}
+	return key, err
+}
+
+func scopeStrings(scopes []apikey.Scope) []string {
+	strs := make([]string, len(scopes))
+	for i, scope := range scopes {
+		strs[i] = string(scope)
+	}
+	return strs
+}
+
+func NewStorage(client postgresql.Client, logger *logging.Logger) apikey.Storage {
+	return &db{
+		client: client,
+		logger: logger,
+	}
+}
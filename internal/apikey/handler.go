@@ -0,0 +1,208 @@
+package apikey
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	keysURL  = "/api/users/:uuid/keys"
+	keyURL   = "/api/users/:uuid/keys/:id"
+	usageURL = "/api/users/:uuid/keys/:id/usage"
+)
+
+type handler struct {
+	Logger        *logging.Logger
+	APIKeyService Service
+	Limiter       *ratelimit.Limiter
+}
+
+func NewHandler(logger *logging.Logger, apiKeyService Service, limiter *ratelimit.Limiter) handlers.Handler {
+	return &handler{
+		Logger:        logger,
+		APIKeyService: apiKeyService,
+		Limiter:       limiter,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, keysURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.GetKeys)))
+	router.HandlerFunc(http.MethodPost, keysURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.CreateKey)))
+	router.HandlerFunc(http.MethodDelete, keyURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.RevokeKey)))
+	router.HandlerFunc(http.MethodGet, usageURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.GetUsage)))
+}
+
+// GetKeys godoc
+// @Summary      Get user API keys
+// @Description  API keys of the user with their usage counters, including revoked ones. Secrets are never returned
+// @Tags         API keys
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true  "User uuid"
+// @Param        password    query    string  true  "User password"
+// @Success      200  {array}  Key
+// @Router       /users/{uuid}/keys [get]
+func (h *handler) GetKeys(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER API KEYS")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("get password from URL")
+	password := r.URL.Query().Get("password")
+	if password == "" && user.APIKeyOwner(r.Context()) == "" {
+		return apperror.NewAppError(nil, "invalid query parameter password", "password is required", "WeatherService-000004")
+	}
+
+	keys, err := h.APIKeyService.FindByUser(r.Context(), params.ByName("uuid"), password)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal api keys")
+	keysBytes, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to marshall api keys. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(keysBytes)
+	return nil
+}
+
+// CreateKey godoc
+// @Summary      Create user API key
+// @Description  Create an API key for machine clients. Scopes: weather:read (read-only weather endpoints), users:admin (the user API of the key owner without the password, except for email and password changes and new keys). rate_limit is requests per minute. The key is only returned in this response
+// @Tags         API keys
+// @Accept       json
+// @Produce      json
+// @Param        uuid    path     string  true  "User uuid"
+// @Param        dto     body     CreateKeyDTO  true  "API key"
+// @Success      201  {object}  CreatedKey
+// @Router       /users/{uuid}/keys [post]
+func (h *handler) CreateKey(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("CREATE USER API KEY")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("decode create api key dto")
+	var dto CreateKeyDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme. check swagger API")
+	}
+	dto.UUID = params.ByName("uuid")
+
+	key, err := h.APIKeyService.Create(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal api key")
+	keyBytes, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshall api key. error: %w", err)
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%s/keys/%s", key.UserUUID, key.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(keyBytes)
+	return nil
+}
+
+// RevokeKey godoc
+// @Summary      Revoke user API key
+// @Description  Revoked keys stop working at once on this instance and within api_keys.cache_ttl on others. Their usage is kept
+// @Tags         API keys
+// @Accept       json
+// @Produce      json
+// @Param        uuid    path     string  true  "User uuid"
+// @Param        id      path     string  true  "API key id"
+// @Param        dto     body     RevokeKeyDTO  true  "User password"
+// @Success      204
+// @Router       /users/{uuid}/keys/{id} [delete]
+func (h *handler) RevokeKey(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("REVOKE USER API KEY")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("decode revoke api key dto")
+	var dto RevokeKeyDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil && !(errors.Is(err, io.EOF) && user.APIKeyOwner(r.Context()) != "") {
+		return fmt.Errorf("invalid JSON scheme")
+	}
+	dto.UUID = params.ByName("uuid")
+	dto.ID = params.ByName("id")
+
+	if err := h.APIKeyService.Revoke(r.Context(), dto); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// GetUsage godoc
+// @Summary      Get API key usage
+// @Description  Requests made with the key per UTC day, oldest first. Counters are stored every api_keys.flush_interval
+// @Tags         API keys
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true   "User uuid"
+// @Param        id          path     string  true   "API key id"
+// @Param        password    query    string  true   "User password"
+// @Param        days        query    int     false  "Number of days, 30 by default, at most 90"
+// @Success      200  {array}  Usage
+// @Router       /users/{uuid}/keys/{id}/usage [get]
+func (h *handler) GetUsage(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET API KEY USAGE")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("get password from URL")
+	password := r.URL.Query().Get("password")
+	if password == "" && user.APIKeyOwner(r.Context()) == "" {
+		return apperror.NewAppError(nil, "invalid query parameter password", "password is required", "WeatherService-000004")
+	}
+
+	days := 0
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 {
+			return apperror.NewAppError(err, "invalid query parameter days", "expected a positive integer", "WeatherService-000004")
+		}
+	}
+
+	usage, err := h.APIKeyService.FindUsage(r.Context(), params.ByName("uuid"), password, params.ByName("id"), days)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal api key usage")
+	usageBytes, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("failed to marshall api key usage. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(usageBytes)
+	return nil
+}
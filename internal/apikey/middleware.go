@@ -0,0 +1,119 @@
+package apikey
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+type keyContextKey struct{}
+
+// FromContext returns the API key the request was made with.
+func FromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(Key)
+	return key, ok
+}
+
+// Middleware authenticates requests made with an API key in X-API-Key or in
+// Authorization as a bearer token. Requests without a key pass through, so
+// the API stays usable with passwords; other bearer tokens, like the webhooks
+// admin token, are left to the handlers.
+//
+// The user API (/api/users, /api/userfavs) needs the users:admin scope and
+// treats the key as the password of its owner; other endpoints need
+// weather:read and are read-only. Requests over the rate limit of the key get
+// 429 with Retry-After; the key replaces the address of the client in other
+// rate limits.
+func Middleware(keyService Service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := requestKey(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := logging.FromContext(r.Context())
+		lang := i18n.FromContext(r.Context())
+
+		key, err := keyService.Verify(r.Context(), raw)
+		if errors.Is(err, ErrInvalidKey) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, apperror.NewAppError(err, "invalid api key", "the key is unknown or revoked", "WeatherService-000006"), lang)
+			return
+		}
+		if err != nil {
+			logger.Error(err)
+			writeError(w, http.StatusInternalServerError, apperror.NewAppError(err, "internal system error", err.Error(), "WeatherService-000001"), lang)
+			return
+		}
+
+		scope, ok := requiredScope(r)
+		if !ok || !key.Allows(scope) {
+			developerMessage := "api keys are not accepted for this request"
+			if ok {
+				developerMessage = "the key needs the " + string(scope) + " scope"
+			}
+			writeError(w, http.StatusForbidden, apperror.NewAppError(nil, "api key scope does not allow this request", developerMessage, "WeatherService-000007"), lang)
+			return
+		}
+
+		now := time.Now()
+		result := keyService.Allow(r.Context(), key, now)
+		w.Header().Set(ratelimit.HeaderLimit, strconv.Itoa(result.Limit))
+		w.Header().Set(ratelimit.HeaderRemaining, strconv.Itoa(result.Remaining))
+		w.Header().Set(ratelimit.HeaderReset, strconv.FormatInt(result.Reset.Unix(), 10))
+		if !result.Allowed {
+			retryAfter := result.RetryAfter(now)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, http.StatusTooManyRequests, apperror.NewAppError(nil, "rate limit exceeded", "too many requests with this api key, retry after "+strconv.Itoa(retryAfter)+"s", "WeatherService-000008"), lang)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), keyContextKey{}, key)
+		ctx = ratelimit.ContextWithClient(ctx, "key:"+key.ID)
+		ctx = logging.ContextWithLogger(ctx, logger.GetLoggerWithField("key_prefix", key.Prefix))
+		if scope == ScopeUsers {
+			ctx = user.ContextWithAPIKeyOwner(ctx, key.UserUUID)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestKey returns the API key of the request.
+func requestKey(r *http.Request) (string, bool) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return key, true
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.HasPrefix(token, keyPrefix) {
+		return token, true
+	}
+	return "", false
+}
+
+// requiredScope returns the scope a key needs for the request, or false when
+// keys cannot be used for it.
+func requiredScope(r *http.Request) (Scope, bool) {
+	if strings.HasPrefix(r.URL.Path, "/api/users") || strings.HasPrefix(r.URL.Path, "/api/userfavs") {
+		return ScopeUsers, true
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return ScopeWeather, true
+	}
+	return "", false
+}
+
+func writeError(w http.ResponseWriter, status int, err *apperror.AppError, lang string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(err.Localize(lang).Marshal())
+}
@@ -0,0 +1,108 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope is what an API key may be used for.
+type Scope string
+
+const (
+	// ScopeWeather allows reading the weather endpoints.
+	ScopeWeather Scope = "weather:read"
+	// ScopeUsers allows managing the account of the key owner through the
+	// user API without the password.
+	ScopeUsers Scope = "users:admin"
+)
+
+// keyPrefix starts every API key, so keys are told apart from other bearer
+// tokens and found by secret scanners.
+const keyPrefix = "wsk_"
+
+// Key is an API key of a user. The secret is only returned when the key is
+// created, the database keeps its SHA-256 hash. Prefix is the public part of
+// the key identifying it in listings and logs.
+type Key struct {
+	ID           string     `json:"id"`
+	UserUUID     string     `json:"user_uuid"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []Scope    `json:"scopes" swaggertype:"array,string"`
+	RateLimit    int        `json:"rate_limit"`
+	RequestCount int64      `json:"request_count"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	Hash         string     `json:"-"`
+}
+
+// Allows reports whether the key has the scope.
+func (k Key) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatedKey is a new key with its secret.
+type CreatedKey struct {
+	Key
+	Secret string `json:"key" example:"wsk_3f9a1c2b7d4e_Qm9vZ..."`
+}
+
+// Usage is the number of requests made with a key in a UTC day.
+type Usage struct {
+	Day      time.Time `json:"day"`
+	Requests int64     `json:"requests"`
+}
+
+type CreateKeyDTO struct {
+	UUID     string  `json:"uuid,omitempty"`
+	Password string  `json:"password"`
+	Name     string  `json:"name"`
+	Scopes   []Scope `json:"scopes" swaggertype:"array,string" example:"weather:read"`
+	// RateLimit is the number of requests per minute, the configured
+	// default when zero.
+	RateLimit int `json:"rate_limit,omitempty"`
+}
+
+type RevokeKeyDTO struct {
+	UUID     string `json:"uuid,omitempty"`
+	Password string `json:"password"`
+	ID       string `json:"id,omitempty"`
+}
+
+// generateKey returns a new key "wsk_<prefix>_<secret>" and its prefix.
+func generateKey() (string, string, error) {
+	b := make([]byte, 6+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key. error: %w", err)
+	}
+	prefix := hex.EncodeToString(b[:6])
+	return keyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[6:]), prefix, nil
+}
+
+// parseKey returns the prefix of a key, or false when raw is not a key.
+func parseKey(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, keyPrefix) {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(raw, keyPrefix), "_")
+	if !ok || len(prefix) != 12 || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
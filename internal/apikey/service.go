@@ -0,0 +1,366 @@
+package apikey
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxKeysPerUser limits the active keys of a user.
+	maxKeysPerUser = 20
+
+	// maxNameLength limits key names.
+	maxNameLength = 100
+
+	// usageDaysDefault and usageDaysMax bound the days of usage returned.
+	usageDaysDefault = 30
+	usageDaysMax     = 90
+
+	// rateWindow is the window of key rate limits.
+	rateWindow = time.Minute
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ErrInvalidKey is returned by Verify for unknown, malformed and revoked
+// keys.
+var ErrInvalidKey = errors.New("invalid api key")
+
+// Options configure the keys service.
+type Options struct {
+	// DefaultRateLimit is the requests per minute of keys created without
+	// a limit, MaxRateLimit the highest limit a user can set.
+	DefaultRateLimit int
+	MaxRateLimit     int
+	// CacheTTL is how long verified keys are kept in memory. Keys revoked
+	// on another instance keep working there for up to CacheTTL.
+	CacheTTL time.Duration
+}
+
+type cachedKey struct {
+	key     Key
+	expires time.Time
+}
+
+type usageKey struct {
+	id  string
+	day time.Time
+}
+
+type usage struct {
+	requests   int64
+	lastUsedAt time.Time
+}
+
+type service struct {
+	storage     Storage
+	logger      *logging.Logger
+	userService user.Service
+	limiter     *ratelimit.Limiter
+	opts        Options
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+	usage map[usageKey]*usage
+}
+
+// NewService creates the API keys service. Rate limits of keys are counted
+// by limiter.
+func NewService(storage Storage, logger *logging.Logger, userService user.Service, limiter *ratelimit.Limiter, opts Options) (Service, error) {
+	return &service{
+		storage:     storage,
+		logger:      logger,
+		userService: userService,
+		limiter:     limiter,
+		opts:        opts,
+		cache:       make(map[string]cachedKey),
+		usage:       make(map[usageKey]*usage),
+	}, nil
+}
+
+type Service interface {
+	Create(ctx context.Context, dto CreateKeyDTO) (CreatedKey, error)
+	FindByUser(ctx context.Context, userUUID, password string) ([]Key, error)
+	Revoke(ctx context.Context, dto RevokeKeyDTO) error
+	FindUsage(ctx context.Context, userUUID, password, id string, days int) ([]Usage, error)
+
+	// Verify returns the active key raw or ErrInvalidKey.
+	Verify(ctx context.Context, raw string) (Key, error)
+	// Allow counts a request made with the key against its rate limit and
+	// usage counters. Rejected requests are not counted.
+	Allow(ctx context.Context, key Key, now time.Time) ratelimit.Result
+	// Run stores the usage counters every interval until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// authenticate checks the password of the user through the user service, so
+// failed checks count towards the lockout of the account.
+func (s *service) authenticate(ctx context.Context, userUUID, password string) (user.User, error) {
+	return s.userService.Authenticate(ctx, userUUID, password, user.AuthActionAPIKeys)
+}
+
+func (s *service) Create(ctx context.Context, dto CreateKeyDTO) (CreatedKey, error) {
+	logger := logging.FromContext(ctx)
+
+	name := strings.TrimSpace(dto.Name)
+	if len(name) > maxNameLength {
+		return CreatedKey{}, apperror.NewAppError(nil, "invalid api key name", fmt.Sprintf("name must be at most %d characters", maxNameLength), "WeatherService-000004")
+	}
+	if len(dto.Scopes) == 0 {
+		return CreatedKey{}, apperror.NewAppError(nil, "invalid api key scopes", "at least one scope is required: weather:read or users:admin", "WeatherService-000004")
+	}
+	seen := make(map[Scope]bool, len(dto.Scopes))
+	for _, scope := range dto.Scopes {
+		if scope != ScopeWeather && scope != ScopeUsers {
+			return CreatedKey{}, apperror.NewAppError(nil, "invalid api key scopes", fmt.Sprintf("unknown scope %q. expected: weather:read or users:admin", scope), "WeatherService-000004")
+		}
+		seen[scope] = true
+	}
+	scopes := make([]Scope, 0, len(seen))
+	for _, scope := range []Scope{ScopeWeather, ScopeUsers} {
+		if seen[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	rateLimit := dto.RateLimit
+	if rateLimit == 0 {
+		rateLimit = s.opts.DefaultRateLimit
+	}
+	if rateLimit < 1 || rateLimit > s.opts.MaxRateLimit {
+		return CreatedKey{}, apperror.NewAppError(nil, "invalid api key rate limit", fmt.Sprintf("rate_limit must be from 1 to %d requests per minute", s.opts.MaxRateLimit), "WeatherService-000004")
+	}
+
+	// a new key always needs the password, not another key
+	u, err := s.userService.Authenticate(ctx, dto.UUID, dto.Password, user.AuthActionCreateAPIKey)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	if !u.Verified() {
+		return CreatedKey{}, user.ErrUnverified
+	}
+
+	keys, err := s.storage.FindByUser(ctx, u.UUID)
+	if err != nil {
+		return CreatedKey{}, fmt.Errorf("failed to find api keys. error: %w", err)
+	}
+	active := 0
+	for _, key := range keys {
+		if key.RevokedAt == nil {
+			active++
+		}
+	}
+	if active >= maxKeysPerUser {
+		return CreatedKey{}, apperror.NewAppError(nil, "too many api keys", fmt.Sprintf("a user can have at most %d active api keys", maxKeysPerUser), "WeatherService-000004")
+	}
+
+	raw, prefix, err := generateKey()
+	if err != nil {
+		return CreatedKey{}, err
+	}
+	key := Key{
+		UserUUID:  u.UUID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now().UTC(),
+		Hash:      hashKey(raw),
+	}
+
+	logger.Debug("create api key")
+	key.ID, err = s.storage.Create(ctx, key)
+	if err != nil {
+		return CreatedKey{}, fmt.Errorf("failed to create api key. error: %w", err)
+	}
+
+	return CreatedKey{Key: key, Secret: raw}, nil
+}
+
+func (s *service) FindByUser(ctx context.Context, userUUID, password string) ([]Key, error) {
+	u, err := s.authenticate(ctx, userUUID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.storage.FindByUser(ctx, u.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api keys. error: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *service) Revoke(ctx context.Context, dto RevokeKeyDTO) error {
+	if !uuidRegexp.MatchString(dto.ID) {
+		return apperror.ErrNotFound
+	}
+
+	u, err := s.authenticate(ctx, dto.UUID, dto.Password)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.Revoke(ctx, u.UUID, dto.ID, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to revoke api key. error: %w", err)
+	}
+
+	s.mu.Lock()
+	for prefix, cached := range s.cache {
+		if cached.key.ID == dto.ID {
+			delete(s.cache, prefix)
+		}
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *service) FindUsage(ctx context.Context, userUUID, password, id string, days int) ([]Usage, error) {
+	if days < 0 || days > usageDaysMax {
+		return nil, apperror.NewAppError(nil, "invalid query parameter days", fmt.Sprintf("days must be from 1 to %d", usageDaysMax), "WeatherService-000004")
+	}
+	if days == 0 {
+		days = usageDaysDefault
+	}
+	if !uuidRegexp.MatchString(id) {
+		return nil, apperror.ErrNotFound
+	}
+
+	keys, err := s.FindByUser(ctx, userUUID, password)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, key := range keys {
+		found = found || key.ID == id
+	}
+	if !found {
+		return nil, apperror.ErrNotFound
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+	usage, err := s.storage.FindUsage(ctx, userUUID, id, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api key usage. error: %w", err)
+	}
+	return usage, nil
+}
+
+func (s *service) Verify(ctx context.Context, raw string) (Key, error) {
+	prefix, ok := parseKey(raw)
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.cache[prefix]
+	s.mu.Unlock()
+
+	key := cached.key
+	if !ok || now.After(cached.expires) {
+		var err error
+		key, err = s.storage.FindByPrefix(ctx, prefix)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return Key{}, ErrInvalidKey
+		}
+		if err != nil {
+			return Key{}, fmt.Errorf("failed to find api key. error: %w", err)
+		}
+
+		s.mu.Lock()
+		s.cache[prefix] = cachedKey{key: key, expires: now.Add(s.opts.CacheTTL)}
+		s.mu.Unlock()
+	}
+
+	if key.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(hashKey(raw)), []byte(key.Hash)) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+	return key, nil
+}
+
+func (s *service) Allow(ctx context.Context, key Key, now time.Time) ratelimit.Result {
+	result := s.limiter.Allow(ctx, "key:"+key.ID, ratelimit.Policy{Limit: key.RateLimit, Window: rateWindow}, now)
+	if !result.Allowed {
+		return result
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	u, ok := s.usage[usageKey{id: key.ID, day: day}]
+	if !ok {
+		u = &usage{}
+		s.usage[usageKey{id: key.ID, day: day}] = u
+	}
+	u.requests++
+	u.lastUsedAt = now
+
+	return result
+}
+
+func (s *service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush(context.Background())
+			return
+		case now := <-ticker.C:
+			s.flush(ctx)
+			s.expire(now)
+		}
+	}
+}
+
+// flush stores the counted usage. Counters that failed to be stored are kept
+// for the next flush.
+func (s *service) flush(ctx context.Context) {
+	s.mu.Lock()
+	pending := s.usage
+	s.usage = make(map[usageKey]*usage)
+	s.mu.Unlock()
+
+	for k, u := range pending {
+		if err := s.storage.AddUsage(ctx, k.id, k.day, u.requests, u.lastUsedAt); err != nil {
+			s.logger.Errorf("failed to store usage of api key %s. error: %v", k.id, err)
+
+			s.mu.Lock()
+			if current, ok := s.usage[k]; ok {
+				current.requests += u.requests
+			} else {
+				s.usage[k] = u
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// expire forgets expired cached keys.
+func (s *service) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for prefix, cached := range s.cache {
+		if now.After(cached.expires) {
+			delete(s.cache, prefix)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package apikey
+
+import (
+	"context"
+	"time"
+)
+
+type Storage interface {
+	Create(ctx context.Context, key Key) (string, error)
+	FindByUser(ctx context.Context, userUUID string) ([]Key, error)
+	// FindByPrefix returns the key with the prefix, revoked or not, or
+	// apperror.ErrNotFound.
+	FindByPrefix(ctx context.Context, prefix string) (Key, error)
+	// Revoke revokes an active key of the user or returns
+	// apperror.ErrNotFound.
+	Revoke(ctx context.Context, userUUID, id string, at time.Time) error
+
+	// AddUsage adds requests made with the key on day to its counters.
+	AddUsage(ctx context.Context, id string, day time.Time, requests int64, lastUsedAt time.Time) error
+	// FindUsage returns the daily usage of a key of the user since a day,
+	// oldest first.
+	FindUsage(ctx context.Context, userUUID, id string, since time.Time) ([]Usage, error)
+}
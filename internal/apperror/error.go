@@ -1,9 +1,21 @@
 package apperror
 
-import "encoding/json"
+import (
+	"WeatherServiceAPI/pkg/i18n"
+	"encoding/json"
+)
 
 var (
 	ErrNotFound = NewAppError(nil, "not found", "", "WeatherService-000003")
+	// ErrTooManyRequests is returned for requests over a rate limit. The
+	// limiter sets Retry-After before returning it.
+	ErrTooManyRequests = NewAppError(nil, "rate limit exceeded", "", "WeatherService-000008")
+	// ErrForbidden is the cause of errors for operations the user is not
+	// allowed to perform.
+	ErrForbidden = NewAppError(nil, "forbidden", "", "WeatherService-000007")
+	// ErrUnavailable is the cause of errors for features disabled in the
+	// configuration of the server.
+	ErrUnavailable = NewAppError(nil, "service unavailable", "", "WeatherService-000011")
 )
 
 type AppError struct {
@@ -29,6 +41,14 @@ func (e *AppError) Marshal() []byte {
 	return marshal
 }
 
+// Localize returns a copy of the error with the message translated to lang.
+// The developer message is left in English.
+func (e *AppError) Localize(lang string) *AppError {
+	localized := *e
+	localized.Message = i18n.Error(lang, e.Code, e.Message)
+	return &localized
+}
+
 func NewAppError(err error, message, developerMessage, code string) *AppError {
 	return &AppError{
 		Err:              err,
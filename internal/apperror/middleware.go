@@ -1,6 +1,7 @@
 package apperror
 
 import (
+	"WeatherServiceAPI/pkg/i18n"
 	"errors"
 	"net/http"
 )
@@ -12,21 +13,37 @@ func Middleware(h appHandler) http.HandlerFunc {
 		var appErr *AppError
 		err := h(writer, request)
 		if err != nil {
+			lang := i18n.FromContext(request.Context())
 			writer.Header().Set("Content-Type", "application/type")
 			if errors.As(err, &appErr) {
 				if errors.Is(err, ErrNotFound) {
 					writer.WriteHeader(http.StatusNotFound)
-					writer.Write(ErrNotFound.Marshal())
+					writer.Write(appErr.Localize(lang).Marshal())
+					return
+				}
+				if errors.Is(err, ErrTooManyRequests) {
+					writer.WriteHeader(http.StatusTooManyRequests)
+					writer.Write(appErr.Localize(lang).Marshal())
+					return
+				}
+				if errors.Is(err, ErrForbidden) {
+					writer.WriteHeader(http.StatusForbidden)
+					writer.Write(appErr.Localize(lang).Marshal())
+					return
+				}
+				if errors.Is(err, ErrUnavailable) {
+					writer.WriteHeader(http.StatusServiceUnavailable)
+					writer.Write(appErr.Localize(lang).Marshal())
 					return
 				}
 
 				err = err.(*AppError)
 				writer.WriteHeader(http.StatusBadRequest)
-				writer.Write(appErr.Marshal())
+				writer.Write(appErr.Localize(lang).Marshal())
 				return
 			}
 			writer.WriteHeader(http.StatusTeapot)
-			writer.Write(systemError(err).Marshal())
+			writer.Write(systemError(err).Localize(lang).Marshal())
 		}
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"WeatherServiceAPI/pkg/logging"
 	"github.com/ilyakaznacheev/cleanenv"
 	"sync"
+	"time"
 )
 
 type Config struct {
@@ -14,7 +15,125 @@ type Config struct {
 		BindIp string `yaml:"bind_ip" env-default:"127.0.0.1"`
 		Port   string `yaml:"port" env-default:"8090"`
 	} `yaml:"listen"`
-	Storage StorageConfig `yaml:"storage"`
+	Storage StorageConfig  `yaml:"storage"`
+	Logging logging.Config `yaml:"logging"`
+	Refresh struct {
+		Interval time.Duration `yaml:"interval" env-default:"1m"`
+	} `yaml:"refresh"`
+	Coordinates struct {
+		Precision int           `yaml:"precision" env-default:"2"`
+		TTL       time.Duration `yaml:"ttl" env-default:"30m"`
+	} `yaml:"coordinates"`
+	Geocoding struct {
+		ReversePrecision int           `yaml:"reverse_precision" env-default:"3"`
+		ReverseTTL       time.Duration `yaml:"reverse_ttl" env-default:"720h"`
+	} `yaml:"geocoding"`
+	Health struct {
+		RefreshStaleness time.Duration `yaml:"refresh_staleness" env-default:"5m"`
+	} `yaml:"health"`
+	Cache struct {
+		// MaxEntries bounds the number of cached responses.
+		MaxEntries int `yaml:"max_entries" env-default:"10000"`
+	} `yaml:"cache"`
+	Stream struct {
+		Heartbeat time.Duration `yaml:"heartbeat" env-default:"15s"`
+		History   int           `yaml:"history" env-default:"1000"`
+		Buffer    int           `yaml:"buffer" env-default:"64"`
+	} `yaml:"stream"`
+	WebSocket struct {
+		MaxConnections int           `yaml:"max_connections" env-default:"10000"`
+		PingInterval   time.Duration `yaml:"ping_interval" env-default:"30s"`
+	} `yaml:"websocket"`
+	GRPC struct {
+		Enabled bool `yaml:"enabled" env-default:"true"`
+		// Port of a separate gRPC listener. Empty serves gRPC on the listen
+		// port next to the REST API.
+		Port string `yaml:"port"`
+	} `yaml:"grpc"`
+	GraphQL struct {
+		MaxDepth      int `yaml:"max_depth" env-default:"8"`
+		MaxComplexity int `yaml:"max_complexity" env-default:"5000"`
+	} `yaml:"graphql"`
+	Webhooks struct {
+		// AdminToken authorizes the /api/webhooks admin endpoints, which are
+		// disabled while it is empty.
+		AdminToken   string        `yaml:"admin_token"`
+		MaxAttempts  int           `yaml:"max_attempts" env-default:"8"`
+		Backoff      time.Duration `yaml:"backoff" env-default:"30s"`
+		MaxBackoff   time.Duration `yaml:"max_backoff" env-default:"1h"`
+		Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+		PollInterval time.Duration `yaml:"poll_interval" env-default:"5s"`
+		BatchSize    int           `yaml:"batch_size" env-default:"20"`
+		// AllowPrivateNetworks lets webhooks reach loopback and private
+		// addresses, for local testing only.
+		AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+	} `yaml:"webhooks"`
+	Email struct {
+		Enabled  bool          `yaml:"enabled"`
+		Host     string        `yaml:"host" env-default:"localhost"`
+		Port     string        `yaml:"port" env-default:"1025"`
+		Username string        `yaml:"username"`
+		Password string        `yaml:"password"`
+		From     string        `yaml:"from" env-default:"Weather Service <weather@localhost>"`
+		Timeout  time.Duration `yaml:"timeout" env-default:"10s"`
+		// Transport delivers emails: smtp, or for local testing log, which
+		// prints them to stdout, and file, which writes .eml files to Dir.
+		Transport string `yaml:"transport" env-default:"smtp"`
+		Dir       string `yaml:"dir" env-default:"mail"`
+		// BaseURL is the public address of the service used in links in
+		// emails.
+		BaseURL string `yaml:"base_url" env-default:"http://localhost:8090"`
+		// UnsubscribeSecret signs unsubscribe links. Without it a random
+		// secret is used and links stop working after a restart.
+		UnsubscribeSecret string        `yaml:"unsubscribe_secret"`
+		UnsubscribeTTL    time.Duration `yaml:"unsubscribe_ttl" env-default:"2160h"`
+		QueueSize         int           `yaml:"queue_size" env-default:"1000"`
+		RateLimit         int           `yaml:"rate_limit" env-default:"10"`
+		RateWindow        time.Duration `yaml:"rate_window" env-default:"1h"`
+	} `yaml:"email"`
+	Digest struct {
+		CheckInterval time.Duration `yaml:"check_interval" env-default:"1m"`
+	} `yaml:"digest"`
+	APIKeys struct {
+		// RateLimit is the default requests per minute of a key,
+		// MaxRateLimit the highest one a user can set.
+		RateLimit     int           `yaml:"rate_limit" env-default:"60"`
+		MaxRateLimit  int           `yaml:"max_rate_limit" env-default:"6000"`
+		CacheTTL      time.Duration `yaml:"cache_ttl" env-default:"1m"`
+		FlushInterval time.Duration `yaml:"flush_interval" env-default:"30s"`
+	} `yaml:"api_keys"`
+	RateLimit struct {
+		// Store keeps the counters: memory limits every instance on its own,
+		// postgres shares the limits between instances.
+		Store string `yaml:"store" env-default:"memory"`
+		// Limit requests per Window of a client, AuthLimit per AuthWindow to
+		// endpoints checking passwords and AccountLimit per AccountWindow to
+		// them for one account. Zero disables a limit.
+		Limit         int           `yaml:"limit" env-default:"300"`
+		Window        time.Duration `yaml:"window" env-default:"1m"`
+		AuthLimit     int           `yaml:"auth_limit" env-default:"20"`
+		AuthWindow    time.Duration `yaml:"auth_window" env-default:"1m"`
+		AccountLimit  int           `yaml:"account_limit" env-default:"50"`
+		AccountWindow time.Duration `yaml:"account_window" env-default:"15m"`
+		// TrustProxy takes client addresses from X-Forwarded-For, enable it
+		// only behind a reverse proxy setting the header.
+		TrustProxy      bool          `yaml:"trust_proxy"`
+		CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1m"`
+	} `yaml:"rate_limit"`
+	Lockout struct {
+		// MaxFailures failed password checks in a row lock an account for
+		// Duration, doubled for every further failure up to MaxDuration.
+		// Zero MaxFailures disables lockout.
+		MaxFailures int           `yaml:"max_failures" env-default:"5"`
+		Duration    time.Duration `yaml:"duration" env-default:"1m"`
+		MaxDuration time.Duration `yaml:"max_duration" env-default:"24h"`
+	} `yaml:"lockout"`
+	Account struct {
+		// VerificationTTL and PasswordResetTTL are how long emailed
+		// verification links and password reset tokens are valid.
+		VerificationTTL  time.Duration `yaml:"verification_ttl" env-default:"48h"`
+		PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env-default:"1h"`
+	} `yaml:"account"`
 }
 
 type StorageConfig struct {
@@ -23,6 +142,8 @@ type StorageConfig struct {
 	Database string `json:"database"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	MigrationsDir string `yaml:"migrations_dir" env-default:"migrations"`
 }
 
 var instance *Config
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/digest"
+	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+var _ digest.Storage = &db{}
+
+type db struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func (d db) FindSchedule(ctx context.Context, userUUID string) (schedule digest.Schedule, err error) {
+	q := `SELECT user_id, digest_hours, timezone FROM notification_preferences WHERE user_id = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err = d.client.QueryRow(ctx, q, userUUID).Scan(&schedule.UserUUID, &schedule.Hours, &schedule.Timezone); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return schedule, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return schedule, newErr
+		}
+		return schedule, err
+	}
+
+	return schedule, nil
+}
+
+// ClaimDue compares times in the timezone of every user, so a digest is sent
+// once per local day at the first check after its local time. A digest missed
+// while the service was down is sent when it is back, on the same day only.
+func (d db) ClaimDue(ctx context.Context, now time.Time) ([]digest.Schedule, error) {
+	q := `UPDATE notification_preferences SET last_digest_at = $1
+WHERE email_digest
+  AND ($1::timestamptz AT TIME ZONE timezone)::time >= digest_time
+  AND (last_digest_at IS NULL OR (last_digest_at AT TIME ZONE timezone)::date < ($1::timestamptz AT TIME ZONE timezone)::date)
+RETURNING user_id, digest_hours, timezone;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, now)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := make([]digest.Schedule, 0)
+
+	for rows.Next() {
+		var schedule digest.Schedule
+		if err = rows.Scan(&schedule.UserUUID, &schedule.Hours, &schedule.Timezone); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func NewStorage(client postgresql.Client, logger *logging.Logger) digest.Storage {
+	return &db{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,83 @@
+package digest
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+)
+
+const digestURL = "/api/users/:uuid/digest"
+
+type handler struct {
+	Logger        *logging.Logger
+	DigestService Service
+	Limiter       *ratelimit.Limiter
+}
+
+func NewHandler(logger *logging.Logger, digestService Service, limiter *ratelimit.Limiter) handlers.Handler {
+	return &handler{
+		Logger:        logger,
+		DigestService: digestService,
+		Limiter:       limiter,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, digestURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.GetDigest)))
+}
+
+// GetDigest godoc
+// @Summary      Get user forecast digest
+// @Description  Forecast summary of the favourite cities of the user for the next hours, the same digest that is emailed daily. Times are in the timezone of the notification preferences, quantities in the units of the user
+// @Tags         Notifications
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true   "User uuid"
+// @Param        password    query    string  true   "User password"
+// @Param        hours       query    int     false  "Period from 24 to 48 hours, digest_hours of the preferences by default"
+// @Param        lang        query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      200  {object}  Digest
+// @Router       /users/{uuid}/digest [get]
+func (h *handler) GetDigest(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER DIGEST")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("get password from URL")
+	password := r.URL.Query().Get("password")
+	if password == "" && user.APIKeyOwner(r.Context()) == "" {
+		return apperror.NewAppError(nil, "invalid query parameter password", "password is required", "WeatherService-000004")
+	}
+
+	hours := 0
+	if value := r.URL.Query().Get("hours"); value != "" {
+		var err error
+		if hours, err = strconv.Atoi(value); err != nil {
+			return apperror.NewAppError(err, "invalid query parameter hours", "expected an integer", "WeatherService-000004")
+		}
+	}
+
+	d, err := h.DigestService.Get(r.Context(), params.ByName("uuid"), password, hours, i18n.FromContext(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	digestBytes, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to marshall digest. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(digestBytes)
+	return nil
+}
@@ -0,0 +1,65 @@
+package digest
+
+import (
+	"WeatherServiceAPI/pkg/units"
+	"time"
+	// Timezones of users are loaded from the embedded database, so they do
+	// not depend on the zoneinfo of the host.
+	_ "time/tzdata"
+)
+
+const (
+	// MinHours and MaxHours bound the period a digest covers.
+	MinHours = 24
+	MaxHours = 48
+)
+
+// Digest is the forecast for the next hours of the favourite cities of a
+// user. Times are in the timezone of the user.
+type Digest struct {
+	UserUUID    string        `json:"user_uuid"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Hours       int           `json:"hours"`
+	Timezone    string        `json:"timezone"`
+	Cities      []City        `json:"cities"`
+	Units       *units.Labels `json:"units,omitempty"`
+}
+
+// City summarises the forecast of one favourite city over the digest
+// period. The summary is empty when no forecast is stored for the city yet.
+type City struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Country     string  `json:"country"`
+	Available   bool    `json:"available"`
+	TempMin     float64 `json:"temp_min"`
+	TempMax     float64 `json:"temp_max"`
+	Pop         float64 `json:"pop"`
+	Humidity    int     `json:"humidity"`
+	WindSpeed   float64 `json:"wind_speed"`
+	WindGust    float64 `json:"wind_gust"`
+	Rain        float64 `json:"rain"`
+	Snow        float64 `json:"snow"`
+	ConditionID int     `json:"condition_id"`
+	Condition   string  `json:"condition"`
+	Description string  `json:"description"`
+	Icon        string  `json:"icon"`
+}
+
+// Schedule is the digest setting of a user.
+type Schedule struct {
+	UserUUID string
+	Hours    int
+	Timezone string
+}
+
+// Location returns the timezone of the schedule, UTC when it is unknown.
+func (s Schedule) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
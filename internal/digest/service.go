@@ -0,0 +1,217 @@
+package digest
+
+import (
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/units"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// slotLength is the length of a forecast slot. A digest starts with the slot
+// in progress.
+const slotLength = 3 * time.Hour
+
+// Listener is notified of every digest built by the scheduler.
+type Listener func(ctx context.Context, d Digest)
+
+type service struct {
+	storage        Storage
+	logger         *logging.Logger
+	userService    user.Service
+	weatherService weatherClient.Service
+
+	mu        sync.RWMutex
+	listeners []Listener
+}
+
+func NewService(storage Storage, logger *logging.Logger, userService user.Service, weatherService weatherClient.Service) (Service, error) {
+	return &service{
+		storage:        storage,
+		logger:         logger,
+		userService:    userService,
+		weatherService: weatherService,
+	}, nil
+}
+
+type Service interface {
+	// Get builds the digest of the user for the next hours, or for the hours
+	// of the user's digest setting when hours is 0.
+	Get(ctx context.Context, userUUID, password string, hours int, lang string) (Digest, error)
+	// Run builds and publishes the digests that are due every interval
+	// until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+	Subscribe(l Listener)
+}
+
+func (s *service) Get(ctx context.Context, userUUID, password string, hours int, lang string) (Digest, error) {
+	if hours != 0 && (hours < MinHours || hours > MaxHours) {
+		return Digest{}, apperror.NewAppError(nil, "invalid digest period", fmt.Sprintf("hours must be from %d to %d", MinHours, MaxHours), "WeatherService-000004")
+	}
+
+	u, err := s.userService.Authenticate(ctx, userUUID, password, user.AuthActionDigest)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	schedule, err := s.storage.FindSchedule(ctx, u.UUID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		schedule = Schedule{UserUUID: u.UUID, Hours: MinHours, Timezone: "UTC"}
+	} else if err != nil {
+		return Digest{}, fmt.Errorf("failed to find digest schedule. error: %w", err)
+	}
+	if hours != 0 {
+		schedule.Hours = hours
+	}
+
+	return s.build(ctx, u, schedule, lang, time.Now())
+}
+
+// build assembles the digest of the user from the stored forecast of the
+// favourite cities.
+func (s *service) build(ctx context.Context, u user.User, schedule Schedule, lang string, now time.Time) (Digest, error) {
+	unitSystem, err := units.Parse(u.Units, "")
+	if err != nil {
+		unitSystem, _ = units.Parse("", "")
+	}
+	labels := unitSystem.Labels()
+
+	loc := schedule.Location()
+	from := now.UTC().Truncate(slotLength)
+	to := from.Add(time.Duration(schedule.Hours) * time.Hour)
+
+	d := Digest{
+		UserUUID:    u.UUID,
+		GeneratedAt: now.In(loc),
+		From:        from.In(loc),
+		To:          to.In(loc),
+		Hours:       schedule.Hours,
+		Timezone:    loc.String(),
+		Cities:      make([]City, 0),
+		Units:       &labels,
+	}
+
+	favourites, err := s.userService.FindFavourites(ctx, u.UUID)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to find favourite cities. error: %w", err)
+	}
+
+	for _, favourite := range favourites {
+		city := City{ID: favourite.Id, Name: favourite.Name, Country: favourite.Country}
+
+		brief, err := s.weatherService.FindBriefInfo(ctx, favourite.Id)
+		if errors.Is(err, apperror.ErrNotFound) {
+			d.Cities = append(d.Cities, city)
+			continue
+		}
+		if err != nil {
+			return Digest{}, fmt.Errorf("failed to find weather of city %s. error: %w", favourite.Id, err)
+		}
+		city.Name, city.Country = brief.Name, brief.Country
+
+		forecast, err := s.weatherService.FindForecast(ctx, favourite.Id, from, to)
+		if err != nil {
+			return Digest{}, fmt.Errorf("failed to find forecast of city %s. error: %w", favourite.Id, err)
+		}
+		if len(forecast) > 0 {
+			summarise(&city, weatherClient.LocalizeForecast(weatherClient.ConvertForecastUnits(forecast, unitSystem), lang))
+		}
+		d.Cities = append(d.Cities, city)
+	}
+
+	return d, nil
+}
+
+// summarise fills the city from the forecast slots of the digest period.
+func summarise(city *City, forecast []weatherClient.Forecast) {
+	summary := weatherClient.Summary(forecast)
+
+	city.Available = true
+	city.TempMin = summary.TempMin
+	city.TempMax = summary.TempMax
+	city.Pop = summary.Pop
+	city.Humidity = summary.Humidity
+	city.WindSpeed = summary.WindSpeed
+	city.ConditionID = summary.ConditionID
+	city.Condition = summary.Condition
+	city.Description = summary.Description
+	city.Icon = summary.Icon
+
+	for _, slot := range forecast {
+		if slot.Wind.Gust > city.WindGust {
+			city.WindGust = slot.Wind.Gust
+		}
+		city.Rain += slot.Rain.ThreeH
+		city.Snow += slot.Snow.ThreeH
+	}
+}
+
+func (s *service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.sendDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue builds and publishes the digests due at now. A digest is claimed
+// before it is built, so a failure skips the digest of the day rather than
+// sending it twice.
+func (s *service) sendDue(ctx context.Context, now time.Time) {
+	schedules, err := s.storage.ClaimDue(ctx, now)
+	if err != nil {
+		s.logger.Errorf("failed to find due digests. error: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		u, err := s.userService.GetOne(ctx, schedule.UserUUID)
+		if err != nil {
+			s.logger.Errorf("failed to find user %s of digest. error: %v", schedule.UserUUID, err)
+			continue
+		}
+
+		d, err := s.build(ctx, u, schedule, i18n.English, now)
+		if err != nil {
+			s.logger.Errorf("failed to build digest of user %s. error: %v", schedule.UserUUID, err)
+			continue
+		}
+		if len(d.Cities) == 0 {
+			s.logger.Debugf("skip digest of user %s without favourite cities", schedule.UserUUID)
+			continue
+		}
+
+		s.logger.Debugf("send digest of user %s", schedule.UserUUID)
+		s.notify(ctx, d)
+	}
+}
+
+func (s *service) notify(ctx context.Context, d Digest) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+
+	for _, l := range listeners {
+		l(ctx, d)
+	}
+}
+
+func (s *service) Subscribe(l Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, l)
+}
@@ -0,0 +1,16 @@
+package digest
+
+import (
+	"context"
+	"time"
+)
+
+type Storage interface {
+	// FindSchedule returns the digest setting of the user or
+	// apperror.ErrNotFound when the user has not stored preferences.
+	FindSchedule(ctx context.Context, userUUID string) (Schedule, error)
+	// ClaimDue returns the users with the digest turned on whose local
+	// digest time has passed today and who have not got the digest of
+	// today, and marks their digest as sent at now.
+	ClaimDue(ctx context.Context, now time.Time) ([]Schedule, error)
+}
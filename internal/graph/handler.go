@@ -0,0 +1,177 @@
+package graph
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+const (
+	graphqlURL = "/graphql"
+
+	// maxRequestSize is the largest accepted request body, bytes.
+	maxRequestSize = 1 << 20
+
+	searchLimitDefault = 10
+	searchLimitMax     = 50
+)
+
+// Request is a GraphQL request, sent as a JSON body or, for GET, as the
+// query, operationName and variables query parameters.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type handler struct {
+	logger         *logging.Logger
+	cityService    cityClient.Service
+	weatherService weatherClient.Service
+	userService    user.Service
+	limiter        *ratelimit.Limiter
+	limits         limits
+
+	graphSchema graphql.Schema
+}
+
+func NewHandler(logger *logging.Logger, cityService cityClient.Service, weatherService weatherClient.Service, userService user.Service, limiter *ratelimit.Limiter, maxDepth, maxComplexity int) (handlers.Handler, error) {
+	h := &handler{
+		logger:         logger,
+		cityService:    cityService,
+		weatherService: weatherService,
+		userService:    userService,
+		limiter:        limiter,
+		limits:         limits{maxDepth: maxDepth, maxComplexity: maxComplexity},
+	}
+
+	graphSchema, err := h.schema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graphql schema. error: %w", err)
+	}
+	h.graphSchema = graphSchema
+
+	return h, nil
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, graphqlURL, apperror.Middleware(h.Query))
+	router.HandlerFunc(http.MethodPost, graphqlURL, apperror.Middleware(h.Query))
+}
+
+// Query executes a GraphQL request. Queries deeper or more complex than the
+// configured limits are rejected before execution. Errors of the query
+// document are answered with 400, errors of resolvers with 200 and the
+// partial data, as usual for GraphQL.
+func (h *handler) Query(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GRAPHQL QUERY")
+	w.Header().Set("Content-Type", "application/json")
+
+	req, err := readRequest(w, r)
+	if err != nil {
+		return err
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+	}
+
+	validation := graphql.ValidateDocument(&h.graphSchema, doc, nil)
+	if !validation.IsValid {
+		return writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+	}
+
+	if err = h.limits.check(doc, req.OperationName, req.Variables); err != nil {
+		logger.Debugf("graphql query rejected. error: %v", err)
+		queryErr := newQueryError(r.Context(), err).(queryError)
+		return writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: []gqlerrors.FormattedError{{
+			Message:    queryErr.Error(),
+			Extensions: queryErr.Extensions(),
+		}}})
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.graphSchema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       contextWithLoader(r.Context(), newForecastLoader(h.weatherService)),
+	})
+	restoreExtensions(result.Errors)
+
+	return writeResult(w, http.StatusOK, result)
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request) (Request, error) {
+	var req Request
+
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, apperror.NewAppError(err, "invalid query parameter variables", "expected a JSON object", "WeatherService-000004")
+			}
+		}
+	} else {
+		defer r.Body.Close()
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			return req, apperror.NewAppError(err, "invalid JSON scheme", "expected {\"query\": ..., \"variables\": {...}}", "WeatherService-000004")
+		}
+	}
+
+	if req.Query == "" {
+		return req, apperror.NewAppError(nil, "query is required", "", "WeatherService-000004")
+	}
+	return req, nil
+}
+
+// restoreExtensions sets the extensions of errors returned by thunks, which
+// graphql-go wraps twice and drops.
+func restoreExtensions(errs []gqlerrors.FormattedError) {
+	for i := range errs {
+		if errs[i].Extensions != nil {
+			continue
+		}
+
+		err := errs[i].OriginalError()
+		for err != nil {
+			switch e := err.(type) {
+			case queryError:
+				errs[i].Extensions = e.Extensions()
+				err = nil
+			case *gqlerrors.Error:
+				err = e.OriginalError
+			case gqlerrors.FormattedError:
+				err = e.OriginalError()
+			default:
+				err = nil
+			}
+		}
+	}
+}
+
+func writeResult(w http.ResponseWriter, status int, result *graphql.Result) error {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshall graphql result. error: %w", err)
+	}
+
+	w.WriteHeader(status)
+	w.Write(resultBytes)
+	return nil
+}
@@ -0,0 +1,161 @@
+package graph
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// listSizes estimate the number of items of list fields for the complexity of
+// a query: the value of the size argument when given, up to the most items
+// the resolver returns, otherwise size.
+var listSizes = map[string]struct {
+	arg  string
+	size int
+	max  int
+}{
+	"cities":     {arg: "limit", size: searchLimitMax, max: searchLimitMax},
+	"forecast":   {arg: "first", size: forecastSlotsMax, max: forecastSlotsMax},
+	"daily":      {arg: "", size: 6},
+	"favourites": {arg: "", size: 10},
+}
+
+// forecastSlotsMax is the number of 3 hour slots in the 5 day forecast.
+const forecastSlotsMax = 40
+
+// limits rejects queries nested deeper than maxDepth fields or whose
+// complexity exceeds maxComplexity. Every field costs 1 and the cost of the
+// selection of a list field is multiplied by its estimated size. Introspection
+// fields are not counted.
+type limits struct {
+	maxDepth      int
+	maxComplexity int
+}
+
+func (l limits) check(doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || def.Name != nil && def.Name.Value == operationName {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		// reported by validation or execution
+		return nil
+	}
+
+	w := walker{fragments: fragments, variables: variables, visiting: make(map[string]bool)}
+	depth, complexity := w.selectionSet(operation.SelectionSet)
+
+	if l.maxDepth > 0 && depth > l.maxDepth {
+		return apperror.NewAppError(nil, "query is too deep", fmt.Sprintf("depth %d exceeds the limit of %d", depth, l.maxDepth), "WeatherService-000004")
+	}
+	if l.maxComplexity > 0 && complexity > l.maxComplexity {
+		return apperror.NewAppError(nil, "query is too complex", fmt.Sprintf("complexity %d exceeds the limit of %d", complexity, l.maxComplexity), "WeatherService-000004")
+	}
+	return nil
+}
+
+type walker struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+// selectionSet returns the depth and complexity of a selection.
+func (w walker) selectionSet(set *ast.SelectionSet) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, c int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d, c = w.field(selection)
+		case *ast.InlineFragment:
+			d, c = w.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := w.fragments[name]
+			if !ok || w.visiting[name] {
+				// unknown and cyclic fragments are reported by validation
+				continue
+			}
+			w.visiting[name] = true
+			d, c = w.selectionSet(fragment.SelectionSet)
+			delete(w.visiting, name)
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+
+	return depth, complexity
+}
+
+func (w walker) field(field *ast.Field) (depth, complexity int) {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return 0, 0
+	}
+
+	depth, complexity = w.selectionSet(field.SelectionSet)
+	if list, ok := listSizes[name]; ok {
+		size := w.listSize(field, list.arg, list.size, list.max)
+		if complexity > math.MaxInt32/size {
+			// nested lists of invalid queries, rejected anyway
+			complexity = math.MaxInt32
+		} else {
+			complexity *= size
+		}
+	}
+	return depth + 1, complexity + 1
+}
+
+func (w walker) listSize(field *ast.Field, arg string, size, maxSize int) int {
+	if arg == "" {
+		return size
+	}
+
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != arg {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil && n > 0 {
+				return clampSize(n, maxSize)
+			}
+		case *ast.Variable:
+			switch n := w.variables[value.Name.Value].(type) {
+			case float64:
+				if n >= 1 {
+					return int(math.Min(n, float64(maxSize)))
+				}
+			case int:
+				if n > 0 {
+					return clampSize(n, maxSize)
+				}
+			}
+		}
+	}
+	return size
+}
+
+func clampSize(n, maxSize int) int {
+	if n > maxSize {
+		return maxSize
+	}
+	return n
+}
@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"sync"
+	"time"
+)
+
+// forecastLoader batches the forecast lookups of one request. Resolvers
+// register the city with Load and get a thunk; graphql-go runs the thunks of
+// a level only after all fields of that level are resolved, so the first thunk
+// loads the forecast of every registered city with a single query.
+type forecastLoader struct {
+	weatherService weatherClient.Service
+
+	mu      sync.Mutex
+	batches map[forecastRange]*forecastBatch
+}
+
+type forecastRange struct {
+	from, to time.Time
+}
+
+type forecastBatch struct {
+	pending []string
+	loaded  map[string][]weatherClient.Forecast
+	errs    map[string]error
+}
+
+func newForecastLoader(weatherService weatherClient.Service) *forecastLoader {
+	return &forecastLoader{
+		weatherService: weatherService,
+		batches:        make(map[forecastRange]*forecastBatch),
+	}
+}
+
+// Load registers cityID and returns a thunk with its forecast in [from, to).
+func (l *forecastLoader) Load(ctx context.Context, cityID string, from, to time.Time) func() ([]weatherClient.Forecast, error) {
+	key := forecastRange{from: from.UTC(), to: to.UTC()}
+
+	l.mu.Lock()
+	batch, ok := l.batches[key]
+	if !ok {
+		batch = &forecastBatch{
+			loaded: make(map[string][]weatherClient.Forecast),
+			errs:   make(map[string]error),
+		}
+		l.batches[key] = batch
+	}
+	if _, ok = batch.loaded[cityID]; !ok && !contains(batch.pending, cityID) {
+		batch.pending = append(batch.pending, cityID)
+	}
+	l.mu.Unlock()
+
+	return func() ([]weatherClient.Forecast, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(batch.pending) > 0 && contains(batch.pending, cityID) {
+			l.load(ctx, key, batch)
+		}
+		if err, ok := batch.errs[cityID]; ok {
+			return nil, err
+		}
+		return batch.loaded[cityID], nil
+	}
+}
+
+func (l *forecastLoader) load(ctx context.Context, key forecastRange, batch *forecastBatch) {
+	cityIDs := batch.pending
+	batch.pending = nil
+
+	logging.FromContext(ctx).Debugf("load forecast of %d cities", len(cityIDs))
+
+	forecast := make(map[string][]weatherClient.Forecast, len(cityIDs))
+	for _, cityID := range cityIDs {
+		forecast[cityID] = make([]weatherClient.Forecast, 0)
+	}
+	err := l.weatherService.StreamForecast(ctx, cityIDs, key.from, key.to, func(cityID string, slot weatherClient.Forecast) error {
+		forecast[cityID] = append(forecast[cityID], slot)
+		return nil
+	})
+
+	for _, cityID := range cityIDs {
+		if err != nil {
+			batch.errs[cityID] = err
+			continue
+		}
+		batch.loaded[cityID] = forecast[cityID]
+	}
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
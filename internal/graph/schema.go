@@ -0,0 +1,510 @@
+package graph
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/units"
+	"context"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"time"
+)
+
+// forecastEnd is the end of the forecast range when the query has none.
+var forecastEnd = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// cityNode is the source of City. units is the default unit system, the one
+// of the user when the city is one of their favourites.
+type cityNode struct {
+	city  cityClient.CityData
+	units string
+}
+
+// slotNode is the source of ForecastSlot, already converted and translated.
+type slotNode struct {
+	forecast weatherClient.Forecast
+	labels   units.Labels
+}
+
+// dayNode is the source of DailySummary, already converted and translated.
+type dayNode struct {
+	day    weatherClient.DailyForecast
+	labels units.Labels
+}
+
+// userNode is the source of User. The password is kept to read the
+// favourites the same way GET /api/userfavs does.
+type userNode struct {
+	user     user.User
+	password string
+}
+
+type loaderKey struct{}
+
+func contextWithLoader(ctx context.Context, loader *forecastLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func loaderFromContext(ctx context.Context) *forecastLoader {
+	return ctx.Value(loaderKey{}).(*forecastLoader)
+}
+
+// queryError is a resolver error with its code in the extensions.
+type queryError struct {
+	message string
+	code    string
+}
+
+func (e queryError) Error() string {
+	return e.message
+}
+
+func (e queryError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// newQueryError translates err to the request language. Errors other than
+// AppError are logged and hidden behind the generic system error.
+func newQueryError(ctx context.Context, err error) error {
+	lang := i18n.FromContext(ctx)
+
+	var appErr *apperror.AppError
+	if errors.As(err, &appErr) {
+		return queryError{message: appErr.Localize(lang).Message, code: appErr.Code}
+	}
+
+	logging.FromContext(ctx).Error(err)
+	return queryError{message: i18n.Error(lang, "WeatherService-000001", "internal system error"), code: "WeatherService-000001"}
+}
+
+// resolve converts the errors of fn, including those of the thunks it
+// returns, with newQueryError.
+func resolve(fn graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		result, err := fn(p)
+		if err != nil {
+			return nil, newQueryError(p.Context, err)
+		}
+		if thunk, ok := result.(func() (interface{}, error)); ok {
+			return func() (interface{}, error) {
+				result, err := thunk()
+				if err != nil {
+					return nil, newQueryError(p.Context, err)
+				}
+				return result, nil
+			}, nil
+		}
+		return result, nil
+	}
+}
+
+var unitsType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Units",
+	Description: "Units of the quantities of a forecast.",
+	Fields: graphql.Fields{
+		"system":        labelField(func(l units.Labels) string { return string(l.System) }),
+		"temperature":   labelField(func(l units.Labels) string { return l.Temperature }),
+		"windSpeed":     labelField(func(l units.Labels) string { return l.WindSpeed }),
+		"pressure":      labelField(func(l units.Labels) string { return string(l.Pressure) }),
+		"visibility":    labelField(func(l units.Labels) string { return l.Visibility }),
+		"precipitation": labelField(func(l units.Labels) string { return l.Precipitation }),
+	},
+})
+
+func labelField(get func(l units.Labels) string) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(graphql.String),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(units.Labels)), nil
+		},
+	}
+}
+
+var forecastSlotType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ForecastSlot",
+	Description: "A 3-hour forecast slot.",
+	Fields: graphql.Fields{
+		"time":        slotField(graphql.DateTime, func(f weatherClient.Forecast) interface{} { return f.Time() }),
+		"temp":        slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Main.Temp }),
+		"feelsLike":   slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Main.FeelsLike }),
+		"tempMin":     slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Main.TempMin }),
+		"tempMax":     slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Main.TempMax }),
+		"pressure":    slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Main.Pressure }),
+		"humidity":    slotField(graphql.Int, func(f weatherClient.Forecast) interface{} { return f.Main.Humidity }),
+		"windSpeed":   slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Wind.Speed }),
+		"windDeg":     slotField(graphql.Int, func(f weatherClient.Forecast) interface{} { return f.Wind.Deg }),
+		"windGust":    slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Wind.Gust }),
+		"clouds":      slotField(graphql.Int, func(f weatherClient.Forecast) interface{} { return f.Clouds.All }),
+		"visibility":  slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Visibility }),
+		"pop":         slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Pop }),
+		"rain3h":      slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Rain.ThreeH }),
+		"snow3h":      slotField(graphql.Float, func(f weatherClient.Forecast) interface{} { return f.Snow.ThreeH }),
+		"conditionId": slotField(graphql.Int, func(f weatherClient.Forecast) interface{} { return condition(f).ID }),
+		"condition":   slotField(graphql.String, func(f weatherClient.Forecast) interface{} { return condition(f).Main }),
+		"description": slotField(graphql.String, func(f weatherClient.Forecast) interface{} { return condition(f).Description }),
+		"icon":        slotField(graphql.String, func(f weatherClient.Forecast) interface{} { return condition(f).Icon }),
+		"units": &graphql.Field{
+			Type: graphql.NewNonNull(unitsType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(slotNode).labels, nil
+			},
+		},
+	},
+})
+
+func slotField(t graphql.Output, get func(f weatherClient.Forecast) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(t),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(slotNode).forecast), nil
+		},
+	}
+}
+
+// condition returns the main condition of the slot, empty when there is none.
+func condition(f weatherClient.Forecast) (c struct {
+	ID          int
+	Main        string
+	Description string
+	Icon        string
+}) {
+	if len(f.Weather) > 0 {
+		c.ID, c.Main, c.Description, c.Icon = f.Weather[0].ID, f.Weather[0].Main, f.Weather[0].Description, f.Weather[0].Icon
+	}
+	return c
+}
+
+var dailySummaryType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "DailySummary",
+	Description: "The forecast of one UTC day.",
+	Fields: graphql.Fields{
+		"date":        dayField(graphql.DateTime, func(d weatherClient.DailyForecast) interface{} { return d.Date }),
+		"tempMin":     dayField(graphql.Float, func(d weatherClient.DailyForecast) interface{} { return d.TempMin }),
+		"tempMax":     dayField(graphql.Float, func(d weatherClient.DailyForecast) interface{} { return d.TempMax }),
+		"pop":         dayField(graphql.Float, func(d weatherClient.DailyForecast) interface{} { return d.Pop }),
+		"humidity":    dayField(graphql.Int, func(d weatherClient.DailyForecast) interface{} { return d.Humidity }),
+		"windSpeed":   dayField(graphql.Float, func(d weatherClient.DailyForecast) interface{} { return d.WindSpeed }),
+		"conditionId": dayField(graphql.Int, func(d weatherClient.DailyForecast) interface{} { return d.ConditionID }),
+		"condition":   dayField(graphql.String, func(d weatherClient.DailyForecast) interface{} { return d.Condition }),
+		"description": dayField(graphql.String, func(d weatherClient.DailyForecast) interface{} { return d.Description }),
+		"icon":        dayField(graphql.String, func(d weatherClient.DailyForecast) interface{} { return d.Icon }),
+		"units": &graphql.Field{
+			Type: graphql.NewNonNull(unitsType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(dayNode).labels, nil
+			},
+		},
+	},
+})
+
+func dayField(t graphql.Output, get func(d weatherClient.DailyForecast) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(t),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(dayNode).day), nil
+		},
+	}
+}
+
+// unitsArgs select the units of forecast fields, as ?units= and ?pressure=.
+var unitsArgs = graphql.FieldConfigArgument{
+	"units": &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "metric, imperial or standard. Defaults to the units of the user for favourites, otherwise metric",
+	},
+	"pressure": &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "Pressure unit overriding the unit system: hPa, inHg or mmHg",
+	},
+}
+
+func withUnitsArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	for name, arg := range unitsArgs {
+		args[name] = arg
+	}
+	return args
+}
+
+// schema builds the schema with resolvers backed by the services of h.
+func (h *handler) schema() (graphql.Schema, error) {
+	cityType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "City",
+		Description: "A tracked city.",
+		Fields: graphql.Fields{
+			"id":      cityField(graphql.NewNonNull(graphql.ID), func(c cityClient.CityData) interface{} { return c.Id }),
+			"name":    cityField(graphql.NewNonNull(graphql.String), func(c cityClient.CityData) interface{} { return c.Name }),
+			"country": cityField(graphql.NewNonNull(graphql.String), func(c cityClient.CityData) interface{} { return c.Country }),
+			"state":   cityField(graphql.String, func(c cityClient.CityData) interface{} { return c.State }),
+			"lat":     cityField(graphql.NewNonNull(graphql.Float), func(c cityClient.CityData) interface{} { return c.Lat }),
+			"lon":     cityField(graphql.NewNonNull(graphql.Float), func(c cityClient.CityData) interface{} { return c.Lon }),
+			"forecast": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(forecastSlotType))),
+				Description: "Forecast slots starting in [from, to), all stored slots by default",
+				Args: withUnitsArgs(graphql.FieldConfigArgument{
+					"from":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"to":    &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"first": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Maximum number of slots"},
+				}),
+				Resolve: resolve(h.resolveForecast),
+			},
+			"current": &graphql.Field{
+				Type:        forecastSlotType,
+				Description: "The slot of the current time",
+				Args:        withUnitsArgs(graphql.FieldConfigArgument{}),
+				Resolve:     resolve(h.resolveCurrent),
+			},
+			"daily": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(dailySummaryType))),
+				Description: "Forecast summarised by UTC day",
+				Args:        withUnitsArgs(graphql.FieldConfigArgument{}),
+				Resolve:     resolve(h.resolveDaily),
+			},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"uuid": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(userNode).user.UUID, nil
+				},
+			},
+			"email": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(userNode).user.Email, nil
+				},
+			},
+			"units": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(userNode).user.Units, nil
+				},
+			},
+			"favourites": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(cityType))),
+				Resolve: resolve(h.resolveFavourites),
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"cities": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(cityType))),
+				Description: "Tracked cities, or the cities matching search",
+				Args: graphql.FieldConfigArgument{
+					"search": &graphql.ArgumentConfig{Type: graphql.String, Description: "Name prefix, as /api/cities/search?q="},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: resolve(h.resolveCities),
+			},
+			"city": &graphql.Field{
+				Type:        cityType,
+				Description: "City by name in any case, name,country or id",
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: resolve(h.resolveCity),
+			},
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "User by email and password",
+				Args: graphql.FieldConfigArgument{
+					"email":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"password": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: resolve(h.resolveUser),
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func cityField(t graphql.Output, get func(c cityClient.CityData) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(cityNode).city), nil
+		},
+	}
+}
+
+func (h *handler) resolveCities(p graphql.ResolveParams) (interface{}, error) {
+	limit, _ := p.Args["limit"].(int)
+
+	var cities []cityClient.CityData
+	var err error
+	if search, ok := p.Args["search"].(string); ok {
+		if limit <= 0 {
+			limit = searchLimitDefault
+		}
+		if limit > searchLimitMax {
+			limit = searchLimitMax
+		}
+		cities, err = h.cityService.Search(p.Context, search, limit)
+	} else {
+		cities, err = h.cityService.FindAll(p.Context)
+		if limit > 0 && len(cities) > limit {
+			cities = cities[:limit]
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]cityNode, len(cities))
+	for i, city := range cities {
+		nodes[i] = cityNode{city: city}
+	}
+	return nodes, nil
+}
+
+func (h *handler) resolveCity(p graphql.ResolveParams) (interface{}, error) {
+	city, err := h.cityService.Resolve(p.Context, p.Args["name"].(string))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cityNode{city: city}, nil
+}
+
+func (h *handler) resolveUser(p graphql.ResolveParams) (interface{}, error) {
+	email, password := p.Args["email"].(string), p.Args["password"].(string)
+
+	if result := h.limiter.AllowAuth(p.Context, user.ClientFromContext(p.Context).IP, email, time.Now()); !result.Allowed {
+		return nil, apperror.ErrTooManyRequests
+	}
+	u, err := h.userService.GetByEmailAndPassword(p.Context, email, password)
+	if err != nil {
+		return nil, err
+	}
+	return userNode{user: u, password: password}, nil
+}
+
+func (h *handler) resolveFavourites(p graphql.ResolveParams) (interface{}, error) {
+	node := p.Source.(userNode)
+
+	favourites, err := h.userService.GetFavourites(p.Context, node.user.Email, node.password)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]cityNode, len(favourites))
+	for i, city := range favourites {
+		nodes[i] = cityNode{city: city, units: node.user.Units}
+	}
+	return nodes, nil
+}
+
+func (h *handler) resolveForecast(p graphql.ResolveParams) (interface{}, error) {
+	from, to := time.Time{}, forecastEnd
+	if v, ok := p.Args["from"].(time.Time); ok {
+		from = v
+	}
+	if v, ok := p.Args["to"].(time.Time); ok {
+		to = v
+	}
+	if !from.Before(to) {
+		return nil, apperror.NewAppError(nil, "invalid period", "from must be before to", "WeatherService-000004")
+	}
+	first, _ := p.Args["first"].(int)
+
+	u, err := argUnits(p)
+	if err != nil {
+		return nil, err
+	}
+	lang := i18n.FromContext(p.Context)
+
+	load := loaderFromContext(p.Context).Load(p.Context, p.Source.(cityNode).city.Id, from, to)
+	return func() (interface{}, error) {
+		forecast, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if first > 0 && len(forecast) > first {
+			forecast = forecast[:first]
+		}
+
+		labels := u.Labels()
+		slots := make([]slotNode, len(forecast))
+		for i, f := range forecast {
+			slots[i] = slotNode{forecast: f.ConvertUnits(u).Localize(lang), labels: labels}
+		}
+		return slots, nil
+	}, nil
+}
+
+func (h *handler) resolveCurrent(p graphql.ResolveParams) (interface{}, error) {
+	u, err := argUnits(p)
+	if err != nil {
+		return nil, err
+	}
+	lang := i18n.FromContext(p.Context)
+
+	// shares the batch of forecast fields without a range
+	load := loaderFromContext(p.Context).Load(p.Context, p.Source.(cityNode).city.Id, time.Time{}, forecastEnd)
+	return func() (interface{}, error) {
+		forecast, err := load()
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		for _, f := range forecast {
+			if f.Time().Add(3 * time.Hour).After(now) {
+				return slotNode{forecast: f.ConvertUnits(u).Localize(lang), labels: u.Labels()}, nil
+			}
+		}
+		return nil, nil
+	}, nil
+}
+
+func (h *handler) resolveDaily(p graphql.ResolveParams) (interface{}, error) {
+	u, err := argUnits(p)
+	if err != nil {
+		return nil, err
+	}
+	lang := i18n.FromContext(p.Context)
+
+	load := loaderFromContext(p.Context).Load(p.Context, p.Source.(cityNode).city.Id, time.Time{}, forecastEnd)
+	return func() (interface{}, error) {
+		forecast, err := load()
+		if err != nil {
+			return nil, err
+		}
+
+		labels := u.Labels()
+		daily := weatherClient.Daily(weatherClient.LocalizeForecast(weatherClient.ConvertForecastUnits(forecast, u), lang))
+		days := make([]dayNode, len(daily))
+		for i, day := range daily {
+			days[i] = dayNode{day: day, labels: labels}
+		}
+		return days, nil
+	}, nil
+}
+
+// argUnits reads the units arguments of a City field.
+func argUnits(p graphql.ResolveParams) (units.Units, error) {
+	system, _ := p.Args["units"].(string)
+	if system == "" {
+		system = p.Source.(cityNode).units
+	}
+	pressure, _ := p.Args["pressure"].(string)
+
+	u, err := units.Parse(system, pressure)
+	if err != nil {
+		return u, apperror.NewAppError(err, err.Error(), "", "WeatherService-000004")
+	}
+	return u, nil
+}
@@ -1,7 +1,25 @@
 package handlers
 
-import "github.com/julienschmidt/httprouter"
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
 
 type Handler interface {
 	Register(router *httprouter.Router)
 }
+
+// ParamRoutes sends requests whose path parameter param equals one of the keys
+// of routes to that handler and all other requests to fallback. httprouter does
+// not allow a static segment next to a named parameter, so a route such as
+// /api/cities/search has to be served through /api/cities/:city.
+func ParamRoutes(param string, routes map[string]http.HandlerFunc, fallback http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if route, ok := routes[params.ByName(param)]; ok {
+			route(w, r)
+			return
+		}
+		fallback(w, r)
+	}
+}
@@ -0,0 +1,167 @@
+package health
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"time"
+)
+
+const (
+	healthURL = "/healthz"
+	readyURL  = "/readyz"
+	statusURL = "/status"
+
+	checkOK = "ok"
+)
+
+// Database is the part of the connection pool the readiness check needs.
+type Database interface {
+	postgresql.Client
+	Ping(ctx context.Context) error
+}
+
+type ReadinessReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type StatusReport struct {
+	Build            BuildInfo    `json:"build"`
+	StartedAt        time.Time    `json:"started_at"`
+	Uptime           string       `json:"uptime"`
+	MigrationVersion int64        `json:"migration_version"`
+	LastRefresh      *time.Time   `json:"last_refresh,omitempty"`
+	NextRefresh      *time.Time   `json:"next_refresh,omitempty"`
+	Cities           []CityStatus `json:"cities"`
+}
+
+type handler struct {
+	logger                   *logging.Logger
+	db                       Database
+	tracker                  *Tracker
+	refreshStaleness         time.Duration
+	expectedMigrationVersion int64
+}
+
+func NewHandler(logger *logging.Logger, db Database, tracker *Tracker, refreshStaleness time.Duration, expectedMigrationVersion int64) handlers.Handler {
+	return &handler{
+		logger:                   logger,
+		db:                       db,
+		tracker:                  tracker,
+		refreshStaleness:         refreshStaleness,
+		expectedMigrationVersion: expectedMigrationVersion,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, healthURL, apperror.Middleware(h.Health))
+	router.HandlerFunc(http.MethodGet, readyURL, apperror.Middleware(h.Ready))
+	router.HandlerFunc(http.MethodGet, statusURL, apperror.Middleware(h.Status))
+}
+
+// Health is the liveness probe: it answers as long as the process serves requests.
+func (h *handler) Health(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"ok"}`))
+
+	return nil
+}
+
+// Ready is the readiness probe. It fails with 503 if the database is unreachable,
+// the schema is not at the expected migration version or weather data is stale.
+func (h *handler) Ready(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	report := ReadinessReport{Status: checkOK, Checks: make(map[string]string)}
+
+	report.Checks["database"] = checkOK
+	if err := h.db.Ping(ctx); err != nil {
+		logger.Errorf("readiness: database is unreachable. error: %v", err)
+		report.Checks["database"] = "unreachable"
+	}
+
+	report.Checks["migrations"] = checkOK
+	version, err := postgresql.MigrationVersion(ctx, h.db)
+	if err != nil {
+		logger.Errorf("readiness: failed to get migration version. error: %v", err)
+		report.Checks["migrations"] = "unknown version"
+	} else if version != h.expectedMigrationVersion {
+		report.Checks["migrations"] = fmt.Sprintf("version %d, expected %d", version, h.expectedMigrationVersion)
+	}
+
+	report.Checks["weather"] = checkOK
+	lastRefresh := h.tracker.LastRefresh()
+	if lastRefresh.IsZero() {
+		report.Checks["weather"] = "no successful refresh yet"
+	} else if age := time.Since(lastRefresh); age > h.refreshStaleness {
+		report.Checks["weather"] = fmt.Sprintf("last successful refresh %s ago", age.Round(time.Second))
+	}
+
+	status := http.StatusOK
+	for name, check := range report.Checks {
+		if check != checkOK {
+			logger.Warnf("readiness check %s failed: %s", name, check)
+			report.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshall readiness report. error: %w", err)
+	}
+
+	w.WriteHeader(status)
+	w.Write(reportBytes)
+
+	return nil
+}
+
+// Status reports build info and the refresh state of every city.
+func (h *handler) Status(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	report := StatusReport{
+		Build:     GetBuildInfo(),
+		StartedAt: h.tracker.StartedAt(),
+		Uptime:    time.Since(h.tracker.StartedAt()).Round(time.Second).String(),
+		Cities:    h.tracker.Cities(),
+	}
+
+	version, err := postgresql.MigrationVersion(r.Context(), h.db)
+	if err != nil {
+		logger.Warnf("failed to read migration version: %v", err)
+	}
+	report.MigrationVersion = version
+
+	if lastRefresh := h.tracker.LastRefresh(); !lastRefresh.IsZero() {
+		report.LastRefresh = &lastRefresh
+	}
+	if nextRefresh := h.tracker.NextRefresh(); !nextRefresh.IsZero() {
+		report.NextRefresh = &nextRefresh
+	}
+
+	logger.Debug("marshal status report")
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshall status report. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(reportBytes)
+
+	return nil
+}
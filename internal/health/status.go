@@ -0,0 +1,114 @@
+package health
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"sort"
+	"sync"
+	"time"
+)
+
+type CityStatus struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Country        string     `json:"country"`
+	LastRefresh    *time.Time `json:"last_refresh,omitempty"`
+	UpstreamErrors int        `json:"upstream_errors"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Tracker keeps the outcome of weather refreshes in memory for the readiness
+// check and the status page.
+type Tracker struct {
+	mu          sync.RWMutex
+	startedAt   time.Time
+	lastRefresh time.Time
+	nextRefresh time.Time
+	cities      map[string]*CityStatus
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		startedAt: time.Now(),
+		cities:    make(map[string]*CityStatus),
+	}
+}
+
+func (t *Tracker) RefreshSucceeded(city cityClient.CityData, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.city(city)
+	status.LastRefresh = &at
+	if at.After(t.lastRefresh) {
+		t.lastRefresh = at
+	}
+}
+
+func (t *Tracker) RefreshFailed(city cityClient.CityData, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	status := t.city(city)
+	status.UpstreamErrors++
+	status.LastError = err.Error()
+	status.LastErrorAt = &now
+}
+
+// ScheduleRefresh records when the next refresh of all cities will start.
+func (t *Tracker) ScheduleRefresh(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextRefresh = at
+}
+
+// LastRefresh returns the time of the latest successful refresh of any city.
+func (t *Tracker) LastRefresh() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.lastRefresh
+}
+
+func (t *Tracker) NextRefresh() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.nextRefresh
+}
+
+func (t *Tracker) StartedAt() time.Time {
+	return t.startedAt
+}
+
+// Cities returns a copy of the per-city statuses sorted by city name.
+func (t *Tracker) Cities() []CityStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	cities := make([]CityStatus, 0, len(t.cities))
+	for _, status := range t.cities {
+		cities = append(cities, *status)
+	}
+
+	sort.Slice(cities, func(i, j int) bool {
+		return cities[i].Name < cities[j].Name
+	})
+
+	return cities
+}
+
+func (t *Tracker) city(city cityClient.CityData) *CityStatus {
+	status, ok := t.cities[city.Id]
+	if !ok {
+		status = &CityStatus{
+			ID:      city.Id,
+			Name:    city.Name,
+			Country: city.Country,
+		}
+		t.cities[city.Id] = status
+	}
+	return status
+}
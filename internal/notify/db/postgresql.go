@@ -0,0 +1,90 @@
+package db
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/notify"
+	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+var _ notify.Storage = &db{}
+
+type db struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func (d db) Find(ctx context.Context, userUUID string) (prefs notify.Preferences, err error) {
+	q := `SELECT user_id, email_alerts, email_digest, to_char(digest_time, 'HH24:MI'), timezone, digest_hours, updated_at FROM notification_preferences WHERE user_id = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err = d.client.QueryRow(ctx, q, userUUID).Scan(&prefs.UserUUID, &prefs.EmailAlerts, &prefs.EmailDigest, &prefs.DigestTime, &prefs.Timezone, &prefs.DigestHours, &prefs.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return prefs, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return prefs, newErr
+		}
+		return prefs, err
+	}
+
+	return prefs, nil
+}
+
+func (d db) Save(ctx context.Context, prefs notify.Preferences) error {
+	q := `INSERT INTO notification_preferences (user_id, email_alerts, email_digest, digest_time, timezone, digest_hours, updated_at) VALUES ($1, $2, $3, $4::time, $5, $6, $7)
+ON CONFLICT (user_id) DO UPDATE SET email_alerts = EXCLUDED.email_alerts, email_digest = EXCLUDED.email_digest, digest_time = EXCLUDED.digest_time,
+    timezone = EXCLUDED.timezone, digest_hours = EXCLUDED.digest_hours, updated_at = EXCLUDED.updated_at;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	_, err := d.client.Exec(ctx, q, prefs.UserUUID, prefs.EmailAlerts, prefs.EmailDigest, prefs.DigestTime, prefs.Timezone, prefs.DigestHours, prefs.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) TimezoneExists(ctx context.Context, name string) (exists bool, err error) {
+	q := `SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = $1);`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err = d.client.QueryRow(ctx, q, name).Scan(&exists); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return false, newErr
+		}
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func NewStorage(client postgresql.Client, logger *logging.Logger) notify.Storage {
+	return &db{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+const (
+	preferencesURL = "/api/users/:uuid/notifications"
+	unsubscribeURL = "/api/unsubscribe"
+)
+
+type handler struct {
+	Logger        *logging.Logger
+	NotifyService Service
+	Limiter       *ratelimit.Limiter
+}
+
+func NewHandler(logger *logging.Logger, notifyService Service, limiter *ratelimit.Limiter) handlers.Handler {
+	return &handler{
+		Logger:        logger,
+		NotifyService: notifyService,
+		Limiter:       limiter,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, preferencesURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.GetPreferences)))
+	router.HandlerFunc(http.MethodPatch, preferencesURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.UpdatePreferences)))
+	router.HandlerFunc(http.MethodGet, unsubscribeURL, apperror.Middleware(h.UnsubscribeForm))
+	router.HandlerFunc(http.MethodPost, unsubscribeURL, apperror.Middleware(h.Unsubscribe))
+}
+
+// GetPreferences godoc
+// @Summary      Get user notification preferences
+// @Tags         Notifications
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true  "User uuid"
+// @Param        password    query    string  true  "User password"
+// @Success      200  {object}  Preferences
+// @Router       /users/{uuid}/notifications [get]
+func (h *handler) GetPreferences(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER NOTIFICATION PREFERENCES")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("get password from URL")
+	password := r.URL.Query().Get("password")
+	if password == "" && user.APIKeyOwner(r.Context()) == "" {
+		return apperror.NewAppError(nil, "invalid query parameter password", "password is required", "WeatherService-000004")
+	}
+
+	prefs, err := h.NotifyService.GetPreferences(r.Context(), params.ByName("uuid"), password)
+	if err != nil {
+		return err
+	}
+
+	return writePreferences(w, prefs)
+}
+
+// UpdatePreferences godoc
+// @Summary      Update user notification preferences
+// @Description  Turn alert and digest emails on or off. Omitted fields are not changed
+// @Tags         Notifications
+// @Accept       json
+// @Produce      json
+// @Param        uuid    path     string  true  "User uuid"
+// @Param        dto     body     UpdatePreferencesDTO  true  "Preferences"
+// @Success      200  {object}  Preferences
+// @Router       /users/{uuid}/notifications [patch]
+func (h *handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("UPDATE USER NOTIFICATION PREFERENCES")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("decode update preferences dto")
+	var dto UpdatePreferencesDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme. check swagger API")
+	}
+	dto.UUID = params.ByName("uuid")
+
+	prefs, err := h.NotifyService.UpdatePreferences(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	return writePreferences(w, prefs)
+}
+
+// UnsubscribeForm godoc
+// @Summary      Unsubscribe confirmation page
+// @Description  Page of the unsubscribe link of notification emails. Opening the link does not unsubscribe, so link scanners do not; the page submits the POST
+// @Tags         Notifications
+// @Produce      html
+// @Param        token    query    string  true  "Signed unsubscribe token"
+// @Success      200
+// @Router       /unsubscribe [get]
+func (h *handler) UnsubscribeForm(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("UNSUBSCRIBE FORM")
+
+	return h.unsubscribePage(w, r, false)
+}
+
+// Unsubscribe godoc
+// @Summary      Unsubscribe from notification emails
+// @Description  Turns off the notifications of the signed token. Also serves one-click unsubscribe (RFC 8058) of the List-Unsubscribe header
+// @Tags         Notifications
+// @Produce      html
+// @Param        token    query    string  true  "Signed unsubscribe token"
+// @Success      200
+// @Router       /unsubscribe [post]
+func (h *handler) Unsubscribe(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("UNSUBSCRIBE")
+
+	return h.unsubscribePage(w, r, true)
+}
+
+func (h *handler) unsubscribePage(w http.ResponseWriter, r *http.Request, confirmed bool) error {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return apperror.NewAppError(nil, "invalid unsubscribe token", "token is required", "WeatherService-000004")
+	}
+
+	page, err := h.NotifyService.Unsubscribe(r.Context(), token, confirmed)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page)
+	return nil
+}
+
+func writePreferences(w http.ResponseWriter, prefs Preferences) error {
+	prefsBytes, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("failed to marshall notification preferences. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(prefsBytes)
+	return nil
+}
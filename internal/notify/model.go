@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"WeatherServiceAPI/internal/digest"
+	"time"
+)
+
+// Kind is a kind of email notifications a user can unsubscribe from.
+type Kind string
+
+const (
+	KindAlerts Kind = "alerts"
+	KindDigest Kind = "digest"
+	// KindAll unsubscribes from every kind.
+	KindAll Kind = "all"
+)
+
+// Preferences are the email notifications a user receives. Users without
+// stored preferences get alert emails and no digest.
+type Preferences struct {
+	UserUUID    string `json:"user_uuid"`
+	EmailAlerts bool   `json:"email_alerts"`
+	EmailDigest bool   `json:"email_digest"`
+	// DigestTime is the local time, HH:MM in Timezone, the digest is sent
+	// at every day.
+	DigestTime  string    `json:"digest_time" example:"07:00"`
+	Timezone    string    `json:"timezone" example:"Europe/Moscow"`
+	DigestHours int       `json:"digest_hours" example:"24"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// digestTimeFormat is the format of Preferences.DigestTime.
+const digestTimeFormat = "15:04"
+
+const defaultDigestTime = "07:00"
+
+// DefaultPreferences are the preferences of a user who has not changed them.
+func DefaultPreferences(userUUID string) Preferences {
+	return Preferences{
+		UserUUID:    userUUID,
+		EmailAlerts: true,
+		DigestTime:  defaultDigestTime,
+		Timezone:    "UTC",
+		DigestHours: digest.MinHours,
+	}
+}
+
+// disable turns off the notifications of kind.
+func (p *Preferences) disable(kind Kind) {
+	if kind == KindAlerts || kind == KindAll {
+		p.EmailAlerts = false
+	}
+	if kind == KindDigest || kind == KindAll {
+		p.EmailDigest = false
+	}
+}
+
+type UpdatePreferencesDTO struct {
+	UUID        string  `json:"uuid,omitempty"`
+	Password    string  `json:"password"`
+	EmailAlerts *bool   `json:"email_alerts,omitempty"`
+	EmailDigest *bool   `json:"email_digest,omitempty"`
+	DigestTime  *string `json:"digest_time,omitempty" example:"07:00"`
+	Timezone    *string `json:"timezone,omitempty" example:"Europe/Moscow"`
+	DigestHours *int    `json:"digest_hours,omitempty" example:"24"`
+}
@@ -0,0 +1,336 @@
+package notify
+
+import (
+	"WeatherServiceAPI/internal/alert"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/digest"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/mail"
+	"WeatherServiceAPI/pkg/units"
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"strconv"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+// emailTimeFormat formats times in emails. Alert times are in UTC, digest
+// times in the timezone of the user.
+const emailTimeFormat = "Mon, 02 Jan 15:04"
+
+// email is the data of the email templates.
+type email struct {
+	Data           interface{}
+	UnsubscribeURL string
+}
+
+type service struct {
+	storage     Storage
+	logger      *logging.Logger
+	userService user.Service
+	sender      mail.Sender
+	signer      Signer
+	baseURL     string
+
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// NewService creates the notifications service. Emails are sent with sender,
+// a nil sender disables them. Unsubscribe links point to baseURL.
+func NewService(storage Storage, logger *logging.Logger, userService user.Service, sender mail.Sender, signer Signer, baseURL string) (Service, error) {
+	text, err := texttemplate.ParseFS(templatesFS, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email templates. error: %w", err)
+	}
+	html, err := htmltemplate.ParseFS(templatesFS, "templates/*.html.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email templates. error: %w", err)
+	}
+
+	return &service{
+		storage:     storage,
+		logger:      logger,
+		userService: userService,
+		sender:      sender,
+		signer:      signer,
+		baseURL:     baseURL,
+		text:        text,
+		html:        html,
+	}, nil
+}
+
+type Service interface {
+	GetPreferences(ctx context.Context, userUUID, password string) (Preferences, error)
+	UpdatePreferences(ctx context.Context, dto UpdatePreferencesDTO) (Preferences, error)
+	// Unsubscribe verifies a signed unsubscribe token and renders the
+	// confirmation page, or turns the notifications of the token off and
+	// renders the result page when confirmed.
+	Unsubscribe(ctx context.Context, token string, confirmed bool) ([]byte, error)
+
+	NotifyAlert(ctx context.Context, a alert.Alert) error
+	NotifyDigest(ctx context.Context, d digest.Digest) error
+}
+
+func (s *service) GetPreferences(ctx context.Context, userUUID, password string) (Preferences, error) {
+	u, err := s.authenticate(ctx, userUUID, password)
+	if err != nil {
+		return Preferences{}, err
+	}
+	return s.preferences(ctx, u.UUID)
+}
+
+func (s *service) UpdatePreferences(ctx context.Context, dto UpdatePreferencesDTO) (Preferences, error) {
+	u, err := s.authenticate(ctx, dto.UUID, dto.Password)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	prefs, err := s.preferences(ctx, u.UUID)
+	if err != nil {
+		return Preferences{}, err
+	}
+	if dto.EmailAlerts != nil {
+		prefs.EmailAlerts = *dto.EmailAlerts
+	}
+	if dto.EmailDigest != nil {
+		prefs.EmailDigest = *dto.EmailDigest
+	}
+	if dto.DigestTime != nil {
+		digestTime, err := time.Parse(digestTimeFormat, *dto.DigestTime)
+		if err != nil {
+			return Preferences{}, apperror.NewAppError(err, "invalid digest time", "expected HH:MM", "WeatherService-000004")
+		}
+		prefs.DigestTime = digestTime.Format(digestTimeFormat)
+	}
+	if dto.Timezone != nil {
+		if _, err = time.LoadLocation(*dto.Timezone); err != nil || *dto.Timezone == "" || *dto.Timezone == "Local" {
+			return Preferences{}, apperror.NewAppError(err, "invalid timezone", "expected an IANA timezone like Europe/Moscow", "WeatherService-000004")
+		}
+		// a timezone unknown to the database would fail the digest claim of
+		// every user, not only of this one
+		exists, err := s.storage.TimezoneExists(ctx, *dto.Timezone)
+		if err != nil {
+			return Preferences{}, fmt.Errorf("failed to check timezone. error: %w", err)
+		}
+		if !exists {
+			return Preferences{}, apperror.NewAppError(nil, "invalid timezone", "expected an IANA timezone like Europe/Moscow", "WeatherService-000004")
+		}
+		prefs.Timezone = *dto.Timezone
+	}
+	if dto.DigestHours != nil {
+		if *dto.DigestHours < digest.MinHours || *dto.DigestHours > digest.MaxHours {
+			return Preferences{}, apperror.NewAppError(nil, "invalid digest period", fmt.Sprintf("digest_hours must be from %d to %d", digest.MinHours, digest.MaxHours), "WeatherService-000004")
+		}
+		prefs.DigestHours = *dto.DigestHours
+	}
+	prefs.UpdatedAt = time.Now().UTC()
+
+	if err = s.storage.Save(ctx, prefs); err != nil {
+		return Preferences{}, fmt.Errorf("failed to save notification preferences. error: %w", err)
+	}
+	return prefs, nil
+}
+
+func (s *service) Unsubscribe(ctx context.Context, token string, confirmed bool) ([]byte, error) {
+	logger := logging.FromContext(ctx)
+
+	userUUID, kind, ok := s.signer.Parse(token, time.Now())
+	if !ok {
+		return nil, apperror.NewAppError(nil, "invalid unsubscribe token", "", "WeatherService-000004")
+	}
+	if _, err := s.userService.GetOne(ctx, userUUID); err != nil {
+		return nil, err
+	}
+
+	if confirmed {
+		prefs, err := s.preferences(ctx, userUUID)
+		if err != nil {
+			return nil, err
+		}
+		prefs.disable(kind)
+		prefs.UpdatedAt = time.Now().UTC()
+
+		logger.Debugf("unsubscribe user %s from %s", userUUID, kind)
+		if err = s.storage.Save(ctx, prefs); err != nil {
+			return nil, fmt.Errorf("failed to save notification preferences. error: %w", err)
+		}
+	}
+
+	description := map[Kind]string{
+		KindAlerts: "weather alert emails",
+		KindDigest: "weather digest emails",
+		KindAll:    "all emails of the weather service",
+	}[kind]
+
+	var buf bytes.Buffer
+	err := s.html.ExecuteTemplate(&buf, "unsubscribe.html.tmpl", map[string]interface{}{
+		"Done":        confirmed,
+		"Description": description,
+		"Action":      unsubscribeURL + "?token=" + url.QueryEscape(token),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render unsubscribe page. error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *service) NotifyAlert(ctx context.Context, a alert.Alert) error {
+	if s.sender == nil {
+		return nil
+	}
+
+	prefs, err := s.preferences(ctx, a.UserUUID)
+	if err != nil {
+		return err
+	}
+	if !prefs.EmailAlerts {
+		return nil
+	}
+
+	u, err := s.userService.GetOne(ctx, a.UserUUID)
+	if err != nil {
+		return fmt.Errorf("failed to find user of alert. error: %w", err)
+	}
+
+	data := map[string]interface{}{
+		"CityName": a.CityName,
+		"Rule":     a.Rule,
+		"Metric":   a.Metric,
+		"Value":    strconv.FormatFloat(a.Value, 'f', -1, 64),
+		"Unit":     a.Metric.Unit(),
+		"SlotTime": a.SlotTime.UTC().Format(emailTimeFormat),
+		"Until":    a.Until.UTC().Format(emailTimeFormat),
+	}
+	subject := fmt.Sprintf("Weather alert: %s in %s", a.Rule, a.CityName)
+
+	return s.send(ctx, u, KindAlerts, subject, "alert", data)
+}
+
+// digestCity is a city of the digest email with formatted quantities.
+type digestCity struct {
+	Name        string
+	Country     string
+	Available   bool
+	Description string
+	Temp        string
+	Pop         string
+	Rain        string
+	Snow        string
+	Wind        string
+	Gust        string
+}
+
+// NotifyDigest emails the digest built by the scheduler, which only builds
+// digests of users with the digest turned on.
+func (s *service) NotifyDigest(ctx context.Context, d digest.Digest) error {
+	if s.sender == nil {
+		return nil
+	}
+
+	u, err := s.userService.GetOne(ctx, d.UserUUID)
+	if err != nil {
+		return fmt.Errorf("failed to find user of digest. error: %w", err)
+	}
+
+	labels := d.Units
+	if labels == nil {
+		labels = &units.Labels{}
+	}
+	format := func(v float64, unit string) string {
+		return strconv.FormatFloat(v, 'f', 1, 64) + " " + unit
+	}
+
+	cities := make([]digestCity, len(d.Cities))
+	for i, c := range d.Cities {
+		cities[i] = digestCity{
+			Name:        c.Name,
+			Country:     c.Country,
+			Available:   c.Available,
+			Description: c.Description,
+			Temp:        fmt.Sprintf("%s..%s", strconv.FormatFloat(c.TempMin, 'f', 1, 64), format(c.TempMax, labels.Temperature)),
+			Pop:         strconv.FormatFloat(c.Pop*100, 'f', 0, 64) + "%",
+			Rain:        format(c.Rain, labels.Precipitation),
+			Snow:        format(c.Snow, labels.Precipitation),
+			Wind:        format(c.WindSpeed, labels.WindSpeed),
+			Gust:        format(c.WindGust, labels.WindSpeed),
+		}
+	}
+
+	data := map[string]interface{}{
+		"From":     d.From.Format(emailTimeFormat),
+		"To":       d.To.Format(emailTimeFormat),
+		"Timezone": d.Timezone,
+		"Cities":   cities,
+	}
+	subject := fmt.Sprintf("Weather digest for %s", d.From.Format("Mon, 02 Jan"))
+
+	return s.send(ctx, u, KindDigest, subject, "digest", data)
+}
+
+// send renders the text and HTML templates name and sends them to the user
+// with an unsubscribe link from kind. Users who have not verified their email
+// get no emails.
+func (s *service) send(ctx context.Context, u user.User, kind Kind, subject, name string, data interface{}) error {
+	if !u.Verified() {
+		s.logger.Debugf("%s email to user %s skipped, the email is not verified", name, u.UUID)
+		return nil
+	}
+
+	link := fmt.Sprintf("%s%s?token=%s", s.baseURL, unsubscribeURL, url.QueryEscape(s.signer.Token(u.UUID, kind, time.Now())))
+	e := email{Data: data, UnsubscribeURL: link}
+
+	var text, html bytes.Buffer
+	if err := s.text.ExecuteTemplate(&text, name+".txt.tmpl", e); err != nil {
+		return fmt.Errorf("failed to render %s email. error: %w", name, err)
+	}
+	if err := s.html.ExecuteTemplate(&html, name+".html.tmpl", e); err != nil {
+		return fmt.Errorf("failed to render %s email. error: %w", name, err)
+	}
+
+	err := s.sender.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + link + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+	if errors.Is(err, mail.ErrRateLimited) {
+		s.logger.Warnf("%s email to user %s dropped: %v", name, u.UUID, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send %s email. error: %w", name, err)
+	}
+	return nil
+}
+
+// preferences returns the stored preferences of the user or the defaults.
+func (s *service) preferences(ctx context.Context, userUUID string) (Preferences, error) {
+	prefs, err := s.storage.Find(ctx, userUUID)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return DefaultPreferences(userUUID), nil
+	}
+	if err != nil {
+		return Preferences{}, fmt.Errorf("failed to find notification preferences. error: %w", err)
+	}
+	return prefs, nil
+}
+
+// authenticate checks the password of the user through the user service, so
+// failed checks count towards the lockout of the account.
+func (s *service) authenticate(ctx context.Context, userUUID, password string) (user.User, error) {
+	return s.userService.Authenticate(ctx, userUUID, password, user.AuthActionNotifications)
+}
@@ -0,0 +1,12 @@
+package notify
+
+import "context"
+
+type Storage interface {
+	// Find returns the stored preferences of the user or apperror.ErrNotFound.
+	Find(ctx context.Context, userUUID string) (Preferences, error)
+	Save(ctx context.Context, prefs Preferences) error
+	// TimezoneExists reports whether the database knows the timezone, the
+	// digests are scheduled in it by the database.
+	TimezoneExists(ctx context.Context, name string) (bool, error)
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs unsubscribe tokens. A token is the user uuid, the kind and the
+// expiry, base64url encoded, and their HMAC-SHA256, so links in emails keep
+// working without storing anything and cannot be forged for other users.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewSigner creates a signer of tokens valid for ttl.
+func NewSigner(secret string, ttl time.Duration) Signer {
+	return Signer{secret: []byte(secret), ttl: ttl}
+}
+
+// Token returns the unsubscribe token of the user from kind issued at now.
+func (s Signer) Token(userUUID string, kind Kind, now time.Time) string {
+	payload := userUUID + ":" + string(kind) + ":" + strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// Parse verifies the token and returns its user uuid and kind. Tokens expired
+// at now are rejected.
+func (s Signer) Parse(token string, now time.Time) (string, Kind, bool) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, s.mac(string(payload))) {
+		return "", "", false
+	}
+
+	parts := strings.Split(string(payload), ":")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	userUUID, kind := parts[0], parts[1]
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return "", "", false
+	}
+	switch Kind(kind) {
+	case KindAlerts, KindDigest, KindAll:
+		return userUUID, Kind(kind), true
+	}
+	return "", "", false
+}
+
+func (s Signer) mac(payload string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("unsubscribe:" + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerParse(t *testing.T) {
+	signer := NewSigner("secret", time.Hour)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	userUUID := "5b6bd7b1-6c4b-4b0e-8d55-4c1f3c1b7c2e"
+	token := signer.Token(userUUID, KindAlerts, now)
+
+	// sign builds a token with a valid MAC over any payload
+	sign := func(payload string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(signer.mac(payload))
+	}
+	expires := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+	encodedPayload, encodedMAC, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name     string
+		signer   Signer
+		token    string
+		now      time.Time
+		wantUUID string
+		wantKind Kind
+		wantOK   bool
+	}{
+		{name: "valid", signer: signer, token: token, now: now, wantUUID: userUUID, wantKind: KindAlerts, wantOK: true},
+		{name: "valid until expiry", signer: signer, token: token, now: now.Add(time.Hour - time.Second), wantUUID: userUUID, wantKind: KindAlerts, wantOK: true},
+		{name: "all kinds", signer: signer, token: signer.Token(userUUID, KindAll, now), now: now, wantUUID: userUUID, wantKind: KindAll, wantOK: true},
+		{name: "expired", signer: signer, token: token, now: now.Add(time.Hour)},
+		{name: "other secret", signer: NewSigner("other", time.Hour), token: token, now: now},
+		{name: "tampered payload", signer: signer, token: base64.RawURLEncoding.EncodeToString([]byte("other-uuid:alerts:"+expires)) + "." + encodedMAC, now: now},
+		{name: "tampered mac", signer: signer, token: encodedPayload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")), now: now},
+		{name: "extended expiry", signer: signer, token: base64.RawURLEncoding.EncodeToString([]byte(userUUID+":alerts:9999999999")) + "." + encodedMAC, now: now},
+		{name: "wrong kind", signer: signer, token: sign(userUUID + ":weekly:" + expires), now: now},
+		{name: "invalid expiry", signer: signer, token: sign(userUUID + ":alerts:soon"), now: now},
+		{name: "without expiry", signer: signer, token: sign(userUUID + ":alerts"), now: now},
+		{name: "no separator", signer: signer, token: encodedPayload, now: now},
+		{name: "not base64", signer: signer, token: "%%%." + encodedMAC, now: now},
+		{name: "empty", signer: signer, token: "", now: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUUID, gotKind, gotOK := tt.signer.Parse(tt.token, tt.now)
+			if gotUUID != tt.wantUUID || gotKind != tt.wantKind || gotOK != tt.wantOK {
+				t.Errorf("Parse() = %q, %q, %t, want %q, %q, %t", gotUUID, gotKind, gotOK, tt.wantUUID, tt.wantKind, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"time"
+)
+
+var _ ratelimit.Storage = &db{}
+
+// db keeps one row per key, restarted when a new window begins, in an
+// unlogged table: counters are cheap to lose in a crash.
+type db struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func (d db) Take(ctx context.Context, key string, start time.Time, window time.Duration) (count int, err error) {
+	q := `INSERT INTO rate_limit_counters (key, window_start, count, expires_at) VALUES ($1, $2, 1, $3)
+ON CONFLICT (key) DO UPDATE SET
+    count = CASE WHEN rate_limit_counters.window_start = EXCLUDED.window_start THEN rate_limit_counters.count + 1 ELSE 1 END,
+    window_start = EXCLUDED.window_start,
+    expires_at = EXCLUDED.expires_at
+RETURNING count;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err = d.client.QueryRow(ctx, q, key, start, start.Add(window)).Scan(&count); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return 0, newErr
+		}
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (d db) DeleteExpired(ctx context.Context, now time.Time) error {
+	q := `DELETE FROM rate_limit_counters WHERE expires_at <= $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	_, err := d.client.Exec(ctx, q, now)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func NewStorage(client postgresql.Client, logger *logging.Logger) ratelimit.Storage {
+	return &db{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,258 @@
+package ratelimit
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/julienschmidt/httprouter"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderLimit     = "X-RateLimit-Limit"
+	HeaderRemaining = "X-RateLimit-Remaining"
+	HeaderReset     = "X-RateLimit-Reset"
+)
+
+// Policy allows Limit requests per Window. A zero Limit disables it.
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (p Policy) enabled() bool {
+	return p.Limit > 0 && p.Window > 0
+}
+
+// Result is the state of a limit after a request.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RetryAfter returns the whole seconds until the window of the result ends.
+func (r Result) RetryAfter(now time.Time) int {
+	return int(r.Reset.Sub(now).Seconds()) + 1
+}
+
+// Options configure the limiter.
+type Options struct {
+	// Default limits every request of a client. Requests made with an API
+	// key are limited by the key instead.
+	Default Policy
+	// Auth limits password checking endpoints per client, Account per
+	// account they are made for, so both a single client trying many
+	// accounts and many clients trying one account are stopped.
+	Auth    Policy
+	Account Policy
+	// TrustProxy takes the client address from the last X-Forwarded-For
+	// entry, set by the reverse proxy in front of the service.
+	TrustProxy bool
+}
+
+type clientContextKey struct{}
+
+// ContextWithClient identifies the client of the request, like an API key,
+// instead of its address.
+func ContextWithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// Limiter counts requests in fixed windows. Storage errors are logged and let
+// requests through, so the API keeps working when counters are unavailable.
+type Limiter struct {
+	storage Storage
+	logger  *logging.Logger
+	opts    Options
+}
+
+func NewLimiter(storage Storage, logger *logging.Logger, opts Options) *Limiter {
+	return &Limiter{
+		storage: storage,
+		logger:  logger,
+		opts:    opts,
+	}
+}
+
+// Allow counts a request of key against the policy.
+func (l *Limiter) Allow(ctx context.Context, key string, policy Policy, now time.Time) Result {
+	start := now.Truncate(policy.Window)
+	result := Result{Allowed: true, Limit: policy.Limit, Remaining: policy.Limit, Reset: start.Add(policy.Window)}
+
+	count, err := l.storage.Take(ctx, key, start, policy.Window)
+	if err != nil {
+		l.logger.Errorf("failed to count request of %s. error: %v", key, err)
+		return result
+	}
+
+	result.Allowed = count <= policy.Limit
+	if count < policy.Limit {
+		result.Remaining = policy.Limit - count
+	} else {
+		result.Remaining = 0
+	}
+	return result
+}
+
+// Middleware applies the default policy to every request of a client.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.opts.Default.enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := r.Context().Value(clientContextKey{}).(string); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		result := l.Allow(r.Context(), "default:"+l.client(r), l.opts.Default, now)
+		w.Header().Set(HeaderLimit, strconv.Itoa(result.Limit))
+		w.Header().Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+		w.Header().Set(HeaderReset, strconv.FormatInt(result.Reset.Unix(), 10))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(result.RetryAfter(now)))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(apperror.ErrTooManyRequests.Localize(i18n.FromContext(r.Context())).Marshal())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Auth wraps a handler checking passwords with the auth and account
+// policies. It is composed with apperror.Middleware, which answers
+// apperror.ErrTooManyRequests with 429:
+//
+//	apperror.Middleware(limiter.Auth(h.GetUserByEmailAndPassword))
+//
+// The account is the email query parameter or the uuid path parameter.
+func (l *Limiter) Auth(h func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		now := time.Now()
+		if result := l.allowAuth(r.Context(), l.client(r), account(r), now); !result.Allowed {
+			return l.reject(w, r, result, now)
+		}
+
+		return h(w, r)
+	}
+}
+
+// AllowDefault counts a request made outside of Middleware, like a gRPC call,
+// against the default policy. ip is the address of the client, which shares
+// its limit with its HTTP requests.
+func (l *Limiter) AllowDefault(ctx context.Context, ip string, now time.Time) Result {
+	if !l.opts.Default.enabled() {
+		return Result{Allowed: true}
+	}
+	return l.Allow(ctx, "default:ip:"+ip, l.opts.Default, now)
+}
+
+// AllowAuth counts a password check for account made outside of a handler
+// wrapped with Auth, like a WebSocket message or a gRPC call. ip is the
+// address of the client, account the email or uuid the password is checked
+// for.
+func (l *Limiter) AllowAuth(ctx context.Context, ip, account string, now time.Time) Result {
+	client := "ip:" + ip
+	if c, ok := ctx.Value(clientContextKey{}).(string); ok {
+		client = c
+	}
+	return l.allowAuth(ctx, client, hashAccount(account), now)
+}
+
+// allowAuth applies the auth policy to client and the account policy to
+// account. The result is the first limit exceeded, or allowed.
+func (l *Limiter) allowAuth(ctx context.Context, client, account string, now time.Time) Result {
+	if l.opts.Auth.enabled() {
+		if result := l.Allow(ctx, "auth:"+client, l.opts.Auth, now); !result.Allowed {
+			return result
+		}
+	}
+	if account != "" && l.opts.Account.enabled() {
+		if result := l.Allow(ctx, "account:"+account, l.opts.Account, now); !result.Allowed {
+			return result
+		}
+	}
+	return Result{Allowed: true}
+}
+
+func (l *Limiter) reject(w http.ResponseWriter, r *http.Request, result Result, now time.Time) error {
+	logging.FromContext(r.Context()).Warnf("rate limit of %s exceeded", l.client(r))
+	w.Header().Set("Retry-After", strconv.Itoa(result.RetryAfter(now)))
+	return apperror.ErrTooManyRequests
+}
+
+// Run deletes expired counters every interval until ctx is done.
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := l.storage.DeleteExpired(ctx, now); err != nil {
+				l.logger.Errorf("failed to delete expired rate limit counters. error: %v", err)
+			}
+		}
+	}
+}
+
+// client returns the client set by ContextWithClient or the address of the
+// request.
+func (l *Limiter) client(r *http.Request) string {
+	if client, ok := r.Context().Value(clientContextKey{}).(string); ok {
+		return client
+	}
+	return "ip:" + l.ClientIP(r)
+}
+
+// ClientIP returns the address of the client of the request, taken from
+// X-Forwarded-For when the proxy is trusted.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	if l.opts.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			entries := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// account returns the hashed account a request is made for, so counters do
+// not store emails.
+func account(r *http.Request) string {
+	account := r.URL.Query().Get("email")
+	if account == "" {
+		account = httprouter.ParamsFromContext(r.Context()).ByName("uuid")
+	}
+	return hashAccount(account)
+}
+
+func hashAccount(account string) string {
+	account = strings.ToLower(strings.TrimSpace(account))
+	if account == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(account))
+	return hex.EncodeToString(sum[:16])
+}
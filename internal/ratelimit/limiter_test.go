@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type failingStorage struct{}
+
+func (failingStorage) Take(ctx context.Context, key string, start time.Time, window time.Duration) (int, error) {
+	return 0, errors.New("storage is down")
+}
+
+func (failingStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+func TestLimiterAllow(t *testing.T) {
+	policy := Policy{Limit: 2, Window: time.Minute}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	reset := start.Add(time.Minute)
+
+	type request struct {
+		key string
+		at  time.Time
+	}
+	tests := []struct {
+		name     string
+		storage  Storage
+		requests []request
+		want     Result
+	}{
+		{
+			name:     "first request",
+			storage:  NewMemoryStorage(),
+			requests: []request{{"a", start}},
+			want:     Result{Allowed: true, Limit: 2, Remaining: 1, Reset: reset},
+		},
+		{
+			name:     "at the limit",
+			storage:  NewMemoryStorage(),
+			requests: []request{{"a", start}, {"a", start.Add(time.Second)}},
+			want:     Result{Allowed: true, Limit: 2, Remaining: 0, Reset: reset},
+		},
+		{
+			name:     "over the limit",
+			storage:  NewMemoryStorage(),
+			requests: []request{{"a", start}, {"a", start}, {"a", start.Add(59 * time.Second)}},
+			want:     Result{Allowed: false, Limit: 2, Remaining: 0, Reset: reset},
+		},
+		{
+			name:     "next window",
+			storage:  NewMemoryStorage(),
+			requests: []request{{"a", start}, {"a", start}, {"a", start}, {"a", reset}},
+			want:     Result{Allowed: true, Limit: 2, Remaining: 1, Reset: reset.Add(time.Minute)},
+		},
+		{
+			name:     "keys are counted apart",
+			storage:  NewMemoryStorage(),
+			requests: []request{{"a", start}, {"a", start}, {"a", start}, {"b", start}},
+			want:     Result{Allowed: true, Limit: 2, Remaining: 1, Reset: reset},
+		},
+		{
+			name:     "storage errors fail open",
+			storage:  failingStorage{},
+			requests: []request{{"a", start}, {"a", start}, {"a", start}},
+			want:     Result{Allowed: true, Limit: 2, Remaining: 2, Reset: reset},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.storage, logging.GetLogger(), Options{})
+
+			var got Result
+			for _, r := range tt.requests {
+				got = l.Allow(context.Background(), r.key, policy, r.at)
+			}
+			if got != tt.want {
+				t.Errorf("Allow() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+	r := Result{Reset: now.Add(30 * time.Second)}
+
+	if got := r.RetryAfter(now); got != 31 {
+		t.Errorf("RetryAfter() = %d, want 31", got)
+	}
+}
+
+func TestLimiterAllowDefaultSharesHTTPLimit(t *testing.T) {
+	l := NewLimiter(NewMemoryStorage(), logging.GetLogger(), Options{Default: Policy{Limit: 2, Window: time.Minute}})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	l.Allow(context.Background(), "default:ip:192.0.2.1", l.opts.Default, now)
+	if got := l.AllowDefault(context.Background(), "192.0.2.1", now); !got.Allowed {
+		t.Fatalf("second request rejected: %+v", got)
+	}
+	if got := l.AllowDefault(context.Background(), "192.0.2.1", now); got.Allowed {
+		t.Errorf("third request allowed: %+v", got)
+	}
+	if got := l.AllowDefault(context.Background(), "192.0.2.2", now); !got.Allowed {
+		t.Errorf("request of another client rejected: %+v", got)
+	}
+
+	disabled := NewLimiter(NewMemoryStorage(), logging.GetLogger(), Options{})
+	if got := disabled.AllowDefault(context.Background(), "192.0.2.1", now); !got.Allowed {
+		t.Errorf("request rejected without a default policy: %+v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Storage = &memoryStorage{}
+
+type counter struct {
+	start   time.Time
+	expires time.Time
+	count   int
+}
+
+type memoryStorage struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+}
+
+// NewMemoryStorage returns a storage keeping counters in memory of the
+// instance.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{counters: make(map[string]*counter)}
+}
+
+func (m *memoryStorage) Take(ctx context.Context, key string, start time.Time, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.counters[key]
+	if !ok || !c.start.Equal(start) {
+		c = &counter{start: start, expires: start.Add(window)}
+		m.counters[key] = c
+	}
+	c.count++
+	return c.count, nil
+}
+
+func (m *memoryStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, c := range m.counters {
+		if !now.Before(c.expires) {
+			delete(m.counters, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Storage keeps request counters of fixed windows. The memory storage limits
+// every instance on its own, the Postgres one shares counters between
+// instances.
+type Storage interface {
+	// Take counts a request of key in the window starting at start and
+	// returns the requests of the window, including this one.
+	Take(ctx context.Context, key string, start time.Time, window time.Duration) (int, error)
+	// DeleteExpired removes the counters of windows ended before now.
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/pkg/units"
+	"WeatherServiceAPI/pkg/weatherpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// presentation reads the units and language of p, falling back to userUnits
+// and defaultLang.
+func presentation(p *weatherpb.Presentation, userUnits, defaultLang string) (units.Units, string, error) {
+	system, pressure, lang := p.GetUnits(), p.GetPressure(), defaultLang
+	if system == "" {
+		system = userUnits
+	}
+	if p.GetLang() != "" {
+		lang = p.GetLang()
+	}
+
+	u, err := units.Parse(system, pressure)
+	if err != nil {
+		return u, lang, apperror.NewAppError(err, err.Error(), "", "WeatherService-000004")
+	}
+	return u, lang, nil
+}
+
+func toCity(city cityClient.CityData) *weatherpb.City {
+	return &weatherpb.City{
+		Id:      city.Id,
+		Name:    city.Name,
+		Country: city.Country,
+		Lat:     city.Lat,
+		Lon:     city.Lon,
+	}
+}
+
+func toCities(cities []cityClient.CityData) []*weatherpb.City {
+	converted := make([]*weatherpb.City, len(cities))
+	for i, city := range cities {
+		converted[i] = toCity(city)
+	}
+	return converted
+}
+
+func toUnits(labels units.Labels) *weatherpb.Units {
+	return &weatherpb.Units{
+		System:        string(labels.System),
+		Temperature:   labels.Temperature,
+		WindSpeed:     labels.WindSpeed,
+		Pressure:      string(labels.Pressure),
+		Visibility:    labels.Visibility,
+		Precipitation: labels.Precipitation,
+	}
+}
+
+// toSlot converts a slot already converted to the requested units and
+// language.
+func toSlot(f weatherClient.Forecast) *weatherpb.ForecastSlot {
+	slot := &weatherpb.ForecastSlot{
+		Time:       timestamppb.New(f.Time()),
+		Temp:       f.Main.Temp,
+		FeelsLike:  f.Main.FeelsLike,
+		TempMin:    f.Main.TempMin,
+		TempMax:    f.Main.TempMax,
+		Pressure:   f.Main.Pressure,
+		Humidity:   int32(f.Main.Humidity),
+		WindSpeed:  f.Wind.Speed,
+		WindDeg:    int32(f.Wind.Deg),
+		WindGust:   f.Wind.Gust,
+		Clouds:     int32(f.Clouds.All),
+		Visibility: f.Visibility,
+		Pop:        f.Pop,
+		Rain_3H:    f.Rain.ThreeH,
+		Snow_3H:    f.Snow.ThreeH,
+	}
+	if len(f.Weather) > 0 {
+		slot.ConditionId = int32(f.Weather[0].ID)
+		slot.Condition = f.Weather[0].Main
+		slot.Description = f.Weather[0].Description
+		slot.Icon = f.Weather[0].Icon
+	}
+	return slot
+}
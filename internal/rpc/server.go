@@ -0,0 +1,198 @@
+package rpc
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/events"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/weatherpb"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewServer returns a gRPC server with WeatherService and UserService backed
+// by the same services as the REST API. Calls are limited by the default rate
+// limit of limiter and credentials by its auth limits.
+func NewServer(logger *logging.Logger, cityService cityClient.Service, weatherService weatherClient.Service, userService user.Service, broker *events.Broker, limiter *ratelimit.Limiter) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptor(logger, limiter)),
+		grpc.ChainStreamInterceptor(streamInterceptor(logger, limiter)),
+	)
+
+	weatherpb.RegisterWeatherServiceServer(server, &weatherServer{
+		cityService:    cityService,
+		weatherService: weatherService,
+		userService:    userService,
+		broker:         broker,
+		limiter:        limiter,
+	})
+	weatherpb.RegisterUserServiceServer(server, &userServer{
+		cityService: cityService,
+		userService: userService,
+		limiter:     limiter,
+	})
+
+	return server
+}
+
+// Mux sends gRPC requests (HTTP/2 with an application/grpc content type) to
+// server and everything else to next, so both can share a listener. The
+// listener must accept cleartext HTTP/2, see h2c.NewHandler.
+func Mux(server *grpc.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			// streams outlive the server write timeout
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			server.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRequest stores the request logger and the language given by the
+// accept-language metadata in ctx.
+func withRequest(ctx context.Context, logger *logging.Logger, method string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := first(md, strings.ToLower(logging.RequestIDHeader))
+	if requestID == "" || len(requestID) > 128 {
+		requestID = logging.NewRequestID()
+	}
+	logger = logger.GetLoggerWithField("request_id", requestID).GetLoggerWithField("method", method)
+
+	client := user.Client{UserAgent: first(md, "user-agent")}
+	if p, ok := peer.FromContext(ctx); ok {
+		client.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client.IP); err == nil {
+			client.IP = host
+		}
+	}
+
+	ctx = logging.ContextWithLogger(ctx, logger)
+	ctx = user.ContextWithClient(ctx, client)
+	return i18n.ContextWithLang(ctx, i18n.Match(first(md, "accept-language")))
+}
+
+// allow applies the default rate limit of the client to a call and sets the
+// retry-after header when it is exceeded.
+func allow(ctx context.Context, limiter *ratelimit.Limiter) error {
+	now := time.Now()
+	result := limiter.AllowDefault(ctx, user.ClientFromContext(ctx).IP, now)
+	if result.Allowed {
+		return nil
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(result.RetryAfter(now))))
+	return apperror.ErrTooManyRequests
+}
+
+func unaryInterceptor(logger *logging.Logger, limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = withRequest(ctx, logger, info.FullMethod)
+
+		start := time.Now()
+		var resp interface{}
+		err := allow(ctx, limiter)
+		if err == nil {
+			resp, err = handler(ctx, req)
+		}
+		err = statusError(ctx, err)
+
+		logging.FromContext(ctx).
+			GetLoggerWithField("code", status.Code(err).String()).
+			GetLoggerWithField("duration", time.Since(start).String()).
+			Info("rpc handled")
+		return resp, err
+	}
+}
+
+func streamInterceptor(logger *logging.Logger, limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := withRequest(ss.Context(), logger, info.FullMethod)
+
+		start := time.Now()
+		err := allow(ctx, limiter)
+		if err == nil {
+			err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		}
+		err = statusError(ctx, err)
+
+		logging.FromContext(ctx).
+			GetLoggerWithField("code", status.Code(err).String()).
+			GetLoggerWithField("duration", time.Since(start).String()).
+			Info("rpc stream closed")
+		return err
+	}
+}
+
+// serverStream replaces the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// statusError maps service errors to gRPC status codes the way
+// apperror.Middleware maps them to HTTP statuses.
+func statusError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	lang := i18n.FromContext(ctx)
+	var appErr *apperror.AppError
+	if errors.As(err, &appErr) {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return status.Error(codes.NotFound, appErr.Localize(lang).Message)
+		}
+		if errors.Is(err, apperror.ErrTooManyRequests) {
+			return status.Error(codes.ResourceExhausted, appErr.Localize(lang).Message)
+		}
+		if errors.Is(err, apperror.ErrForbidden) {
+			return status.Error(codes.PermissionDenied, appErr.Localize(lang).Message)
+		}
+		if errors.Is(err, apperror.ErrUnavailable) {
+			return status.Error(codes.Unavailable, appErr.Localize(lang).Message)
+		}
+		return status.Error(codes.InvalidArgument, appErr.Localize(lang).Message)
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	logging.FromContext(ctx).Error(err)
+	return status.Error(codes.Internal, i18n.Error(lang, "WeatherService-000001", "internal system error"))
+}
+
+// statusMessage returns a status with message translated to the request
+// language.
+func statusMessage(ctx context.Context, code codes.Code, message string) error {
+	return status.Error(code, i18n.Error(i18n.FromContext(ctx), "", message))
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/weatherpb"
+	"context"
+	"encoding/base64"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"time"
+)
+
+type userServer struct {
+	weatherpb.UnimplementedUserServiceServer
+
+	cityService cityClient.Service
+	userService user.Service
+	limiter     *ratelimit.Limiter
+}
+
+func (s *userServer) ListFavourites(ctx context.Context, req *weatherpb.ListFavouritesRequest) (*weatherpb.ListCitiesResponse, error) {
+	logger := logging.FromContext(ctx)
+	logger.Info("GET USER FAVOURITES")
+
+	email, password, err := credentials(ctx, s.limiter)
+	if err != nil {
+		return nil, err
+	}
+
+	favourites, err := s.userService.GetFavourites(ctx, email, password)
+	if err != nil {
+		return nil, unauthenticated(ctx, err)
+	}
+
+	return &weatherpb.ListCitiesResponse{Cities: toCities(favourites)}, nil
+}
+
+func (s *userServer) AddFavourite(ctx context.Context, req *weatherpb.FavouriteRequest) (*weatherpb.FavouriteResponse, error) {
+	logger := logging.FromContext(ctx)
+	logger.Info("ADD CITY TO USER FAVOURITES")
+
+	dto, err := s.favouriteDTO(ctx, req.GetCity())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.userService.CreateFavourite(ctx, dto, dto.CityID); err != nil {
+		return nil, err
+	}
+	return &weatherpb.FavouriteResponse{}, nil
+}
+
+func (s *userServer) RemoveFavourite(ctx context.Context, req *weatherpb.FavouriteRequest) (*weatherpb.FavouriteResponse, error) {
+	logger := logging.FromContext(ctx)
+	logger.Info("DELETE CITY FROM USER FAVOURITES")
+
+	dto, err := s.favouriteDTO(ctx, req.GetCity())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.userService.DeleteFavourite(ctx, dto, dto.CityID); err != nil {
+		return nil, err
+	}
+	return &weatherpb.FavouriteResponse{}, nil
+}
+
+// favouriteDTO authenticates the caller and resolves the city.
+func (s *userServer) favouriteDTO(ctx context.Context, query string) (user.UserFavouriteCityDTO, error) {
+	email, password, err := credentials(ctx, s.limiter)
+	if err != nil {
+		return user.UserFavouriteCityDTO{}, err
+	}
+
+	u, err := s.userService.GetByEmailAndPassword(ctx, email, password)
+	if err != nil {
+		return user.UserFavouriteCityDTO{}, unauthenticated(ctx, err)
+	}
+
+	city, err := s.cityService.Resolve(ctx, query)
+	if err != nil {
+		return user.UserFavouriteCityDTO{}, err
+	}
+
+	return user.UserFavouriteCityDTO{
+		UUID:     u.UUID,
+		Email:    u.Email,
+		Password: password,
+		CityID:   city.Id,
+	}, nil
+}
+
+// credentials reads "authorization: Basic base64(email:password)" from the
+// request metadata and counts the password check against the auth rate
+// limits.
+func credentials(ctx context.Context, limiter *ratelimit.Limiter) (email, password string, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := first(md, "authorization")
+	if auth == "" {
+		return "", "", statusMessage(ctx, codes.Unauthenticated, "authorization metadata is required")
+	}
+
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", statusMessage(ctx, codes.Unauthenticated, "expected basic authorization")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", statusMessage(ctx, codes.Unauthenticated, "invalid basic authorization")
+	}
+
+	email, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", statusMessage(ctx, codes.Unauthenticated, "invalid basic authorization")
+	}
+	if result := limiter.AllowAuth(ctx, user.ClientFromContext(ctx).IP, email, time.Now()); !result.Allowed {
+		return "", "", apperror.ErrTooManyRequests
+	}
+	return email, password, nil
+}
+
+// optionalUser authenticates the caller when the request has authorization
+// metadata.
+func optionalUser(ctx context.Context, userService user.Service, limiter *ratelimit.Limiter) (user.User, bool, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if first(md, "authorization") == "" {
+		return user.User{}, false, nil
+	}
+
+	email, password, err := credentials(ctx, limiter)
+	if err != nil {
+		return user.User{}, false, err
+	}
+	u, err := userService.GetByEmailAndPassword(ctx, email, password)
+	if err != nil {
+		return user.User{}, false, unauthenticated(ctx, err)
+	}
+	return u, true, nil
+}
+
+// unauthenticated reports wrong credentials, which the user service returns
+// as not found, as codes.Unauthenticated.
+func unauthenticated(ctx context.Context, err error) error {
+	if errors.Is(err, apperror.ErrNotFound) {
+		return statusMessage(ctx, codes.Unauthenticated, "invalid credentials")
+	}
+	return err
+}
@@ -0,0 +1,248 @@
+package rpc
+
+import (
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/events"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/units"
+	"WeatherServiceAPI/pkg/weatherpb"
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"time"
+)
+
+// forecastEnd is the end of the range when the request has none.
+var forecastEnd = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type weatherServer struct {
+	weatherpb.UnimplementedWeatherServiceServer
+
+	cityService    cityClient.Service
+	weatherService weatherClient.Service
+	userService    user.Service
+	broker         *events.Broker
+	limiter        *ratelimit.Limiter
+}
+
+func (s *weatherServer) ListCities(ctx context.Context, req *weatherpb.ListCitiesRequest) (*weatherpb.ListCitiesResponse, error) {
+	logger := logging.FromContext(ctx)
+	logger.Info("LIST CITIES")
+
+	cities, err := s.cityService.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &weatherpb.ListCitiesResponse{Cities: toCities(cities)}, nil
+}
+
+func (s *weatherServer) GetBriefInfo(ctx context.Context, req *weatherpb.GetBriefInfoRequest) (*weatherpb.BriefInfo, error) {
+	logger := logging.FromContext(ctx)
+	logger.Info("GET BRIEF WEATHER INFO FOR CITY")
+
+	city, err := s.cityService.Resolve(ctx, req.GetCity())
+	if err != nil {
+		return nil, err
+	}
+
+	u, _, err := s.presentation(ctx, req.GetPresentation())
+	if err != nil {
+		return nil, err
+	}
+
+	briefInfo, err := s.weatherService.FindBriefInfo(ctx, city.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]*timestamppb.Timestamp, len(briefInfo.DateTimeArray))
+	for i, date := range briefInfo.DateTimeArray {
+		dates[i] = timestamppb.New(date)
+	}
+
+	return &weatherpb.BriefInfo{
+		City:    toCity(city),
+		AvgTemp: math.Round(u.Temperature(briefInfo.AvgTemp)*100) / 100,
+		Dates:   dates,
+		Units:   toUnits(u.Labels()),
+	}, nil
+}
+
+func (s *weatherServer) GetForecast(ctx context.Context, req *weatherpb.GetForecastRequest) (*weatherpb.GetForecastResponse, error) {
+	logger := logging.FromContext(ctx)
+	logger.Info("GET CITY FORECAST")
+
+	city, err := s.cityService.Resolve(ctx, req.GetCity())
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := time.Time{}, forecastEnd
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime()
+	}
+	if !from.Before(to) {
+		return nil, apperror.NewAppError(nil, "invalid period", "from must be before to", "WeatherService-000004")
+	}
+
+	u, lang, err := s.presentation(ctx, req.GetPresentation())
+	if err != nil {
+		return nil, err
+	}
+
+	forecast, err := s.weatherService.FindForecast(ctx, city.Id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]*weatherpb.ForecastSlot, len(forecast))
+	for i, f := range forecast {
+		slots[i] = toSlot(f.ConvertUnits(u).Localize(lang))
+	}
+
+	return &weatherpb.GetForecastResponse{
+		City:  toCity(city),
+		Units: toUnits(u.Labels()),
+		Slots: slots,
+	}, nil
+}
+
+// WatchForecast follows the same rules as the SSE stream: missed events after
+// last_event_id are replayed first and a client lagging behind is dropped, to
+// resume with the id of the last received update.
+func (s *weatherServer) WatchForecast(req *weatherpb.WatchForecastRequest, stream weatherpb.WeatherService_WatchForecastServer) error {
+	ctx := stream.Context()
+	logger := logging.FromContext(ctx)
+	logger.Info("WATCH FORECAST")
+
+	cities, topics, err := s.watchTopics(ctx, req.GetCities())
+	if err != nil {
+		return err
+	}
+
+	u, lang, err := s.presentation(ctx, req.GetPresentation())
+	if err != nil {
+		return err
+	}
+
+	lastID := req.GetLastEventId()
+	if lastID > s.broker.LastID() {
+		// the id was issued before a restart of the service
+		lastID = 0
+	}
+
+	sub := s.broker.Subscribe(topics...)
+	defer sub.Close()
+
+	send := func(e events.Event) error {
+		update, ok := e.Payload.(weatherClient.CityUpdate)
+		if !ok {
+			return nil
+		}
+		update = update.Present(u, lang)
+
+		msg := &weatherpb.ForecastUpdate{
+			EventId:   e.ID,
+			City:      cities[update.CityID],
+			UpdatedAt: timestamppb.New(update.UpdatedAt),
+			AvgTemp:   update.AvgTemp,
+			Units:     toUnits(*update.Units),
+		}
+		if msg.City == nil {
+			msg.City = &weatherpb.City{Id: update.CityID}
+		}
+		if update.Current != nil {
+			msg.Current = toSlot(*update.Current)
+		}
+
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		lastID = e.ID
+		return nil
+	}
+
+	if lastID > 0 {
+		missed, complete := s.broker.Replay(lastID, topics...)
+		if !complete {
+			logger.Debugf("events after %d are no longer kept, replaying %d", lastID, len(missed))
+		}
+		for _, e := range missed {
+			if err = send(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.C():
+			if !ok {
+				logger.Warn("forecast watcher lagged behind and was dropped")
+				return statusMessage(ctx, codes.ResourceExhausted, "client is too slow, resume with the last event id")
+			}
+			if e.ID <= lastID {
+				continue
+			}
+			if err = send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// presentation reads the units and language of a request. The units of the
+// user given by the authorization metadata, if any, are the default.
+func (s *weatherServer) presentation(ctx context.Context, p *weatherpb.Presentation) (units.Units, string, error) {
+	userUnits := ""
+	if p.GetUnits() == "" {
+		if u, ok, err := optionalUser(ctx, s.userService, s.limiter); err != nil {
+			return units.Units{}, "", err
+		} else if ok {
+			userUnits = u.Units
+		}
+	}
+
+	return presentation(p, userUnits, i18n.FromContext(ctx))
+}
+
+// watchTopics resolves the requested cities to event topics and the cities
+// by id.
+func (s *weatherServer) watchTopics(ctx context.Context, names []string) (map[string]*weatherpb.City, []string, error) {
+	cities := make(map[string]*weatherpb.City)
+
+	if len(names) == 0 {
+		all, err := s.cityService.FindAll(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, city := range all {
+			cities[city.Id] = toCity(city)
+		}
+		return cities, []string{weatherClient.AllCitiesTopic}, nil
+	}
+
+	topics := make([]string, 0, len(names))
+	for _, name := range names {
+		city, err := s.cityService.Resolve(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		cities[city.Id] = toCity(city)
+		topics = append(topics, weatherClient.CityTopic(city.Id))
+	}
+
+	return cities, topics, nil
+}
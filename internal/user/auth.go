@@ -0,0 +1,115 @@
+package user
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Actions of auth events, the operation a password was checked for.
+const (
+	AuthActionLogin           = "login"
+	AuthActionFavourites      = "favourites"
+	AuthActionAddFavourite    = "add_favourite"
+	AuthActionDeleteFavourite = "delete_favourite"
+	AuthActionUpdate          = "update"
+	AuthActionCalendarToken   = "calendar_token"
+	AuthActionAuthEvents      = "auth_events"
+	AuthActionPasswordReset   = "password_reset"
+	AuthActionAlerts          = "alerts"
+	AuthActionWebhooks        = "webhooks"
+	AuthActionAPIKeys         = "api_keys"
+	AuthActionCreateAPIKey    = "create_api_key"
+	AuthActionNotifications   = "notifications"
+	AuthActionDigest          = "digest"
+)
+
+// Reasons of failed auth events.
+const (
+	AuthReasonWrongPassword = "wrong_password"
+	AuthReasonLocked        = "locked"
+)
+
+// Purposes of single-use tokens sent by email.
+const (
+	tokenVerifyEmail   = "verify_email"
+	tokenPasswordReset = "password_reset"
+)
+
+const (
+	maxEmailLength = 254
+
+	// maxUnverifiedFavourites limits the favourite cities of accounts
+	// without a confirmed email.
+	maxUnverifiedFavourites = 3
+)
+
+// ErrUnverified is returned for operations not allowed until the user
+// confirms the email. It unwraps to apperror.ErrForbidden.
+var ErrUnverified = apperror.NewAppError(apperror.ErrForbidden, "email is not verified", "confirm the email with the link sent to it, a new link is sent by POST /api/auth/verify-email/resend", "WeatherService-000010")
+
+// errMailDisabled is returned by the email endpoints when the server has no
+// email transport configured.
+var errMailDisabled = apperror.NewAppError(apperror.ErrUnavailable, "emails are disabled", "the server has no email transport configured, set email.enabled and email.transport", "WeatherService-000012")
+
+// errInvalidToken is returned for unknown, used and expired email tokens.
+var errInvalidToken = apperror.NewAppError(nil, "invalid or expired token", "the token is unknown, already used or expired", "WeatherService-000004")
+
+// emailTimeFormat formats token expiry times in emails.
+const emailTimeFormat = "Mon, 02 Jan 15:04"
+
+// AuthEvent is a password check of an account.
+type AuthEvent struct {
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LockedError is the cause of errors returned for password checks of a
+// locked account. It unwraps to apperror.ErrTooManyRequests, so the account
+// is answered with 429.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("account is locked until %s", e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return apperror.ErrTooManyRequests
+}
+
+// Client is the client a request is made from, recorded in auth events.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// ContextWithClient sets the client of the request.
+func ContextWithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client of the request.
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+// ClientMiddleware sets the client of every request, ip returns the address
+// of the request.
+func ClientMiddleware(ip func(r *http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := ContextWithClient(r.Context(), Client{IP: ip(r), UserAgent: r.UserAgent()})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,18 @@
+package user
+
+import "context"
+
+type apiKeyOwnerKey struct{}
+
+// ContextWithAPIKeyOwner marks the request as made with an API key of the
+// user allowed to manage the account, which replaces the password.
+func ContextWithAPIKeyOwner(ctx context.Context, userUUID string) context.Context {
+	return context.WithValue(ctx, apiKeyOwnerKey{}, userUUID)
+}
+
+// APIKeyOwner returns the uuid of the user whose API key authenticated the
+// request, or an empty string.
+func APIKeyOwner(ctx context.Context) string {
+	userUUID, _ := ctx.Value(apiKeyOwnerKey{}).(string)
+	return userUUID
+}
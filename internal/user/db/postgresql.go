@@ -2,6 +2,7 @@ package db
 
 import (
 	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/apperror"
 	"WeatherServiceAPI/internal/user"
 	"WeatherServiceAPI/pkg/client/postgresql"
 	"WeatherServiceAPI/pkg/logging"
@@ -9,6 +10,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"time"
 )
 
 var _ user.Storage = &db{}
@@ -57,7 +60,7 @@ func (d db) CreateFavourite(ctx context.Context, user user.User, cityId string)
 }
 
 func (d db) FindFavourites(ctx context.Context, user user.User) ([]cityClient.CityData, error) {
-	q := `SELECT c.* FROM user_favorites JOIN cities c on c.id = user_favorites.city_id WHERE user_id = $1;`
+	q := `SELECT c.id, c.name, c.lat, c.lon, c.country FROM user_favorites JOIN cities c on c.id = user_favorites.city_id WHERE user_id = $1;`
 
 	rows, err := d.client.Query(ctx, q, user.UUID)
 	if err != nil {
@@ -93,11 +96,14 @@ func (d db) FindFavourites(ctx context.Context, user user.User) ([]cityClient.Ci
 }
 
 func (d db) FindByEmail(ctx context.Context, email string) (user user.User, err error) {
-	q := `SELECT * FROM users WHERE email = $1;`
+	q := `SELECT uuid, email, password, units, email_verified_at, failed_logins, locked_until FROM users WHERE email = $1;`
 
 	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
 
-	if err = d.client.QueryRow(ctx, q, email).Scan(&user.UUID, &user.Email, &user.Password); err != nil {
+	if err = d.client.QueryRow(ctx, q, email).Scan(&user.UUID, &user.Email, &user.Password, &user.Units, &user.EmailVerifiedAt, &user.FailedLogins, &user.LockedUntil); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, apperror.ErrNotFound
+		}
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
@@ -113,10 +119,13 @@ func (d db) FindByEmail(ctx context.Context, email string) (user user.User, err
 }
 
 func (d db) FindOne(ctx context.Context, uuid string) (user user.User, err error) {
-	q := `SELECT * FROM users WHERE uuid  = $1;`
+	q := `SELECT uuid, email, password, units, email_verified_at, failed_logins, locked_until FROM users WHERE uuid = $1;`
 
 	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
-	if err = d.client.QueryRow(ctx, q, uuid).Scan(&user.UUID, &user.Email, &user.Password); err != nil {
+	if err = d.client.QueryRow(ctx, q, uuid).Scan(&user.UUID, &user.Email, &user.Password, &user.Units, &user.EmailVerifiedAt, &user.FailedLogins, &user.LockedUntil); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, apperror.ErrNotFound
+		}
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
@@ -130,15 +139,232 @@ func (d db) FindOne(ctx context.Context, uuid string) (user user.User, err error
 	return user, nil
 }
 
+func (d db) SetCalendarTokenHash(ctx context.Context, uuid, tokenHash string) error {
+	q := `UPDATE users SET calendar_token_hash = $2 WHERE uuid = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	tag, err := d.client.Exec(ctx, q, uuid, tokenHash)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (d db) FindByCalendarTokenHash(ctx context.Context, tokenHash string) (user user.User, err error) {
+	q := `SELECT uuid, email, password, units, email_verified_at, failed_logins, locked_until FROM users WHERE calendar_token_hash = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	if err = d.client.QueryRow(ctx, q, tokenHash).Scan(&user.UUID, &user.Email, &user.Password, &user.Units, &user.EmailVerifiedAt, &user.FailedLogins, &user.LockedUntil); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return user, newErr
+		}
+		return user, err
+	}
+
+	return user, nil
+}
+
+func (d db) AddFailedLogin(ctx context.Context, uuid string) (failures int, err error) {
+	q := `UPDATE users SET failed_logins = failed_logins + 1 WHERE uuid = $1 RETURNING failed_logins;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	if err = d.client.QueryRow(ctx, q, uuid).Scan(&failures); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return 0, newErr
+		}
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (d db) LockUntil(ctx context.Context, uuid string, until time.Time) error {
+	q := `UPDATE users SET locked_until = $2 WHERE uuid = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	_, err := d.client.Exec(ctx, q, uuid, until)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) ResetFailedLogins(ctx context.Context, uuid string) error {
+	q := `UPDATE users SET failed_logins = 0, locked_until = NULL WHERE uuid = $1;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	_, err := d.client.Exec(ctx, q, uuid)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) CreateAuthEvent(ctx context.Context, uuid string, event user.AuthEvent) error {
+	q := `INSERT INTO user_auth_events (user_id, action, success, reason, ip, user_agent, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	_, err := d.client.Exec(ctx, q, uuid, event.Action, event.Success, event.Reason, event.IP, event.UserAgent, event.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) FindAuthEvents(ctx context.Context, uuid string, limit int) ([]user.AuthEvent, error) {
+	q := `SELECT id, action, success, reason, ip, user_agent, created_at FROM user_auth_events WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	rows, err := d.client.Query(ctx, q, uuid, limit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]user.AuthEvent, 0)
+	for rows.Next() {
+		var event user.AuthEvent
+		if err = rows.Scan(&event.ID, &event.Action, &event.Success, &event.Reason, &event.IP, &event.UserAgent, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (d db) SetEmailVerified(ctx context.Context, uuid string, at time.Time) error {
+	q := `UPDATE users SET email_verified_at = $2 WHERE uuid = $1 AND email_verified_at IS NULL;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	_, err := d.client.Exec(ctx, q, uuid, at)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) CreateToken(ctx context.Context, uuid, purpose, tokenHash string, expiresAt time.Time) error {
+	q := `WITH replaced AS (
+    DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+)
+INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4);`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	_, err := d.client.Exec(ctx, q, uuid, purpose, tokenHash, expiresAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) UseToken(ctx context.Context, purpose, tokenHash string, now time.Time) (uuid string, err error) {
+	q := `UPDATE user_tokens SET used_at = $3
+WHERE token_hash = $2 AND purpose = $1 AND used_at IS NULL AND expires_at > $3
+RETURNING user_id;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+	if err = d.client.QueryRow(ctx, q, purpose, tokenHash, now).Scan(&uuid); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return "", newErr
+		}
+		return "", err
+	}
+
+	return uuid, nil
+}
+
 func (d db) Update(ctx context.Context, user user.User) (err error) {
 
 	if user.Email != "" && user.Password != "" {
-		q := `UPDATE users SET email = $2, password = $3 WHERE uuid = $1;`
+		q := `UPDATE users SET email = $2, password = $3, email_verified_at = CASE WHEN email = $2 THEN email_verified_at END WHERE uuid = $1;`
 
 		d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
 		_, err = d.client.Exec(ctx, q, user.UUID, user.Email, user.Password)
 	} else if user.Email != "" {
-		q := `UPDATE users SET email = $2 WHERE uuid = $1;`
+		q := `UPDATE users SET email = $2, email_verified_at = CASE WHEN email = $2 THEN email_verified_at END WHERE uuid = $1;`
 
 		d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
 		_, err = d.client.Exec(ctx, q, user.UUID, user.Email)
@@ -149,6 +375,13 @@ func (d db) Update(ctx context.Context, user user.User) (err error) {
 		_, err = d.client.Exec(ctx, q, user.UUID, user.Password)
 	}
 
+	if err == nil && user.Units != "" {
+		q := `UPDATE users SET units = $2 WHERE uuid = $1;`
+
+		d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+		_, err = d.client.Exec(ctx, q, user.UUID, user.Units)
+	}
+
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -3,42 +3,84 @@ package user
 import (
 	"WeatherServiceAPI/internal/apperror"
 	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
 	"WeatherServiceAPI/pkg/logging"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 const (
-	usersURL    = "/api/users"
-	userURL     = "/api/users/:uuid"
-	usersFavURL = "/api/userfavs"
-	userFavURL  = "/api/userfavs/:uuid"
+	usersURL      = "/api/users"
+	userURL       = "/api/users/:uuid"
+	usersFavURL   = "/api/userfavs"
+	userFavURL    = "/api/userfavs/:uuid"
+	calendarURL   = "/api/users/:uuid/calendar"
+	authEventsURL = "/api/users/:uuid/auth-events"
+
+	passwordResetURL        = "/api/auth/password-reset"
+	passwordResetConfirmURL = "/api/auth/password-reset/confirm"
+	verifyEmailURL          = "/api/auth/verify-email"
+	verifyEmailResendURL    = "/api/auth/verify-email/resend"
+
+	// calendarFeedURL is served by the weather api handler
+	calendarFeedURL = "/api/calendar/%s.ics"
 )
 
 type handler struct {
 	Logger      *logging.Logger
 	UserService Service
+	Limiter     *ratelimit.Limiter
 }
 
-func NewHandler(logger *logging.Logger, userService Service) handlers.Handler {
+func NewHandler(logger *logging.Logger, userService Service, limiter *ratelimit.Limiter) handlers.Handler {
 	return &handler{
 		Logger:      logger,
 		UserService: userService,
+		Limiter:     limiter,
 	}
 }
 
 func (h *handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodGet, userURL, apperror.Middleware(h.GetUser))
-	router.HandlerFunc(http.MethodGet, usersURL, apperror.Middleware(h.GetUserByEmailAndPassword))
-	router.HandlerFunc(http.MethodPost, usersURL, apperror.Middleware(h.CreateUser))
-	router.HandlerFunc(http.MethodPatch, userURL, apperror.Middleware(h.PartiallyUpdateUser))
+	router.HandlerFunc(http.MethodGet, usersURL, apperror.Middleware(h.auth(h.GetUserByEmailAndPassword)))
+	router.HandlerFunc(http.MethodPost, usersURL, apperror.Middleware(h.auth(h.CreateUser)))
+	router.HandlerFunc(http.MethodPatch, userURL, apperror.Middleware(h.auth(h.PartiallyUpdateUser)))
 	router.HandlerFunc(http.MethodDelete, userURL, apperror.Middleware(h.DeleteUser))
+	router.HandlerFunc(http.MethodPost, calendarURL, apperror.Middleware(h.auth(h.CreateCalendarToken)))
+	router.HandlerFunc(http.MethodGet, authEventsURL, apperror.Middleware(h.auth(h.GetAuthEvents)))
 
-	router.HandlerFunc(http.MethodPost, userFavURL, apperror.Middleware(h.CreateFavourite))
-	router.HandlerFunc(http.MethodDelete, userFavURL, apperror.Middleware(h.DeleteFromFavourites))
-	router.HandlerFunc(http.MethodGet, usersFavURL, apperror.Middleware(h.GetUserFavourites))
+	router.HandlerFunc(http.MethodPost, userFavURL, apperror.Middleware(h.auth(h.CreateFavourite)))
+	router.HandlerFunc(http.MethodDelete, userFavURL, apperror.Middleware(h.auth(h.DeleteFromFavourites)))
+	router.HandlerFunc(http.MethodGet, usersFavURL, apperror.Middleware(h.auth(h.GetUserFavourites)))
+
+	router.HandlerFunc(http.MethodGet, verifyEmailURL, apperror.Middleware(h.auth(h.VerifyEmail)))
+	router.HandlerFunc(http.MethodPost, verifyEmailResendURL, apperror.Middleware(h.auth(h.ResendVerification)))
+	router.HandlerFunc(http.MethodPost, passwordResetURL, apperror.Middleware(h.auth(h.RequestPasswordReset)))
+	router.HandlerFunc(http.MethodPost, passwordResetConfirmURL, apperror.Middleware(h.auth(h.ResetPassword)))
+}
+
+// auth wraps a handler checking passwords with the auth rate limits and sets
+// Retry-After when the account is locked.
+func (h *handler) auth(next func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return LimitAuth(h.Limiter, next)
+}
+
+// LimitAuth wraps a handler checking the password of a user with the auth
+// rate limits of limiter and sets Retry-After when the account is locked.
+func LimitAuth(limiter *ratelimit.Limiter, next func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return limiter.Auth(func(w http.ResponseWriter, r *http.Request) error {
+		err := next(w, r)
+		var lockedErr *LockedError
+		if errors.As(err, &lockedErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(lockedErr.Until).Seconds())+1))
+		}
+		return err
+	})
 }
 
 // GetUser godoc
@@ -51,10 +93,11 @@ func (h *handler) Register(router *httprouter.Router) {
 // @Success      200  {array}  User
 // @Router       /users/{uuid} [get]
 func (h *handler) GetUser(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("GET USER")
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.Logger.Debug("get uuid from context")
+	logger.Debug("get uuid from context")
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	userUUID := params.ByName("uuid")
 
@@ -63,7 +106,7 @@ func (h *handler) GetUser(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	h.Logger.Debug("marshal user")
+	logger.Debug("marshal user")
 	userBytes, err := json.Marshal(user)
 	if err != nil {
 		return fmt.Errorf("failed to marshall user. error: %w", err)
@@ -85,13 +128,14 @@ func (h *handler) GetUser(w http.ResponseWriter, r *http.Request) error {
 // @Success      200  {array}  User
 // @Router       /users [get]
 func (h *handler) GetUserByEmailAndPassword(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("GET USER BY EMAIL AND PASSWORD")
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER BY EMAIL AND PASSWORD")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.Logger.Debug("get email and password from URL")
+	logger.Debug("get email and password from URL")
 	email := r.URL.Query().Get("email")
 	password := r.URL.Query().Get("password")
-	if email == "" || password == "" {
+	if email == "" || (password == "" && APIKeyOwner(r.Context()) == "") {
 		return fmt.Errorf("invalid query parameters email or password")
 	}
 
@@ -100,7 +144,7 @@ func (h *handler) GetUserByEmailAndPassword(w http.ResponseWriter, r *http.Reque
 		return err
 	}
 
-	h.Logger.Debug("marshal user")
+	logger.Debug("marshal user")
 	userBytes, err := json.Marshal(user)
 	if err != nil {
 		return err
@@ -123,13 +167,14 @@ func (h *handler) GetUserByEmailAndPassword(w http.ResponseWriter, r *http.Reque
 // @Success      200  {array}  []cityClient.CityData
 // @Router       /userfavs [get]
 func (h *handler) GetUserFavourites(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("GET USER FAVOURITE CITIES BY EMAIL AND PASSWORD")
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER FAVOURITE CITIES BY EMAIL AND PASSWORD")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.Logger.Debug("get email and password from URL")
+	logger.Debug("get email and password from URL")
 	email := r.URL.Query().Get("email")
 	password := r.URL.Query().Get("password")
-	if email == "" || password == "" {
+	if email == "" || (password == "" && APIKeyOwner(r.Context()) == "") {
 		return fmt.Errorf("invalid query parameters email or password")
 	}
 
@@ -139,7 +184,7 @@ func (h *handler) GetUserFavourites(w http.ResponseWriter, r *http.Request) erro
 	}
 
 	if cities != nil {
-		h.Logger.Debug("marshal cities data")
+		logger.Debug("marshal cities data")
 		userBytes, err := json.Marshal(cities)
 		if err != nil {
 			return err
@@ -157,7 +202,7 @@ func (h *handler) GetUserFavourites(w http.ResponseWriter, r *http.Request) erro
 
 // CreateUser godoc
 // @Summary      Create new user
-// @Description  Create new user by email and password
+// @Description  Create new user by email and password. A verification link is emailed to the user, until it is opened the account is limited
 // @Tags         Users
 // @Accept       json
 // @Produce      json
@@ -165,10 +210,11 @@ func (h *handler) GetUserFavourites(w http.ResponseWriter, r *http.Request) erro
 // @Success      200  {array}  []cityClient.CityData
 // @Router       /users [post]
 func (h *handler) CreateUser(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("CREATE USER")
+	logger := logging.FromContext(r.Context())
+	logger.Info("CREATE USER")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.Logger.Debug("decode create user dto")
+	logger.Debug("decode create user dto")
 	var crUser CreateUserDTO
 	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(&crUser); err != nil {
@@ -196,13 +242,14 @@ func (h *handler) CreateUser(w http.ResponseWriter, r *http.Request) error {
 // @Success      200  {array}  []cityClient.CityData
 // @Router       /userfavs/{uid} [post]
 func (h *handler) CreateFavourite(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("ADD CITY TO USER FAVOURITES")
+	logger := logging.FromContext(r.Context())
+	logger.Info("ADD CITY TO USER FAVOURITES")
 	w.Header().Set("Content-Type", "application/json")
 
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	userUUID := params.ByName("uuid")
 
-	h.Logger.Debug("decode user fav city dto")
+	logger.Debug("decode user fav city dto")
 	var userFavCity UserFavouriteCityDTO
 	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(&userFavCity); err != nil {
@@ -229,13 +276,14 @@ func (h *handler) CreateFavourite(w http.ResponseWriter, r *http.Request) error
 // @Success      204
 // @Router       /users/{uuid} [patch]
 func (h *handler) PartiallyUpdateUser(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("PARTIALLY UPDATE USER")
+	logger := logging.FromContext(r.Context())
+	logger.Info("PARTIALLY UPDATE USER")
 	w.Header().Set("Content-Type", "application/json")
 
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	userUUID := params.ByName("uuid")
 
-	h.Logger.Debug("decode update user dto")
+	logger.Debug("decode update user dto")
 	var updUser UpdateUserDTO
 	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(&updUser); err != nil {
@@ -252,6 +300,204 @@ func (h *handler) PartiallyUpdateUser(w http.ResponseWriter, r *http.Request) er
 	return nil
 }
 
+// CreateCalendarToken godoc
+// @Summary      Create calendar feed token
+// @Description  Create a secret URL of the iCalendar feed of the user's favourite cities. A new token replaces the previous one
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        uuid    path     string  true  "User uuid"
+// @Param        dto     body     CalendarTokenDTO  true  "User password"
+// @Success      201  {object}  CalendarToken
+// @Router       /users/{uuid}/calendar [post]
+func (h *handler) CreateCalendarToken(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("CREATE CALENDAR TOKEN")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("decode calendar token dto")
+	var dto CalendarTokenDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme. check swagger API")
+	}
+	dto.UUID = params.ByName("uuid")
+
+	token, err := h.UserService.CreateCalendarToken(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal calendar token")
+	tokenBytes, err := json.Marshal(CalendarToken{Token: token, URL: fmt.Sprintf(calendarFeedURL, token)})
+	if err != nil {
+		return fmt.Errorf("failed to marshall calendar token. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(tokenBytes)
+
+	return nil
+}
+
+// GetAuthEvents godoc
+// @Summary      Get user auth events
+// @Description  Latest password checks of the account, newest first, with the client address and user agent. Failed checks lock the account for a growing time
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true   "User uuid"
+// @Param        password    query    string  true   "User password"
+// @Param        limit       query    int     false  "Number of events, 50 by default, at most 500"
+// @Success      200  {array}  AuthEvent
+// @Router       /users/{uuid}/auth-events [get]
+func (h *handler) GetAuthEvents(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER AUTH EVENTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	logger.Debug("get password from URL")
+	password := r.URL.Query().Get("password")
+	if password == "" && APIKeyOwner(r.Context()) == "" {
+		return apperror.NewAppError(nil, "invalid query parameter password", "password is required", "WeatherService-000004")
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return apperror.NewAppError(err, "invalid query parameter limit", "expected a positive integer", "WeatherService-000004")
+		}
+	}
+
+	events, err := h.UserService.FindAuthEvents(r.Context(), params.ByName("uuid"), password, limit)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("marshal auth events")
+	eventsBytes, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshall auth events. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(eventsBytes)
+	return nil
+}
+
+// VerifyEmail godoc
+// @Summary      Verify user email
+// @Description  Confirm the email with the token of the link sent to it. The token works once
+// @Tags         Auth
+// @Produce      json
+// @Param        token    query    string  true  "Verification token"
+// @Success      204
+// @Router       /auth/verify-email [get]
+func (h *handler) VerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("VERIFY EMAIL")
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := h.UserService.VerifyEmail(r.Context(), r.URL.Query().Get("token")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  Send a new verification link to an unverified account. The response is the same for unknown emails
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        dto    body     EmailDTO  true  "User email"
+// @Success      202
+// @Router       /auth/verify-email/resend [post]
+func (h *handler) ResendVerification(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("RESEND VERIFICATION EMAIL")
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Debug("decode email dto")
+	var dto EmailDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil || dto.Email == "" {
+		return apperror.NewAppError(err, "invalid JSON scheme", "email is required", "WeatherService-000004")
+	}
+
+	if err := h.UserService.ResendVerification(r.Context(), dto.Email); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+// RequestPasswordReset godoc
+// @Summary      Request password reset
+// @Description  Email a single-use password reset token. The response is the same for unknown emails
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        dto    body     EmailDTO  true  "User email"
+// @Success      202
+// @Router       /auth/password-reset [post]
+func (h *handler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("REQUEST PASSWORD RESET")
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Debug("decode email dto")
+	var dto EmailDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil || dto.Email == "" {
+		return apperror.NewAppError(err, "invalid JSON scheme", "email is required", "WeatherService-000004")
+	}
+
+	if err := h.UserService.RequestPasswordReset(r.Context(), dto.Email); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+// ResetPassword godoc
+// @Summary      Confirm password reset
+// @Description  Set a new password with the emailed token. The token works once
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        dto    body     PasswordResetDTO  true  "Token and new password"
+// @Success      204
+// @Router       /auth/password-reset/confirm [post]
+func (h *handler) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("RESET PASSWORD")
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Debug("decode password reset dto")
+	var dto PasswordResetDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme. check swagger API")
+	}
+
+	if err := h.UserService.ResetPassword(r.Context(), dto); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
 // DeleteUser godoc
 // @Summary      Delete user by uuid param
 // @Tags         Users
@@ -261,10 +507,11 @@ func (h *handler) PartiallyUpdateUser(w http.ResponseWriter, r *http.Request) er
 // @Success      204
 // @Router       /users/{uuid} [delete]
 func (h *handler) DeleteUser(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("DELETE USER")
+	logger := logging.FromContext(r.Context())
+	logger.Info("DELETE USER")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.Logger.Debug("get uuid from context")
+	logger.Debug("get uuid from context")
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	userUUID := params.ByName("uuid")
 
@@ -287,13 +534,14 @@ func (h *handler) DeleteUser(w http.ResponseWriter, r *http.Request) error {
 // @Success      204
 // @Router       /userfavs/{uuid} [delete]
 func (h *handler) DeleteFromFavourites(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("DELETE CITY FROM USER FAVOURITES")
+	logger := logging.FromContext(r.Context())
+	logger.Info("DELETE CITY FROM USER FAVOURITES")
 	w.Header().Set("Content-Type", "application/json")
 
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	userUUID := params.ByName("uuid")
 
-	h.Logger.Debug("decode user fav city dto")
+	logger.Debug("decode user fav city dto")
 	var userFavCity UserFavouriteCityDTO
 	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(&userFavCity); err != nil {
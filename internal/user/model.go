@@ -1,14 +1,28 @@
 package user
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	netmail "net/mail"
+	"time"
 )
 
 type User struct {
 	UUID     string `json:"uuid"`
 	Email    string `json:"email"`
 	Password string `json:"-"`
+	Units    string `json:"units"`
+	// EmailVerifiedAt is set when the user confirms the email, until then
+	// the account is limited.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// FailedLogins counts failed password checks since the last successful
+	// one, LockedUntil is set when they lock the account.
+	FailedLogins int        `json:"-"`
+	LockedUntil  *time.Time `json:"-"`
 }
 
 func (u *User) CheckPassword(password string) error {
@@ -19,6 +33,11 @@ func (u *User) CheckPassword(password string) error {
 	return nil
 }
 
+// Verified reports whether the user has confirmed the email.
+func (u *User) Verified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
 func (u *User) GeneratePasswordHash() error {
 	pwd, err := generatePasswordHash(u.Password)
 	if err != nil {
@@ -37,9 +56,33 @@ type CreateUserDTO struct {
 type UpdateUserDTO struct {
 	UUID        string `json:"uuid,omitempty"`
 	Email       string `json:"email,omitempty"`
-	Password    string `json:"password,omitempty"`
+	Password    string `json:"-"`
 	OldPassword string `json:"old_password,omitempty"`
 	NewPassword string `json:"new_password,omitempty"`
+	Units       string `json:"units,omitempty"`
+}
+
+type CalendarTokenDTO struct {
+	UUID     string `json:"uuid,omitempty"`
+	Password string `json:"password"`
+}
+
+// CalendarToken is the secret of a user's calendar feed. Only its hash is
+// stored, so a lost token has to be replaced with a new one.
+type CalendarToken struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// EmailDTO requests an email to the account, like a password reset link.
+type EmailDTO struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetDTO struct {
+	Token          string `json:"token"`
+	Password       string `json:"password"`
+	RepeatPassword string `json:"repeat_password"`
 }
 
 type UserFavouriteCityDTO struct {
@@ -61,6 +104,7 @@ func UpdatedUser(dto UpdateUserDTO) User {
 		UUID:     dto.UUID,
 		Email:    dto.Email,
 		Password: dto.Password,
+		Units:    dto.Units,
 	}
 }
 
@@ -80,3 +124,23 @@ func generatePasswordHash(password string) (string, error) {
 	}
 	return string(hash), nil
 }
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token due to error %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validEmail reports whether email is a plain address, without a display
+// name.
+func validEmail(email string) bool {
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Address == email && len(email) <= maxEmailLength
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
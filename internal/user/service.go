@@ -4,23 +4,69 @@ import (
 	"WeatherServiceAPI/internal/api/cityClient"
 	"WeatherServiceAPI/internal/apperror"
 	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/mail"
+	"WeatherServiceAPI/pkg/units"
+	"bytes"
 	"context"
+	"embed"
 	"errors"
 	"fmt"
-	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
 )
 
 var _ Service = &service{}
 
+const (
+	// authEventsDefault and authEventsMax bound the auth events returned.
+	authEventsDefault = 50
+	authEventsMax     = 500
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+// Options configure account lockout and account emails.
+type Options struct {
+	// MaxFailures failed password checks in a row lock the account for
+	// LockDuration, every further failure doubles the lock up to
+	// MaxLockDuration. Zero MaxFailures disables lockout.
+	MaxFailures     int
+	LockDuration    time.Duration
+	MaxLockDuration time.Duration
+	// VerificationTTL and PasswordResetTTL are how long emailed tokens are
+	// valid. Links in emails point to BaseURL.
+	VerificationTTL  time.Duration
+	PasswordResetTTL time.Duration
+	BaseURL          string
+}
+
 type service struct {
 	storage Storage
 	logger  *logging.Logger
+	mailer  mail.Sender
+	opts    Options
+
+	templates *template.Template
 }
 
-func NewService(userStorage Storage, logger *logging.Logger) (Service, error) {
+// NewService creates the user service. Verification and password reset emails
+// are sent with mailer. Without a mailer emails cannot be confirmed, so new
+// emails are taken as verified and password reset is unavailable.
+func NewService(userStorage Storage, logger *logging.Logger, mailer mail.Sender, opts Options) (Service, error) {
+	templates, err := template.ParseFS(templatesFS, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email templates. error: %w", err)
+	}
+
 	return &service{
-		storage: userStorage,
-		logger:  logger,
+		storage:   userStorage,
+		logger:    logger,
+		mailer:    mailer,
+		opts:      opts,
+		templates: templates,
 	}, nil
 }
 
@@ -30,24 +76,53 @@ type Service interface {
 	GetOne(ctx context.Context, uuid string) (User, error)
 	Update(ctx context.Context, dto UpdateUserDTO) error
 	Delete(ctx context.Context, uuid string) error
+	CreateCalendarToken(ctx context.Context, dto CalendarTokenDTO) (string, error)
+	GetCalendarFeed(ctx context.Context, token string) (User, []cityClient.CityData, error)
 
 	CreateFavourite(ctx context.Context, dto UserFavouriteCityDTO, cityId string) error
 	GetFavourites(ctx context.Context, email, password string) ([]cityClient.CityData, error)
+	// FindFavourites returns the favourite cities of the user without
+	// checking the password, for jobs running on behalf of the user.
+	FindFavourites(ctx context.Context, uuid string) ([]cityClient.CityData, error)
 	DeleteFavourite(ctx context.Context, dto UserFavouriteCityDTO, cityId string) error
+
+	// Authenticate checks the password of the user for action, for the
+	// services managing data of the user. Like every password check of the
+	// user API it honours the lockout, counts failures and is recorded as an
+	// auth event. Wrong passwords are reported as not found.
+	Authenticate(ctx context.Context, uuid, password, action string) (User, error)
+	// FindAuthEvents returns the latest password checks of the account.
+	FindAuthEvents(ctx context.Context, uuid, password string, limit int) ([]AuthEvent, error)
+
+	// VerifyEmail confirms the email of the user the token was sent to.
+	VerifyEmail(ctx context.Context, token string) error
+	// ResendVerification sends a new verification link to an unverified
+	// account. Unknown and verified emails are ignored, so the result does
+	// not reveal accounts.
+	ResendVerification(ctx context.Context, email string) error
+	// RequestPasswordReset emails a password reset token. Unknown emails
+	// are ignored like in ResendVerification.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword sets the password of the user the token was sent to.
+	ResetPassword(ctx context.Context, dto PasswordResetDTO) error
 }
 
 func (s service) Create(ctx context.Context, dto CreateUserDTO) (userUUID string, err error) {
-	s.logger.Debug("check password and repeat password")
+	logger := logging.FromContext(ctx)
+	logger.Debug("check password and repeat password")
 	if dto.Password != dto.RepeatPassword {
 		return userUUID, fmt.Errorf("password does not match repeat password")
 	}
+	if !validEmail(dto.Email) {
+		return userUUID, apperror.NewAppError(nil, "invalid email", "expected an address like user@example.com", "WeatherService-000004")
+	}
 
 	user := NewUser(dto)
 
-	s.logger.Debug("generate password hash")
+	logger.Debug("generate password hash")
 	err = user.GeneratePasswordHash()
 	if err != nil {
-		s.logger.Errorf("failed to create user due to error %v", err)
+		logger.Errorf("failed to create user due to error %v", err)
 		return
 	}
 
@@ -60,22 +135,44 @@ func (s service) Create(ctx context.Context, dto CreateUserDTO) (userUUID string
 		return userUUID, fmt.Errorf("failed to create user. error: %w", err)
 	}
 
+	user.UUID = userUUID
+	if err = s.sendVerification(ctx, user); err != nil {
+		logger.Errorf("failed to send verification email to user %s. error: %v", userUUID, err)
+	}
+
 	return userUUID, nil
 }
 
 func (s service) CreateFavourite(ctx context.Context, dto UserFavouriteCityDTO, cityId string) error {
+	logger := logging.FromContext(ctx)
 	var updatedUser User
-	s.logger.Debug("compare old and new passwords")
+	logger.Debug("compare old and new passwords")
 
-	s.logger.Debug("get user by uuid")
+	logger.Debug("get user by uuid")
 	user, err := s.GetOne(ctx, dto.UUID)
+	if err != nil {
+		return err
+	}
 
-	s.logger.Debug("compare hash current password and database user password")
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(dto.Password))
+	logger.Debug("compare hash current password and database user password")
+	err = s.authorize(ctx, user, dto.Password, AuthActionAddFavourite)
 	if err != nil {
+		if locked(err) {
+			return err
+		}
 		return fmt.Errorf("database user password does not match current password")
 	}
 
+	if !user.Verified() {
+		favourites, err := s.storage.FindFavourites(ctx, user)
+		if err != nil {
+			return err
+		}
+		if len(favourites) >= maxUnverifiedFavourites {
+			return ErrUnverified
+		}
+	}
+
 	updatedUser = UserFavouriteCity(dto)
 	return s.storage.CreateFavourite(ctx, updatedUser, cityId)
 }
@@ -90,7 +187,10 @@ func (s service) GetByEmailAndPassword(ctx context.Context, email, password stri
 		return u, fmt.Errorf("failed to find user by email. error: %w", err)
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+	if err = s.authorize(ctx, u, password, AuthActionLogin); err != nil {
+		if locked(err) {
+			return u, err
+		}
 		return u, apperror.ErrNotFound
 	}
 
@@ -119,44 +219,57 @@ func (s service) GetFavourites(ctx context.Context, email, password string) ([]c
 		return nil, fmt.Errorf("failed to find user by email. error: %w", err)
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+	if err = s.authorize(ctx, u, password, AuthActionFavourites); err != nil {
+		if locked(err) {
+			return nil, err
+		}
 		return nil, apperror.ErrNotFound
 	}
 
 	return s.storage.FindFavourites(ctx, u)
 }
 
+func (s service) FindFavourites(ctx context.Context, uuid string) ([]cityClient.CityData, error) {
+	return s.storage.FindFavourites(ctx, User{UUID: uuid})
+}
+
 func (s service) Update(ctx context.Context, dto UpdateUserDTO) error {
+	logger := logging.FromContext(ctx)
 	var updatedUser User
-	s.logger.Debug("compare old and new passwords")
 
-	fmt.Println("Update dto:", dto)
-	if dto.OldPassword != dto.NewPassword {
-		s.logger.Debug("get user by uuid")
-		user, err := s.GetOne(ctx, dto.UUID)
-		if err != nil {
-			return err
-		}
+	if dto.Units != "" && !units.Valid(dto.Units) {
+		return apperror.NewAppError(nil, "invalid units", "expected: metric, imperial or standard", "WeatherService-000004")
+	}
+	if dto.Email != "" && !validEmail(dto.Email) {
+		return apperror.NewAppError(nil, "invalid email", "expected an address like user@example.com", "WeatherService-000004")
+	}
 
-		s.logger.Debug("compare hash current password and old password")
-		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(dto.OldPassword))
-		if err != nil {
-			return fmt.Errorf("old password does not match current password")
-		}
+	logger.Debug("get user by uuid")
+	user, err := s.GetOne(ctx, dto.UUID)
+	if err != nil {
+		return err
+	}
 
-		if dto.NewPassword != "" {
-			dto.Password = dto.NewPassword
-		} else {
-			dto.Password = dto.OldPassword
+	logger.Debug("compare hash current password and old password")
+	err = s.authorize(ctx, user, dto.OldPassword, AuthActionUpdate)
+	if err != nil {
+		if locked(err) {
+			return err
 		}
+		return fmt.Errorf("old password does not match current password")
 	}
 
+	// only a new password replaces the current one
+	dto.Password = dto.NewPassword
+
 	updatedUser = UpdatedUser(dto)
 
-	s.logger.Debug("generate password hash")
-	err := updatedUser.GeneratePasswordHash()
-	if err != nil {
-		return fmt.Errorf("failed to update user. error %w", err)
+	if updatedUser.Password != "" {
+		logger.Debug("generate password hash")
+		err := updatedUser.GeneratePasswordHash()
+		if err != nil {
+			return fmt.Errorf("failed to update user. error %w", err)
+		}
 	}
 
 	err = s.storage.Update(ctx, updatedUser)
@@ -167,6 +280,24 @@ func (s service) Update(ctx context.Context, dto UpdateUserDTO) error {
 		}
 		return fmt.Errorf("failed to update user. error: %w", err)
 	}
+
+	if dto.Email != "" {
+		logger.Debug("verify changed email")
+		updated, err := s.GetOne(ctx, dto.UUID)
+		if err != nil {
+			return err
+		}
+		if !updated.Verified() {
+			if err = s.sendVerification(ctx, updated); err != nil {
+				logger.Errorf("failed to send verification email to user %s. error: %v", updated.UUID, err)
+			}
+		}
+		if !strings.EqualFold(updated.Email, user.Email) {
+			if err = s.sendEmailChanged(ctx, user, updated.Email); err != nil {
+				logger.Errorf("failed to send email change notice to user %s. error: %v", user.UUID, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -182,19 +313,375 @@ func (s service) Delete(ctx context.Context, uuid string) error {
 	return err
 }
 
+// CreateCalendarToken replaces the calendar feed token of the user, so any
+// previously shared feed URL stops working.
+func (s service) CreateCalendarToken(ctx context.Context, dto CalendarTokenDTO) (string, error) {
+	logger := logging.FromContext(ctx)
+
+	u, err := s.Authenticate(ctx, dto.UUID, dto.Password, AuthActionCalendarToken)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Debug("generate calendar token")
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err = s.storage.SetCalendarTokenHash(ctx, u.UUID, hashToken(token)); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to save calendar token. error: %w", err)
+	}
+
+	return token, nil
+}
+
+// GetCalendarFeed returns the owner of the calendar token and their favourite
+// cities.
+func (s service) GetCalendarFeed(ctx context.Context, token string) (User, []cityClient.CityData, error) {
+	if token == "" {
+		return User{}, nil, apperror.ErrNotFound
+	}
+
+	u, err := s.storage.FindByCalendarTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return u, nil, err
+		}
+		return u, nil, fmt.Errorf("failed to find user by calendar token. error: %w", err)
+	}
+
+	favourites, err := s.storage.FindFavourites(ctx, u)
+	if err != nil {
+		return u, nil, err
+	}
+
+	return u, favourites, nil
+}
+
 func (s service) DeleteFavourite(ctx context.Context, dto UserFavouriteCityDTO, cityId string) error {
+	logger := logging.FromContext(ctx)
 	var updatedUser User
-	s.logger.Debug("compare old and new passwords")
+	logger.Debug("compare old and new passwords")
 
-	s.logger.Debug("get user by uuid")
+	logger.Debug("get user by uuid")
 	user, err := s.GetOne(ctx, dto.UUID)
+	if err != nil {
+		return err
+	}
 
-	s.logger.Debug("compare hash current password and database user password")
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(dto.Password))
+	logger.Debug("compare hash current password and database user password")
+	err = s.authorize(ctx, user, dto.Password, AuthActionDeleteFavourite)
 	if err != nil {
+		if locked(err) {
+			return err
+		}
 		return fmt.Errorf("database user password does not match current password")
 	}
 
 	updatedUser = UserFavouriteCity(dto)
 	return s.storage.DeleteFavourite(ctx, updatedUser, cityId)
 }
+
+func (s service) Authenticate(ctx context.Context, uuid, password, action string) (User, error) {
+	u, err := s.GetOne(ctx, uuid)
+	if err != nil {
+		return u, err
+	}
+	if err = s.authorize(ctx, u, password, action); err != nil {
+		if locked(err) {
+			return u, err
+		}
+		return u, apperror.ErrNotFound
+	}
+	return u, nil
+}
+
+func (s service) FindAuthEvents(ctx context.Context, uuid, password string, limit int) ([]AuthEvent, error) {
+	if limit < 0 || limit > authEventsMax {
+		return nil, apperror.NewAppError(nil, "invalid query parameter limit", fmt.Sprintf("limit must be from 1 to %d", authEventsMax), "WeatherService-000004")
+	}
+	if limit == 0 {
+		limit = authEventsDefault
+	}
+
+	u, err := s.Authenticate(ctx, uuid, password, AuthActionAuthEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.storage.FindAuthEvents(ctx, u.UUID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find auth events. error: %w", err)
+	}
+	return events, nil
+}
+
+func (s service) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return errInvalidToken
+	}
+
+	now := time.Now().UTC()
+	userUUID, err := s.storage.UseToken(ctx, tokenVerifyEmail, hashToken(token), now)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return errInvalidToken
+		}
+		return fmt.Errorf("failed to use verification token. error: %w", err)
+	}
+
+	if err = s.storage.SetEmailVerified(ctx, userUUID, now); err != nil {
+		return fmt.Errorf("failed to verify email. error: %w", err)
+	}
+	return nil
+}
+
+func (s service) ResendVerification(ctx context.Context, email string) error {
+	if s.mailer == nil {
+		return errMailDisabled
+	}
+	u, err := s.storage.FindByEmail(ctx, email)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find user by email. error: %w", err)
+	}
+	if u.Verified() {
+		return nil
+	}
+
+	return s.sendVerification(ctx, u)
+}
+
+func (s service) RequestPasswordReset(ctx context.Context, email string) error {
+	if s.mailer == nil {
+		return errMailDisabled
+	}
+	u, err := s.storage.FindByEmail(ctx, email)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find user by email. error: %w", err)
+	}
+
+	token, expiresAt, err := s.createToken(ctx, u.UUID, tokenPasswordReset, s.opts.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"Email":     u.Email,
+		"Token":     token,
+		"URL":       s.opts.BaseURL + passwordResetConfirmURL,
+		"ExpiresAt": expiresAt.Format(emailTimeFormat),
+	}
+	return s.sendEmail(ctx, u, "Reset your Weather Service password", "password_reset", data)
+}
+
+func (s service) ResetPassword(ctx context.Context, dto PasswordResetDTO) error {
+	logger := logging.FromContext(ctx)
+	if dto.Password == "" || dto.Password != dto.RepeatPassword {
+		return apperror.NewAppError(nil, "password does not match repeat password", "password and repeat_password must be equal and not empty", "WeatherService-000004")
+	}
+	if dto.Token == "" {
+		return errInvalidToken
+	}
+
+	now := time.Now().UTC()
+	userUUID, err := s.storage.UseToken(ctx, tokenPasswordReset, hashToken(dto.Token), now)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return errInvalidToken
+		}
+		return fmt.Errorf("failed to use password reset token. error: %w", err)
+	}
+
+	logger.Debug("generate password hash")
+	hash, err := generatePasswordHash(dto.Password)
+	if err != nil {
+		return fmt.Errorf("failed to reset password. error %w", err)
+	}
+	if err = s.storage.Update(ctx, User{UUID: userUUID, Password: hash}); err != nil {
+		return fmt.Errorf("failed to reset password. error: %w", err)
+	}
+
+	// the token was delivered to the email, which proves the user owns it
+	if err = s.storage.SetEmailVerified(ctx, userUUID, now); err != nil {
+		logger.Errorf("failed to verify email of user %s. error: %v", userUUID, err)
+	}
+	if err = s.storage.ResetFailedLogins(ctx, userUUID); err != nil {
+		logger.Errorf("failed to reset failed logins of user %s. error: %v", userUUID, err)
+	}
+	s.recordAuthEvent(ctx, userUUID, AuthActionPasswordReset, "", now)
+	return nil
+}
+
+// sendVerification emails a verification link to u. Without a mailer the
+// email is marked verified right away, as there is no way to confirm it.
+func (s service) sendVerification(ctx context.Context, u User) error {
+	if s.mailer == nil {
+		return s.storage.SetEmailVerified(ctx, u.UUID, time.Now().UTC())
+	}
+	token, expiresAt, err := s.createToken(ctx, u.UUID, tokenVerifyEmail, s.opts.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"Email":         u.Email,
+		"URL":           fmt.Sprintf("%s%s?token=%s", s.opts.BaseURL, verifyEmailURL, url.QueryEscape(token)),
+		"ExpiresAt":     expiresAt.Format(emailTimeFormat),
+		"MaxFavourites": maxUnverifiedFavourites,
+	}
+	return s.sendEmail(ctx, u, "Confirm your email for Weather Service", "verify_email", data)
+}
+
+// sendEmailChanged notifies the previous address of u that the email of the
+// account was changed to newEmail.
+func (s service) sendEmailChanged(ctx context.Context, u User, newEmail string) error {
+	if s.mailer == nil {
+		return nil
+	}
+	data := map[string]interface{}{
+		"Email":     u.Email,
+		"NewEmail":  newEmail,
+		"ChangedAt": time.Now().UTC().Format(emailTimeFormat),
+		"URL":       s.opts.BaseURL + passwordResetURL,
+	}
+	return s.sendEmail(ctx, u, "Your Weather Service email was changed", "email_changed", data)
+}
+
+// createToken stores a new single-use token of the user valid for ttl.
+func (s service) createToken(ctx context.Context, userUUID, purpose string, ttl time.Duration) (string, time.Time, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	if err = s.storage.CreateToken(ctx, userUUID, purpose, hashToken(token), expiresAt); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to save %s token. error: %w", purpose, err)
+	}
+	return token, expiresAt, nil
+}
+
+// sendEmail renders the template name and sends it to the user. Messages
+// dropped by the rate limit of the mailer are not errors.
+func (s service) sendEmail(ctx context.Context, u User, subject, name string, data interface{}) error {
+	var text bytes.Buffer
+	if err := s.templates.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return fmt.Errorf("failed to render %s email. error: %w", name, err)
+	}
+
+	err := s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: subject,
+		Text:    text.String(),
+	})
+	if errors.Is(err, mail.ErrRateLimited) {
+		s.logger.Warnf("%s email to user %s dropped: %v", name, u.UUID, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send %s email. error: %w", name, err)
+	}
+	return nil
+}
+
+// authorize checks the password of the user for action and records the check
+// as an auth event. Failed checks are counted and lock the account, while it
+// is locked passwords are not checked at all. Requests made with an API key of
+// the user are neither counted nor recorded, except for the actions that
+// always need the password.
+func (s service) authorize(ctx context.Context, u User, password, action string) error {
+	if owner := APIKeyOwner(ctx); owner != "" && owner == u.UUID && !passwordRequired(action) {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if u.LockedUntil != nil && now.Before(*u.LockedUntil) {
+		s.recordAuthEvent(ctx, u.UUID, action, AuthReasonLocked, now)
+		return lockedError(*u.LockedUntil, now)
+	}
+
+	if err := u.CheckPassword(password); err != nil {
+		s.recordAuthEvent(ctx, u.UUID, action, AuthReasonWrongPassword, now)
+
+		failures, storageErr := s.storage.AddFailedLogin(ctx, u.UUID)
+		if storageErr != nil {
+			s.logger.Errorf("failed to count failed login of user %s. error: %v", u.UUID, storageErr)
+			return err
+		}
+		if s.opts.MaxFailures > 0 && failures >= s.opts.MaxFailures {
+			until := now.Add(s.lockDuration(failures))
+			if storageErr = s.storage.LockUntil(ctx, u.UUID, until); storageErr != nil {
+				s.logger.Errorf("failed to lock user %s. error: %v", u.UUID, storageErr)
+				return err
+			}
+			logging.FromContext(ctx).Warnf("user %s is locked until %s after %d failed logins", u.UUID, until.Format(time.RFC3339), failures)
+		}
+		return err
+	}
+
+	if u.FailedLogins > 0 || u.LockedUntil != nil {
+		if err := s.storage.ResetFailedLogins(ctx, u.UUID); err != nil {
+			s.logger.Errorf("failed to reset failed logins of user %s. error: %v", u.UUID, err)
+		}
+	}
+	s.recordAuthEvent(ctx, u.UUID, action, "", now)
+	return nil
+}
+
+// passwordRequired reports whether action needs the password even with an API
+// key of the user. Changing the credentials or creating keys with a leaked key
+// would take over the account.
+func passwordRequired(action string) bool {
+	return action == AuthActionUpdate || action == AuthActionCreateAPIKey
+}
+
+// lockDuration returns the lock after failures failed checks, doubled for
+// every failure over the limit.
+func (s service) lockDuration(failures int) time.Duration {
+	lock := s.opts.LockDuration
+	for i := s.opts.MaxFailures; i < failures && lock < s.opts.MaxLockDuration; i++ {
+		lock *= 2
+	}
+	if lock > s.opts.MaxLockDuration {
+		lock = s.opts.MaxLockDuration
+	}
+	return lock
+}
+
+// recordAuthEvent stores a password check. Errors are only logged, the
+// request does not depend on the audit trail.
+func (s service) recordAuthEvent(ctx context.Context, uuid, action, reason string, now time.Time) {
+	client := ClientFromContext(ctx)
+	event := AuthEvent{
+		Action:    action,
+		Success:   reason == "",
+		Reason:    reason,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		CreatedAt: now,
+	}
+	if err := s.storage.CreateAuthEvent(ctx, uuid, event); err != nil {
+		s.logger.Errorf("failed to record auth event of user %s. error: %v", uuid, err)
+	}
+}
+
+func lockedError(until, now time.Time) error {
+	retryAfter := int(until.Sub(now).Seconds()) + 1
+	return apperror.NewAppError(&LockedError{Until: until}, "account is temporarily locked", fmt.Sprintf("too many failed password checks, retry after %ds", retryAfter), "WeatherService-000009")
+}
+
+// locked reports whether err is returned for a locked account.
+func locked(err error) bool {
+	var lockedErr *LockedError
+	return errors.As(err, &lockedErr)
+}
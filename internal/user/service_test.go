@@ -0,0 +1,73 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLockDuration(t *testing.T) {
+	s := service{opts: Options{
+		MaxFailures:     5,
+		LockDuration:    time.Minute,
+		MaxLockDuration: time.Hour,
+	}}
+
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "at the limit", failures: 5, want: time.Minute},
+		{name: "below the limit", failures: 1, want: time.Minute},
+		{name: "one over the limit", failures: 6, want: 2 * time.Minute},
+		{name: "three over the limit", failures: 8, want: 8 * time.Minute},
+		{name: "capped", failures: 11, want: time.Hour},
+		{name: "far over the limit", failures: 1000, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.lockDuration(tt.failures); got != tt.want {
+				t.Errorf("lockDuration(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockDurationCappedBelowFirstLock(t *testing.T) {
+	s := service{opts: Options{MaxFailures: 3, LockDuration: time.Hour, MaxLockDuration: time.Minute}}
+
+	if got := s.lockDuration(3); got != time.Minute {
+		t.Errorf("lockDuration(3) = %s, want %s", got, time.Minute)
+	}
+}
+
+func TestPasswordRequired(t *testing.T) {
+	tests := []struct {
+		action string
+		want   bool
+	}{
+		{action: AuthActionUpdate, want: true},
+		{action: AuthActionCreateAPIKey, want: true},
+		{action: AuthActionAPIKeys, want: false},
+		{action: AuthActionFavourites, want: false},
+		{action: AuthActionCalendarToken, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			if got := passwordRequired(tt.action); got != tt.want {
+				t.Errorf("passwordRequired(%q) = %t, want %t", tt.action, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAPIKeyOwner(t *testing.T) {
+	s := service{}
+	u := User{UUID: "5b6bd7b1-6c4b-4b0e-8d55-4c1f3c1b7c2e"}
+	ctx := ContextWithAPIKeyOwner(context.Background(), u.UUID)
+
+	if err := s.authorize(ctx, u, "", AuthActionFavourites); err != nil {
+		t.Errorf("authorize with the key of the owner: %v", err)
+	}
+}
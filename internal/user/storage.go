@@ -3,6 +3,7 @@ package user
 import (
 	"WeatherServiceAPI/internal/api/cityClient"
 	"context"
+	"time"
 )
 
 type Storage interface {
@@ -11,6 +12,24 @@ type Storage interface {
 	FindOne(ctx context.Context, uuid string) (User, error)
 	Update(ctx context.Context, user User) error
 	Delete(ctx context.Context, uuid string) error
+	SetCalendarTokenHash(ctx context.Context, uuid, tokenHash string) error
+	FindByCalendarTokenHash(ctx context.Context, tokenHash string) (User, error)
+
+	// AddFailedLogin counts a failed password check and returns the failed
+	// checks since the last successful one.
+	AddFailedLogin(ctx context.Context, uuid string) (int, error)
+	LockUntil(ctx context.Context, uuid string, until time.Time) error
+	ResetFailedLogins(ctx context.Context, uuid string) error
+	CreateAuthEvent(ctx context.Context, uuid string, event AuthEvent) error
+	FindAuthEvents(ctx context.Context, uuid string, limit int) ([]AuthEvent, error)
+
+	SetEmailVerified(ctx context.Context, uuid string, at time.Time) error
+	// CreateToken stores the hash of a single-use token of the user and
+	// replaces the unused tokens of the user for the same purpose.
+	CreateToken(ctx context.Context, uuid, purpose, tokenHash string, expiresAt time.Time) error
+	// UseToken marks the token as used and returns the uuid of its user, or
+	// apperror.ErrNotFound for unknown, used and expired tokens.
+	UseToken(ctx context.Context, purpose, tokenHash string, now time.Time) (string, error)
 
 	CreateFavourite(ctx context.Context, user User, cityId string) error
 	FindFavourites(ctx context.Context, user User) ([]cityClient.CityData, error)
@@ -0,0 +1,294 @@
+package db
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/webhook"
+	"WeatherServiceAPI/pkg/client/postgresql"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+var _ webhook.Storage = &db{}
+
+// db filters webhooks by owner: an owner without a user uuid is an admin and
+// sees every webhook.
+type db struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func (d db) Create(ctx context.Context, w webhook.Webhook) (webhook.Webhook, error) {
+	q := `INSERT INTO webhooks (user_id, url, secret, events) VALUES (NULLIF($1, '')::uuid, $2, $3, $4) RETURNING id, created_at;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err := d.client.QueryRow(ctx, q, w.UserUUID, w.URL, w.Secret, w.Events).Scan(&w.ID, &w.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return w, newErr
+		}
+		return w, err
+	}
+
+	return w, nil
+}
+
+func (d db) FindAll(ctx context.Context, owner webhook.Owner) ([]webhook.Webhook, error) {
+	q := `SELECT id, COALESCE(user_id::text, ''), url, events, created_at FROM webhooks
+WHERE ($1 = '' OR user_id = NULLIF($1, '')::uuid) ORDER BY created_at;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, owner.UserUUID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]webhook.Webhook, 0)
+
+	for rows.Next() {
+		var w webhook.Webhook
+		if err = rows.Scan(&w.ID, &w.UserUUID, &w.URL, &w.Events, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (d db) FindOne(ctx context.Context, owner webhook.Owner, id string) (w webhook.Webhook, err error) {
+	q := `SELECT id, COALESCE(user_id::text, ''), url, events, created_at FROM webhooks
+WHERE id = $2 AND ($1 = '' OR user_id = NULLIF($1, '')::uuid);`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	if err = d.client.QueryRow(ctx, q, owner.UserUUID, id).Scan(&w.ID, &w.UserUUID, &w.URL, &w.Events, &w.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return w, apperror.ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return w, newErr
+		}
+		return w, err
+	}
+
+	return w, nil
+}
+
+func (d db) Delete(ctx context.Context, owner webhook.Owner, id string) error {
+	q := `DELETE FROM webhooks WHERE id = $2 AND ($1 = '' OR user_id = NULLIF($1, '')::uuid);`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	tag, err := d.client.Exec(ctx, q, owner.UserUUID, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (d db) EnqueueForUser(ctx context.Context, eventType, userUUID string, payload []byte) (int64, error) {
+	q := `INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+SELECT id, $1::text, $3::jsonb FROM webhooks
+WHERE $1::text = ANY (events) AND (user_id IS NULL OR user_id = $2::uuid);`
+
+	return d.enqueue(ctx, q, eventType, userUUID, payload)
+}
+
+func (d db) EnqueueForCity(ctx context.Context, eventType, cityID string, payload []byte) (int64, error) {
+	q := `INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+SELECT w.id, $1::text, $3::jsonb FROM webhooks w
+WHERE $1::text = ANY (w.events) AND (w.user_id IS NULL OR EXISTS (
+    SELECT 1 FROM user_favorites f WHERE f.user_id = w.user_id AND f.city_id = $2::uuid
+));`
+
+	return d.enqueue(ctx, q, eventType, cityID, payload)
+}
+
+func (d db) enqueue(ctx context.Context, q, eventType, arg string, payload []byte) (int64, error) {
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	tag, err := d.client.Exec(ctx, q, eventType, arg, string(payload))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return 0, newErr
+		}
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (d db) Claim(ctx context.Context, limit int, lease time.Duration) ([]webhook.Job, error) {
+	q := `UPDATE webhook_deliveries d SET next_attempt_at = now() + make_interval(secs => $2)
+FROM webhooks w
+WHERE w.id = d.webhook_id AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.webhook_id, d.event_type, d.payload::text, d.status, d.attempts, d.next_attempt_at,
+    d.response_status, d.last_error, d.created_at, d.delivered_at, w.url, w.secret;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, limit, lease.Seconds())
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]webhook.Job, 0, limit)
+
+	for rows.Next() {
+		var job webhook.Job
+		var payload string
+		if err = rows.Scan(&job.ID, &job.WebhookID, &job.EventType, &payload, &job.Status, &job.Attempts, &job.NextAttemptAt,
+			&job.ResponseStatus, &job.LastError, &job.CreatedAt, &job.DeliveredAt, &job.URL, &job.Secret); err != nil {
+			return nil, err
+		}
+		job.Payload = json.RawMessage(payload)
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (d db) MarkDelivered(ctx context.Context, id string, responseStatus int) error {
+	q := `UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1, response_status = $2,
+    last_error = NULL, delivered_at = now() WHERE id = $1;`
+
+	return d.exec(ctx, q, id, responseStatus)
+}
+
+func (d db) MarkFailed(ctx context.Context, id string, responseStatus *int, lastError string, retryIn time.Duration, dead bool) error {
+	q := `UPDATE webhook_deliveries SET status = $5, attempts = attempts + 1, response_status = $2,
+    last_error = $3, next_attempt_at = now() + make_interval(secs => $4) WHERE id = $1;`
+
+	status := webhook.StatusPending
+	if dead {
+		status = webhook.StatusDead
+	}
+
+	return d.exec(ctx, q, id, responseStatus, lastError, retryIn.Seconds(), status)
+}
+
+func (d db) exec(ctx context.Context, q string, args ...interface{}) error {
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	_, err := d.client.Exec(ctx, q, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return newErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (d db) FindDeliveries(ctx context.Context, webhookID, status string, limit int) ([]webhook.Delivery, error) {
+	q := `SELECT id, webhook_id, event_type, payload::text, status, attempts, next_attempt_at,
+    response_status, last_error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+ORDER BY created_at DESC LIMIT $3;`
+
+	d.logger.Trace(fmt.Sprintf("SQL Query: %s", q))
+
+	rows, err := d.client.Query(ctx, q, webhookID, status, limit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			d.logger.Error(newErr)
+			return nil, newErr
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]webhook.Delivery, 0)
+
+	for rows.Next() {
+		var delivery webhook.Delivery
+		var payload string
+		if err = rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+			&delivery.ResponseStatus, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt); err != nil {
+			return nil, err
+		}
+		delivery.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func NewStorage(client postgresql.Client, logger *logging.Logger) webhook.Storage {
+	return &db{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"WeatherServiceAPI/pkg/logging"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxDrain is how much of a response is read so the connection can be reused,
+// longer responses close it.
+const maxDrain = 64 << 10
+
+// sharedAddressSpace is the carrier-grade NAT range, not covered by
+// net.IP.IsPrivate.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Options configure the delivery of webhooks.
+type Options struct {
+	// MaxAttempts is the number of failed attempts after which a delivery is
+	// dead-lettered.
+	MaxAttempts int
+	// Backoff is the delay after the first failure, doubled after each next
+	// one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout limits a single attempt.
+	Timeout time.Duration
+	// PollInterval is how often the queue is checked for due retries.
+	PollInterval time.Duration
+	// BatchSize is the number of deliveries sent concurrently.
+	BatchSize int
+	// AllowPrivateNetworks lets webhooks reach loopback, private and
+	// link-local addresses, for local testing only.
+	AllowPrivateNetworks bool
+}
+
+// Dispatcher sends queued deliveries. The queue is in Postgres, so deliveries
+// survive restarts and several instances can share it.
+type Dispatcher struct {
+	storage Storage
+	logger  *logging.Logger
+	client  *http.Client
+	opts    Options
+	wake    chan struct{}
+}
+
+// NewDispatcher creates a dispatcher sending with client; nil uses a client
+// with opts.Timeout that does not follow redirects, ignores proxies and only
+// connects to public addresses unless opts.AllowPrivateNetworks is set.
+func NewDispatcher(storage Storage, logger *logging.Logger, client *http.Client, opts Options) *Dispatcher {
+	if client == nil {
+		dialer := &net.Dialer{Timeout: opts.Timeout}
+		if !opts.AllowPrivateNetworks {
+			dialer.Control = publicOnly
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+
+		client = &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	return &Dispatcher{
+		storage: storage,
+		logger:  logger,
+		client:  client,
+		opts:    opts,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Wake makes the dispatcher check the queue without waiting for the poll
+// interval.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := d.dispatch(ctx)
+			if err != nil {
+				d.logger.Errorf("failed to dispatch webhooks. error: %v", err)
+				break
+			}
+			if n < d.opts.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatch sends one batch of due deliveries and returns its size.
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	jobs, err := d.storage.Claim(ctx, d.opts.BatchSize, d.lease())
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim webhook deliveries. error: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			d.deliver(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+
+	return len(jobs), nil
+}
+
+// lease covers an attempt and recording its result.
+func (d *Dispatcher) lease() time.Duration {
+	return 2*d.opts.Timeout + time.Minute
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, job Job) {
+	status, err := d.send(ctx, job)
+	if err == nil {
+		if err = d.storage.MarkDelivered(ctx, job.ID, status); err != nil {
+			d.logger.Errorf("failed to mark webhook delivery %s delivered. error: %v", job.ID, err)
+		}
+		return
+	}
+
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+	attempts := job.Attempts + 1
+	dead := attempts >= d.opts.MaxAttempts
+	if dead {
+		d.logger.Warnf("webhook delivery %s to %s is dead after %d attempts. error: %v", job.ID, job.URL, attempts, err)
+	} else {
+		d.logger.Debugf("webhook delivery %s to %s failed. error: %v", job.ID, job.URL, err)
+	}
+
+	if err = d.storage.MarkFailed(ctx, job.ID, responseStatus, err.Error(), d.backoff(attempts), dead); err != nil {
+		d.logger.Errorf("failed to mark webhook delivery %s failed. error: %v", job.ID, err)
+	}
+}
+
+// send posts the payload signed with the secret of the webhook. Any 2xx
+// response is a success.
+func (d *Dispatcher) send(ctx context.Context, job Job) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(job.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "WeatherServiceAPI-Webhooks")
+	req.Header.Set(HeaderID, job.ID)
+	req.Header.Set(HeaderEvent, job.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(job.Secret, timestamp, job.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// the body is not kept, the delivery log is shown to the owner of the
+	// webhook and must not reveal what the receiver answers
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// publicOnly is a net.Dialer Control rejecting connections to loopback,
+// private, link-local and unspecified addresses. It runs on the resolved
+// address, so host names resolving to them are rejected as well.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid webhook address %s", host)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+	return nil
+}
+
+// backoff is the delay before the attempt after attempts failures.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.Backoff
+	for i := 1; i < attempts && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	return delay
+}
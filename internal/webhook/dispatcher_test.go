@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDispatcherSend(t *testing.T) {
+	job := Job{
+		Delivery: Delivery{ID: "delivery-1", EventType: "alert", Payload: []byte(`{"type":"alert"}`)},
+		Secret:   "secret",
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantErr    string
+	}{
+		{
+			name:       "delivered",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "server error without the body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				io.WriteString(w, "database password is hunter2")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantErr:    "unexpected status 500",
+		},
+		{
+			name:       "redirect not followed",
+			handler:    func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/other", http.StatusFound) },
+			wantStatus: http.StatusFound,
+			wantErr:    "unexpected status 302",
+		},
+		{
+			name: "large body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, strings.Repeat("x", 10*maxDrain))
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				body, _ = io.ReadAll(r.Body)
+				tt.handler(w, r)
+			}))
+			defer server.Close()
+
+			client := server.Client()
+			client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+			d := NewDispatcher(nil, logging.GetLogger(), client, Options{Timeout: time.Second})
+
+			job := job
+			job.URL = server.URL
+			status, err := d.send(context.Background(), job)
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+
+			if got == nil {
+				t.Fatal("request was not sent")
+			}
+			if string(body) != string(job.Payload) {
+				t.Errorf("body = %s, want %s", body, job.Payload)
+			}
+			if got.Header.Get(HeaderID) != job.ID || got.Header.Get(HeaderEvent) != job.EventType {
+				t.Errorf("headers %s = %q, %s = %q", HeaderID, got.Header.Get(HeaderID), HeaderEvent, got.Header.Get(HeaderEvent))
+			}
+			timestamp, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+			if err != nil {
+				t.Fatalf("invalid %s: %v", HeaderTimestamp, err)
+			}
+			if want := Sign(job.Secret, timestamp, job.Payload); got.Header.Get(HeaderSignature) != want {
+				t.Errorf("%s = %q, want %q", HeaderSignature, got.Header.Get(HeaderSignature), want)
+			}
+		})
+	}
+}
+
+func TestDispatcherSendRejectsPrivateAddresses(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	job := Job{Delivery: Delivery{ID: "delivery-1", Payload: []byte(`{}`)}}
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "loopback", url: server.URL},
+		{name: "localhost", url: "http://localhost:" + port},
+		{name: "unspecified", url: "http://0.0.0.0:" + port},
+		{name: "private", url: "http://10.0.0.1/"},
+		{name: "link-local", url: "http://169.254.169.254/latest/meta-data/"},
+		{name: "mapped loopback", url: "http://[::ffff:127.0.0.1]:" + port},
+	}
+
+	d := NewDispatcher(nil, logging.GetLogger(), nil, Options{Timeout: time.Second})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := job
+			job.URL = tt.url
+			if _, err := d.send(context.Background(), job); err == nil || !strings.Contains(err.Error(), "is not public") {
+				t.Errorf("send to %s: error = %v, want a rejected address", tt.url, err)
+			}
+		})
+	}
+	if requests != 0 {
+		t.Errorf("%d requests reached the server", requests)
+	}
+}
+
+func TestDispatcherSendAllowPrivateNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	d := NewDispatcher(nil, logging.GetLogger(), nil, Options{Timeout: time.Second, AllowPrivateNetworks: true})
+	job := Job{Delivery: Delivery{ID: "delivery-1", Payload: []byte(`{}`)}, URL: server.URL}
+	if status, err := d.send(context.Background(), job); err != nil || status != http.StatusOK {
+		t.Errorf("send = %d, %v, want 200", status, err)
+	}
+}
@@ -0,0 +1,342 @@
+package webhook
+
+import (
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	userWebhooksURL   = "/api/users/:uuid/webhooks"
+	userWebhookURL    = "/api/users/:uuid/webhooks/:id"
+	userDeliveriesURL = "/api/users/:uuid/webhooks/:id/deliveries"
+
+	adminWebhooksURL   = "/api/webhooks"
+	adminWebhookURL    = "/api/webhooks/:id"
+	adminDeliveriesURL = "/api/webhooks/:id/deliveries"
+)
+
+type handler struct {
+	Logger         *logging.Logger
+	WebhookService Service
+	Limiter        *ratelimit.Limiter
+}
+
+func NewHandler(logger *logging.Logger, webhookService Service, limiter *ratelimit.Limiter) handlers.Handler {
+	return &handler{
+		Logger:         logger,
+		WebhookService: webhookService,
+		Limiter:        limiter,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, userWebhooksURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.GetUserWebhooks)))
+	router.HandlerFunc(http.MethodPost, userWebhooksURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.CreateUserWebhook)))
+	router.HandlerFunc(http.MethodDelete, userWebhookURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.DeleteUserWebhook)))
+	router.HandlerFunc(http.MethodGet, userDeliveriesURL, apperror.Middleware(user.LimitAuth(h.Limiter, h.GetUserDeliveries)))
+
+	if h.WebhookService.AdminEnabled() {
+		router.HandlerFunc(http.MethodGet, adminWebhooksURL, apperror.Middleware(h.GetWebhooks))
+		router.HandlerFunc(http.MethodPost, adminWebhooksURL, apperror.Middleware(h.CreateWebhook))
+		router.HandlerFunc(http.MethodDelete, adminWebhookURL, apperror.Middleware(h.DeleteWebhook))
+		router.HandlerFunc(http.MethodGet, adminDeliveriesURL, apperror.Middleware(h.GetDeliveries))
+	}
+}
+
+// GetUserWebhooks godoc
+// @Summary      Get user webhooks
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true  "User uuid"
+// @Param        password    query    string  true  "User password"
+// @Success      200  {array}  Webhook
+// @Router       /users/{uuid}/webhooks [get]
+func (h *handler) GetUserWebhooks(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER WEBHOOKS")
+	w.Header().Set("Content-Type", "application/json")
+
+	owner, err := h.userOwner(r, "")
+	if err != nil {
+		return err
+	}
+	return h.writeWebhooks(w, r, owner)
+}
+
+// CreateUserWebhook godoc
+// @Summary      Create user webhook
+// @Description  Register a URL receiving signed JSON payloads of the user's alerts ("alert") and refreshes of the user's favourite cities ("forecast"). The secret of the X-Webhook-Signature header is only returned here
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        uuid       path     string  true  "User uuid"
+// @Param        webhook    body     CreateWebhookDTO  true  "Webhook"
+// @Success      201  {object}  Webhook
+// @Router       /users/{uuid}/webhooks [post]
+func (h *handler) CreateUserWebhook(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("CREATE USER WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Debug("decode create webhook dto")
+	var dto CreateWebhookDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme. check swagger API")
+	}
+
+	owner, err := h.userOwner(r, dto.Password)
+	if err != nil {
+		return err
+	}
+	return h.createWebhook(w, r, owner, dto)
+}
+
+// DeleteUserWebhook godoc
+// @Summary      Delete user webhook
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        uuid    path     string  true  "User uuid"
+// @Param        id      path     string  true  "Webhook id"
+// @Param        dto     body     DeleteWebhookDTO  true  "User password"
+// @Success      204
+// @Router       /users/{uuid}/webhooks/{id} [delete]
+func (h *handler) DeleteUserWebhook(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("DELETE USER WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Debug("decode delete webhook dto")
+	var dto DeleteWebhookDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme")
+	}
+
+	owner, err := h.userOwner(r, dto.Password)
+	if err != nil {
+		return err
+	}
+	return h.deleteWebhook(w, r, owner)
+}
+
+// GetUserDeliveries godoc
+// @Summary      Get user webhook deliveries
+// @Description  Delivery log of a webhook, newest first: status (pending, delivered or dead), attempts, the last response status and error
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        uuid        path     string  true   "User uuid"
+// @Param        id          path     string  true   "Webhook id"
+// @Param        password    query    string  true   "User password"
+// @Param        status      query    string  false  "pending, delivered or dead"
+// @Param        limit       query    int     false  "Number of deliveries, 50 by default, at most 200"
+// @Success      200  {array}  Delivery
+// @Router       /users/{uuid}/webhooks/{id}/deliveries [get]
+func (h *handler) GetUserDeliveries(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET USER WEBHOOK DELIVERIES")
+	w.Header().Set("Content-Type", "application/json")
+
+	owner, err := h.userOwner(r, "")
+	if err != nil {
+		return err
+	}
+	return h.writeDeliveries(w, r, owner)
+}
+
+// GetWebhooks godoc
+// @Summary      Get all webhooks
+// @Description  Admin only, authorized with "Authorization: Bearer <webhooks.admin_token>"
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization    header    string  true  "Bearer admin token"
+// @Success      200  {array}  Webhook
+// @Router       /webhooks [get]
+func (h *handler) GetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET WEBHOOKS")
+	w.Header().Set("Content-Type", "application/json")
+
+	owner, err := h.adminOwner(r)
+	if err != nil {
+		return err
+	}
+	return h.writeWebhooks(w, r, owner)
+}
+
+// CreateWebhook godoc
+// @Summary      Create admin webhook
+// @Description  Admin only. Register a URL receiving signed JSON payloads of all alerts ("alert") and refreshes of all cities ("forecast")
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization    header    string  true  "Bearer admin token"
+// @Param        webhook          body      CreateWebhookDTO  true  "Webhook"
+// @Success      201  {object}  Webhook
+// @Router       /webhooks [post]
+func (h *handler) CreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("CREATE WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	owner, err := h.adminOwner(r)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("decode create webhook dto")
+	var dto CreateWebhookDTO
+	defer r.Body.Close()
+	if err = json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return fmt.Errorf("invalid JSON scheme. check swagger API")
+	}
+	return h.createWebhook(w, r, owner, dto)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete webhook
+// @Description  Admin only, deletes webhooks of any user
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization    header    string  true  "Bearer admin token"
+// @Param        id               path      string  true  "Webhook id"
+// @Success      204
+// @Router       /webhooks/{id} [delete]
+func (h *handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("DELETE WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	owner, err := h.adminOwner(r)
+	if err != nil {
+		return err
+	}
+	return h.deleteWebhook(w, r, owner)
+}
+
+// GetDeliveries godoc
+// @Summary      Get webhook deliveries
+// @Description  Admin only. Delivery log of any webhook, newest first
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization    header    string  true   "Bearer admin token"
+// @Param        id               path      string  true   "Webhook id"
+// @Param        status           query     string  false  "pending, delivered or dead"
+// @Param        limit            query     int     false  "Number of deliveries, 50 by default, at most 200"
+// @Success      200  {array}  Delivery
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *handler) GetDeliveries(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("GET WEBHOOK DELIVERIES")
+	w.Header().Set("Content-Type", "application/json")
+
+	owner, err := h.adminOwner(r)
+	if err != nil {
+		return err
+	}
+	return h.writeDeliveries(w, r, owner)
+}
+
+// userOwner authenticates the user of the uuid path parameter with password,
+// or the password query parameter when it is empty.
+func (h *handler) userOwner(r *http.Request, password string) (Owner, error) {
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	if password == "" {
+		password = r.URL.Query().Get("password")
+	}
+	if password == "" && user.APIKeyOwner(r.Context()) == "" {
+		return Owner{}, apperror.NewAppError(nil, "invalid query parameter password", "password is required", "WeatherService-000004")
+	}
+
+	return h.WebhookService.Authenticate(r.Context(), params.ByName("uuid"), password)
+}
+
+func (h *handler) adminOwner(r *http.Request) (Owner, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return h.WebhookService.AuthenticateAdmin(token)
+}
+
+func (h *handler) writeWebhooks(w http.ResponseWriter, r *http.Request, owner Owner) error {
+	webhooks, err := h.WebhookService.FindAll(r.Context(), owner)
+	if err != nil {
+		return err
+	}
+
+	webhooksBytes, err := json.Marshal(webhooks)
+	if err != nil {
+		return fmt.Errorf("failed to marshall webhooks. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(webhooksBytes)
+	return nil
+}
+
+func (h *handler) createWebhook(w http.ResponseWriter, r *http.Request, owner Owner, dto CreateWebhookDTO) error {
+	webhook, err := h.WebhookService.Create(r.Context(), owner, dto)
+	if err != nil {
+		return err
+	}
+
+	webhookBytes, err := json.Marshal(webhook)
+	if err != nil {
+		return fmt.Errorf("failed to marshall webhook. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(webhookBytes)
+	return nil
+}
+
+func (h *handler) deleteWebhook(w http.ResponseWriter, r *http.Request, owner Owner) error {
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	if err := h.WebhookService.Delete(r.Context(), owner, params.ByName("id")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *handler) writeDeliveries(w http.ResponseWriter, r *http.Request, owner Owner) error {
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			return apperror.NewAppError(err, "invalid query parameter limit", "expected a positive integer", "WeatherService-000004")
+		}
+	}
+
+	deliveries, err := h.WebhookService.FindDeliveries(r.Context(), owner, params.ByName("id"), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		return err
+	}
+
+	deliveriesBytes, err := json.Marshal(deliveries)
+	if err != nil {
+		return fmt.Errorf("failed to marshall webhook deliveries. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(deliveriesBytes)
+	return nil
+}
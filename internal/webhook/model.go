@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Event types a webhook can subscribe to.
+const (
+	AlertEvent    = "alert"
+	ForecastEvent = "forecast"
+	DigestEvent   = "digest"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Headers of a delivery request.
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Webhook receives the events of its owner: a user webhook gets the alerts and
+// digests of the user and refreshes of the user's favourite cities, an admin
+// webhook (without UserUUID) gets every alert, digest and refresh. Admins manage the
+// webhooks of all users. The secret is only returned when the webhook is
+// created.
+type Webhook struct {
+	ID        string    `json:"id"`
+	UserUUID  string    `json:"user_uuid,omitempty"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is a queued event of a webhook and the state of its attempts.
+type Delivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Job is a delivery claimed by the dispatcher with the target of its webhook.
+type Job struct {
+	Delivery
+	URL    string
+	Secret string
+}
+
+// Payload is the JSON body sent to webhooks.
+type Payload struct {
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Owner is the user managing webhooks, or an admin when UserUUID is empty.
+type Owner struct {
+	UserUUID string
+	// Verified is set for users who confirmed their email, only they can
+	// register webhooks.
+	Verified bool
+}
+
+func (o Owner) Admin() bool {
+	return o.UserUUID == ""
+}
+
+type CreateWebhookDTO struct {
+	UUID     string   `json:"uuid,omitempty"`
+	Password string   `json:"password,omitempty"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events"`
+}
+
+type DeleteWebhookDTO struct {
+	Password string `json:"password,omitempty"`
+}
+
+// Sign returns the X-Webhook-Signature of a body sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// secret of the webhook. Receivers recompute it to verify the sender.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
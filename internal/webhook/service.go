@@ -0,0 +1,258 @@
+package webhook
+
+import (
+	"WeatherServiceAPI/internal/alert"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/digest"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+const (
+	// maxWebhooksPerUser limits the webhooks of a user.
+	maxWebhooksPerUser = 10
+
+	deliveriesLimitDefault = 50
+	deliveriesLimitMax     = 200
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type service struct {
+	storage     Storage
+	logger      *logging.Logger
+	userService user.Service
+	dispatcher  *Dispatcher
+	adminToken  string
+}
+
+// NewService creates the webhooks service. Queued deliveries wake up the
+// dispatcher; an empty adminToken disables admin webhooks.
+func NewService(storage Storage, logger *logging.Logger, userService user.Service, dispatcher *Dispatcher, adminToken string) (Service, error) {
+	return &service{
+		storage:     storage,
+		logger:      logger,
+		userService: userService,
+		dispatcher:  dispatcher,
+		adminToken:  adminToken,
+	}, nil
+}
+
+type Service interface {
+	Authenticate(ctx context.Context, userUUID, password string) (Owner, error)
+	AuthenticateAdmin(token string) (Owner, error)
+	AdminEnabled() bool
+
+	Create(ctx context.Context, owner Owner, dto CreateWebhookDTO) (Webhook, error)
+	FindAll(ctx context.Context, owner Owner) ([]Webhook, error)
+	Delete(ctx context.Context, owner Owner, id string) error
+	FindDeliveries(ctx context.Context, owner Owner, webhookID, status string, limit int) ([]Delivery, error)
+
+	PublishAlert(ctx context.Context, a alert.Alert) error
+	PublishForecast(ctx context.Context, update weatherClient.CityUpdate) error
+	PublishDigest(ctx context.Context, d digest.Digest) error
+}
+
+// Authenticate checks the password of the user through the user service, so
+// failed checks count towards the lockout of the account.
+func (s *service) Authenticate(ctx context.Context, userUUID, password string) (Owner, error) {
+	u, err := s.userService.Authenticate(ctx, userUUID, password, user.AuthActionWebhooks)
+	if err != nil {
+		return Owner{}, err
+	}
+	return Owner{UserUUID: u.UUID, Verified: u.Verified()}, nil
+}
+
+// AuthenticateAdmin checks the admin token. Admin endpoints are reported as
+// not found to callers without it.
+func (s *service) AuthenticateAdmin(token string) (Owner, error) {
+	if !s.AdminEnabled() || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		return Owner{}, apperror.ErrNotFound
+	}
+	return Owner{}, nil
+}
+
+func (s *service) AdminEnabled() bool {
+	return s.adminToken != ""
+}
+
+func (s *service) Create(ctx context.Context, owner Owner, dto CreateWebhookDTO) (Webhook, error) {
+	logger := logging.FromContext(ctx)
+	if !owner.Admin() && !owner.Verified {
+		return Webhook{}, user.ErrUnverified
+	}
+
+	target, err := url.Parse(dto.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return Webhook{}, apperror.NewAppError(err, "invalid webhook url", "expected an absolute http or https URL", "WeatherService-000004")
+	}
+
+	events := dto.Events
+	if len(events) == 0 {
+		events = []string{AlertEvent, ForecastEvent, DigestEvent}
+	}
+	seen := make(map[string]bool, len(events))
+	for _, event := range events {
+		if event != AlertEvent && event != ForecastEvent && event != DigestEvent {
+			return Webhook{}, apperror.NewAppError(nil, "invalid webhook event", fmt.Sprintf("unknown event %q. expected: alert, forecast or digest", event), "WeatherService-000004")
+		}
+		seen[event] = true
+	}
+	events = events[:0:0]
+	for _, event := range []string{AlertEvent, ForecastEvent, DigestEvent} {
+		if seen[event] {
+			events = append(events, event)
+		}
+	}
+
+	if !owner.Admin() {
+		webhooks, err := s.storage.FindAll(ctx, owner)
+		if err != nil {
+			return Webhook{}, fmt.Errorf("failed to find webhooks. error: %w", err)
+		}
+		if len(webhooks) >= maxWebhooksPerUser {
+			return Webhook{}, apperror.NewAppError(nil, "too many webhooks", fmt.Sprintf("a user can have at most %d webhooks", maxWebhooksPerUser), "WeatherService-000004")
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	logger.Debug("create webhook")
+	webhook, err := s.storage.Create(ctx, Webhook{
+		UserUUID: owner.UserUUID,
+		URL:      target.String(),
+		Secret:   secret,
+		Events:   events,
+	})
+	if err != nil {
+		return Webhook{}, fmt.Errorf("failed to create webhook. error: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (s *service) FindAll(ctx context.Context, owner Owner) ([]Webhook, error) {
+	webhooks, err := s.storage.FindAll(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhooks. error: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (s *service) Delete(ctx context.Context, owner Owner, id string) error {
+	if !uuidRegexp.MatchString(id) {
+		return apperror.ErrNotFound
+	}
+
+	err := s.storage.Delete(ctx, owner, id)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete webhook. error: %w", err)
+	}
+	return nil
+}
+
+func (s *service) FindDeliveries(ctx context.Context, owner Owner, webhookID, status string, limit int) ([]Delivery, error) {
+	if status != "" && status != StatusPending && status != StatusDelivered && status != StatusDead {
+		return nil, apperror.NewAppError(nil, "invalid query parameter status", "expected pending, delivered or dead", "WeatherService-000004")
+	}
+	if limit < 0 || limit > deliveriesLimitMax {
+		return nil, apperror.NewAppError(nil, "invalid query parameter limit", fmt.Sprintf("limit must be from 1 to %d", deliveriesLimitMax), "WeatherService-000004")
+	}
+	if limit == 0 {
+		limit = deliveriesLimitDefault
+	}
+	if !uuidRegexp.MatchString(webhookID) {
+		return nil, apperror.ErrNotFound
+	}
+
+	if _, err := s.storage.FindOne(ctx, owner, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.storage.FindDeliveries(ctx, webhookID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhook deliveries. error: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (s *service) PublishAlert(ctx context.Context, a alert.Alert) error {
+	payload, err := newPayload(AlertEvent, a)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.storage.EnqueueForUser(ctx, AlertEvent, a.UserUUID, payload)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue alert webhooks. error: %w", err)
+	}
+	s.queued(n)
+	return nil
+}
+
+func (s *service) PublishForecast(ctx context.Context, update weatherClient.CityUpdate) error {
+	payload, err := newPayload(ForecastEvent, update)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.storage.EnqueueForCity(ctx, ForecastEvent, update.CityID, payload)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue forecast webhooks. error: %w", err)
+	}
+	s.queued(n)
+	return nil
+}
+
+func (s *service) PublishDigest(ctx context.Context, d digest.Digest) error {
+	payload, err := newPayload(DigestEvent, d)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.storage.EnqueueForUser(ctx, DigestEvent, d.UserUUID, payload)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue digest webhooks. error: %w", err)
+	}
+	s.queued(n)
+	return nil
+}
+
+func (s *service) queued(n int64) {
+	if n > 0 && s.dispatcher != nil {
+		s.dispatcher.Wake()
+	}
+}
+
+func newPayload(eventType string, data interface{}) ([]byte, error) {
+	payload, err := json.Marshal(Payload{Type: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshall webhook payload. error: %w", err)
+	}
+	return payload, nil
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret. error: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
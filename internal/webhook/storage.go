@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"context"
+	"time"
+)
+
+type Storage interface {
+	Create(ctx context.Context, webhook Webhook) (Webhook, error)
+	FindAll(ctx context.Context, owner Owner) ([]Webhook, error)
+	FindOne(ctx context.Context, owner Owner, id string) (Webhook, error)
+	Delete(ctx context.Context, owner Owner, id string) error
+
+	// EnqueueForUser queues the payload for admin webhooks and the webhooks of
+	// the user subscribed to eventType, returning the number of deliveries.
+	EnqueueForUser(ctx context.Context, eventType, userUUID string, payload []byte) (int64, error)
+	// EnqueueForCity queues the payload for admin webhooks and the webhooks of
+	// users with the city in favourites subscribed to eventType.
+	EnqueueForCity(ctx context.Context, eventType, cityID string, payload []byte) (int64, error)
+
+	// Claim takes up to limit due pending deliveries and postpones them by
+	// lease, so other dispatchers skip them while they are being sent and they
+	// are retried if the dispatcher stops.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Job, error)
+	MarkDelivered(ctx context.Context, id string, responseStatus int) error
+	// MarkFailed records a failed attempt and schedules the next one after
+	// retryIn, or moves the delivery to the dead letters when dead.
+	MarkFailed(ctx context.Context, id string, responseStatus *int, lastError string, retryIn time.Duration, dead bool) error
+	FindDeliveries(ctx context.Context, webhookID, status string, limit int) ([]Delivery, error)
+}
@@ -0,0 +1,350 @@
+package ws
+
+import (
+	"WeatherServiceAPI/internal/alert"
+	"WeatherServiceAPI/internal/api/cityClient"
+	"WeatherServiceAPI/internal/api/weatherClient"
+	"WeatherServiceAPI/internal/apperror"
+	"WeatherServiceAPI/internal/handlers"
+	"WeatherServiceAPI/internal/ratelimit"
+	"WeatherServiceAPI/internal/user"
+	"WeatherServiceAPI/pkg/events"
+	"WeatherServiceAPI/pkg/i18n"
+	"WeatherServiceAPI/pkg/logging"
+	"WeatherServiceAPI/pkg/units"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	wsURL = "/api/ws"
+
+	// authTimeout is how long a client may take to send the auth message.
+	authTimeout = 10 * time.Second
+
+	citiesTopic     = "cities"
+	cityTopicPrefix = "city:"
+	alertsTopic     = "alerts"
+)
+
+// ClientMessage is a message sent by the client.
+//
+//	{"type": "auth", "email": "...", "password": "..."}
+//	{"type": "subscribe", "topics": ["city:London", "city:Oslo,NO", "alerts"]}
+//	{"type": "unsubscribe", "topics": ["city:London"]}
+//
+// "cities" subscribes to every tracked city and "alerts" to the alerts of the
+// authenticated user.
+type ClientMessage struct {
+	Type     string   `json:"type"`
+	Email    string   `json:"email,omitempty"`
+	Password string   `json:"password,omitempty"`
+	Topics   []string `json:"topics,omitempty"`
+}
+
+// ServerMessage is a message sent to the client. Events carry the event id,
+// type and payload; replies to client messages carry the resulting topics or
+// an error.
+type ServerMessage struct {
+	Type    string      `json:"type"`
+	ID      uint64      `json:"id,omitempty"`
+	Event   string      `json:"event,omitempty"`
+	Topic   string      `json:"topic,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Topics  []string    `json:"topics,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+type handler struct {
+	logger      *logging.Logger
+	hub         *Hub
+	broker      *events.Broker
+	cityService cityClient.Service
+	userService user.Service
+	limiter     *ratelimit.Limiter
+	upgrader    websocket.Upgrader
+}
+
+func NewHandler(logger *logging.Logger, hub *Hub, broker *events.Broker, cityService cityClient.Service, userService user.Service, limiter *ratelimit.Limiter) handlers.Handler {
+	return &handler{
+		logger:      logger,
+		hub:         hub,
+		broker:      broker,
+		cityService: cityService,
+		userService: userService,
+		limiter:     limiter,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			// the API is public and users authenticate with the auth message,
+			// never with credentials a browser sends by itself
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, wsURL, apperror.Middleware(h.Serve))
+}
+
+// Serve godoc
+// @Summary      WebSocket subscriptions
+// @Description  Upgrade to a WebSocket. Authenticate with an auth message with email and password sent first, then send subscribe/unsubscribe messages with topics "city:<city>", "cities" or "alerts" to receive forecast changes and alerts
+// @Tags         Stream
+// @Param        units     query    string  false  "metric, imperial or standard. Defaults to the units of the user"
+// @Param        lang      query    string  false  "Response language: en or ru. Defaults to Accept-Language"
+// @Success      101
+// @Router       /ws [get]
+func (h *handler) Serve(w http.ResponseWriter, r *http.Request) error {
+	logger := logging.FromContext(r.Context())
+	logger.Info("OPEN WEBSOCKET")
+
+	// Credentials are only accepted in the auth message. Browsers send basic
+	// auth to any origin by themselves, and passwords in the URL end up in
+	// proxy logs and the browser history.
+	if r.URL.Query().Has("password") {
+		return apperror.NewAppError(nil, "invalid query parameter password", "send the credentials in an auth message after connecting", "WeatherService-000004")
+	}
+
+	// the connection outlives the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warnf("failed to clear write deadline. error: %v", err)
+	}
+
+	wsConn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied
+		logger.Debugf("failed to upgrade websocket. error: %v", err)
+		return nil
+	}
+
+	c := &conn{
+		hub:    h.hub,
+		ws:     wsConn,
+		logger: logger,
+		sub:    h.broker.Subscribe(),
+		send:   make(chan interface{}, sendBuffer),
+		done:   make(chan struct{}),
+	}
+	defer c.sub.Close()
+
+	if !h.hub.add(c) {
+		wsConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections"), time.Now().Add(writeWait))
+		wsConn.Close()
+		return nil
+	}
+	defer h.hub.remove(c)
+
+	// requests are detached from the HTTP request, which ends with the upgrade
+	ctx := user.ContextWithClient(logging.ContextWithLogger(context.Background(), logger), user.ClientFromContext(r.Context()))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	session := &session{
+		handler: h,
+		conn:    c,
+		query:   r,
+		lang:    i18n.FromContext(r.Context()),
+	}
+	if err = session.configureUnits(); err != nil {
+		wsConn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()), time.Now().Add(writeWait))
+		wsConn.Close()
+		return nil
+	}
+	c.present = session.present
+
+	go c.writePump()
+	session.readPump(ctx)
+
+	return nil
+}
+
+// session is the state of one connection. user is only used by the read
+// pump; units and names are shared with the write pump and guarded by mu.
+type session struct {
+	handler *handler
+	conn    *conn
+	user    *user.User
+	query   *http.Request
+	lang    string
+
+	mu    sync.RWMutex
+	units units.Units
+	names map[string]string
+}
+
+func (s *session) configureUnits() error {
+	system := s.query.URL.Query().Get("units")
+	if system == "" && s.user != nil {
+		system = s.user.Units
+	}
+
+	u, err := units.Parse(system, s.query.URL.Query().Get("pressure"))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.units = u
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *session) readPump(ctx context.Context) {
+	c := s.conn
+	defer c.close()
+
+	c.ws.SetReadLimit(maxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(c.hub.pingInterval * 2))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(c.hub.pingInterval * 2))
+	})
+
+	if s.user == nil {
+		c.ws.SetReadDeadline(time.Now().Add(authTimeout))
+	}
+
+	for {
+		var msg ClientMessage
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logger.Debugf("websocket closed. error: %v", err)
+			}
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(c.hub.pingInterval * 2))
+
+		if !s.handle(ctx, msg) {
+			return
+		}
+	}
+}
+
+// handle processes a client message. It returns false when the connection
+// must be closed.
+func (s *session) handle(ctx context.Context, msg ClientMessage) bool {
+	if s.user == nil && msg.Type != "auth" {
+		s.conn.reply(ServerMessage{Type: "error", Message: "authenticate first"})
+		return false
+	}
+
+	switch msg.Type {
+	case "auth":
+		if s.user != nil {
+			return s.conn.reply(ServerMessage{Type: "error", Message: "already authenticated"})
+		}
+		if result := s.handler.limiter.AllowAuth(ctx, user.ClientFromContext(ctx).IP, msg.Email, time.Now()); !result.Allowed {
+			s.conn.reply(ServerMessage{Type: "error", Message: s.errorMessage(apperror.ErrTooManyRequests)})
+			return false
+		}
+		u, err := s.handler.userService.GetByEmailAndPassword(ctx, msg.Email, msg.Password)
+		var lockedErr *user.LockedError
+		if errors.As(err, &lockedErr) {
+			s.conn.reply(ServerMessage{Type: "error", Message: s.errorMessage(err)})
+			return false
+		}
+		if err != nil {
+			s.conn.reply(ServerMessage{Type: "error", Message: i18n.Error(s.lang, "WeatherService-000003", "invalid credentials")})
+			return false
+		}
+		s.user = &u
+		if s.query.URL.Query().Get("units") == "" {
+			if err = s.configureUnits(); err != nil {
+				s.conn.reply(ServerMessage{Type: "error", Message: err.Error()})
+				return false
+			}
+		}
+		return s.conn.reply(ServerMessage{Type: "authenticated"})
+	case "subscribe":
+		topics, err := s.resolveTopics(ctx, msg.Topics)
+		if err != nil {
+			return s.conn.reply(ServerMessage{Type: "error", Message: s.errorMessage(err)})
+		}
+		s.conn.sub.Add(topics...)
+		return s.conn.reply(ServerMessage{Type: "subscribed", Topics: msg.Topics})
+	case "unsubscribe":
+		topics, err := s.resolveTopics(ctx, msg.Topics)
+		if err != nil {
+			return s.conn.reply(ServerMessage{Type: "error", Message: s.errorMessage(err)})
+		}
+		s.conn.sub.Remove(topics...)
+		return s.conn.reply(ServerMessage{Type: "unsubscribed", Topics: msg.Topics})
+	default:
+		return s.conn.reply(ServerMessage{Type: "error", Message: fmt.Sprintf("unknown message type %q", msg.Type)})
+	}
+}
+
+// resolveTopics maps client topics to broker topics.
+func (s *session) resolveTopics(ctx context.Context, topics []string) ([]string, error) {
+	resolved := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		switch {
+		case topic == citiesTopic:
+			if err := s.loadNames(ctx); err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, weatherClient.AllCitiesTopic)
+		case topic == alertsTopic:
+			resolved = append(resolved, alert.Topic(s.user.UUID))
+		case strings.HasPrefix(topic, cityTopicPrefix):
+			city, err := s.handler.cityService.Resolve(ctx, strings.TrimPrefix(topic, cityTopicPrefix))
+			if err != nil {
+				return nil, err
+			}
+			s.setName(city)
+			resolved = append(resolved, weatherClient.CityTopic(city.Id))
+		default:
+			return nil, apperror.NewAppError(nil, fmt.Sprintf("unknown topic %q", topic), "expected city:<city>, cities or alerts", "WeatherService-000004")
+		}
+	}
+	return resolved, nil
+}
+
+func (s *session) loadNames(ctx context.Context) error {
+	cities, err := s.handler.cityService.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, city := range cities {
+		s.setName(city)
+	}
+	return nil
+}
+
+func (s *session) setName(city cityClient.CityData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+	s.names[city.Id] = city.Name
+}
+
+func (s *session) errorMessage(err error) string {
+	if appErr, ok := err.(*apperror.AppError); ok {
+		return appErr.Localize(s.lang).Message
+	}
+	return i18n.Error(s.lang, "WeatherService-000001", "internal system error")
+}
+
+// present converts an event for this client. It runs on the write pump.
+func (s *session) present(e events.Event) (interface{}, bool) {
+	msg := ServerMessage{Type: "event", ID: e.ID, Event: e.Type, Topic: e.Topic, Data: e.Payload}
+
+	if update, ok := e.Payload.(weatherClient.CityUpdate); ok {
+		s.mu.RLock()
+		update = update.Present(s.units, s.lang)
+		update.Name = s.names[update.CityID]
+		s.mu.RUnlock()
+		msg.Data = update
+	}
+
+	return msg, true
+}
@@ -0,0 +1,162 @@
+package ws
+
+import (
+	"WeatherServiceAPI/pkg/events"
+	"WeatherServiceAPI/pkg/logging"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// writeWait is the time allowed to write a message to the peer.
+	writeWait = 10 * time.Second
+
+	// maxMessageSize is the largest message accepted from the peer.
+	maxMessageSize = 4096
+
+	// sendBuffer is the number of replies queued per connection.
+	sendBuffer = 16
+)
+
+// Hub keeps track of the open connections.
+type Hub struct {
+	mu             sync.Mutex
+	conns          map[*conn]struct{}
+	count          int64
+	maxConnections int
+	pingInterval   time.Duration
+}
+
+func NewHub(maxConnections int, pingInterval time.Duration) *Hub {
+	return &Hub{
+		conns:          make(map[*conn]struct{}),
+		maxConnections: maxConnections,
+		pingInterval:   pingInterval,
+	}
+}
+
+// Connections returns the number of open connections.
+func (h *Hub) Connections() int {
+	return int(atomic.LoadInt64(&h.count))
+}
+
+func (h *Hub) add(c *conn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.maxConnections > 0 && len(h.conns) >= h.maxConnections {
+		return false
+	}
+	h.conns[c] = struct{}{}
+	atomic.StoreInt64(&h.count, int64(len(h.conns)))
+	return true
+}
+
+func (h *Hub) remove(c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns, c)
+	atomic.StoreInt64(&h.count, int64(len(h.conns)))
+}
+
+// conn is a single client. Replies go through send and events through the
+// broker subscription; both are drained by writePump only, so a slow client
+// never blocks the hub or the publishers. A client whose subscription overflows
+// is disconnected.
+type conn struct {
+	hub    *Hub
+	ws     *websocket.Conn
+	logger *logging.Logger
+	sub    *events.Subscription
+	send   chan interface{}
+	done   chan struct{}
+	once   sync.Once
+
+	// present turns an event into the message sent to this client
+	present func(e events.Event) (interface{}, bool)
+}
+
+func (c *conn) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// reply queues a message for the client. It reports false when the queue is
+// full and the connection is being closed.
+func (c *conn) reply(msg interface{}) bool {
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.done:
+		return false
+	default:
+		c.logger.Warn("websocket client is not reading replies, closing")
+		c.close()
+		return false
+	}
+}
+
+func (c *conn) writePump() {
+	ticker := time.NewTicker(c.hub.pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.ws.Close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			// deliver queued replies, such as the error causing the close
+			for len(c.send) > 0 {
+				if err := c.write(<-c.send); err != nil {
+					return
+				}
+			}
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		case msg := <-c.send:
+			if err := c.write(msg); err != nil {
+				c.close()
+				return
+			}
+		case e, ok := <-c.sub.C():
+			if !ok {
+				if c.sub.Lagged() {
+					c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+					c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client is too slow"))
+				}
+				c.close()
+				return
+			}
+			msg, ok := c.present(e)
+			if !ok {
+				continue
+			}
+			if err := c.write(msg); err != nil {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
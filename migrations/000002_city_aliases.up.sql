@@ -0,0 +1,20 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS city_aliases
+(
+    city_id    uuid         NOT NULL,
+    alias      VARCHAR(100) NOT NULL,
+    normalized VARCHAR(100) NOT NULL,
+    lang       VARCHAR(16)  NOT NULL DEFAULT '',
+
+    CONSTRAINT city_fk FOREIGN KEY (city_id) REFERENCES cities (id) ON DELETE CASCADE,
+    CONSTRAINT city_alias_unique UNIQUE (city_id, normalized)
+);
+
+CREATE INDEX IF NOT EXISTS city_aliases_normalized_idx ON city_aliases (normalized);
+CREATE INDEX IF NOT EXISTS city_aliases_normalized_trgm_idx ON city_aliases USING gin (normalized gin_trgm_ops);
+
+INSERT INTO city_aliases (city_id, alias, normalized)
+SELECT id, name, lower(name)
+FROM cities
+ON CONFLICT (city_id, normalized) DO NOTHING;
@@ -0,0 +1 @@
+DROP TABLE coordinate_weather;
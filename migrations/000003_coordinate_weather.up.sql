@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS coordinate_weather
+(
+    precision  SMALLINT    NOT NULL,
+    lat_key    INTEGER     NOT NULL,
+    lon_key    INTEGER     NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL,
+    data_json  json        NOT NULL,
+
+    CONSTRAINT coordinate_weather_pk PRIMARY KEY (precision, lat_key, lon_key)
+);
+
+CREATE INDEX IF NOT EXISTS coordinate_weather_fetched_at_idx ON coordinate_weather (fetched_at);
@@ -0,0 +1 @@
+DROP TABLE reverse_geocoding;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS reverse_geocoding
+(
+    precision  SMALLINT    NOT NULL,
+    lat_key    INTEGER     NOT NULL,
+    lon_key    INTEGER     NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL,
+    data_json  json        NOT NULL,
+
+    CONSTRAINT reverse_geocoding_pk PRIMARY KEY (precision, lat_key, lon_key)
+);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS units VARCHAR(16) NOT NULL DEFAULT 'metric';
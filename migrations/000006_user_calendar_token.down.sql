@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN calendar_token_hash;
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_token_hash VARCHAR(64) UNIQUE;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS alert_rules
+(
+    id           uuid primary key default gen_random_uuid(),
+    user_id      uuid             NOT NULL,
+    city_id      uuid             NOT NULL,
+    metric       VARCHAR(20)      NOT NULL,
+    operator     VARCHAR(10)      NOT NULL,
+    threshold    DOUBLE PRECISION NOT NULL,
+    within_hours INTEGER          NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ      NOT NULL DEFAULT now(),
+
+    CONSTRAINT alert_rules_user_fk FOREIGN KEY (user_id) REFERENCES users (uuid) ON DELETE CASCADE,
+    CONSTRAINT alert_rules_city_fk FOREIGN KEY (city_id) REFERENCES cities (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS alert_rules_user_idx ON alert_rules (user_id);
+CREATE INDEX IF NOT EXISTS alert_rules_city_idx ON alert_rules (city_id);
+
+CREATE TABLE IF NOT EXISTS alert_events
+(
+    id        uuid primary key default gen_random_uuid(),
+    rule_id   uuid             NOT NULL,
+    slot_time TIMESTAMPTZ      NOT NULL,
+    until     TIMESTAMPTZ      NOT NULL,
+    value     DOUBLE PRECISION NOT NULL,
+    fired_at  TIMESTAMPTZ      NOT NULL DEFAULT now(),
+
+    CONSTRAINT alert_events_rule_fk FOREIGN KEY (rule_id) REFERENCES alert_rules (id) ON DELETE CASCADE,
+    CONSTRAINT alert_events_rule_slot_unique UNIQUE (rule_id, slot_time)
+);
+
+CREATE INDEX IF NOT EXISTS alert_events_rule_until_idx ON alert_events (rule_id, until DESC);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid,
+    url        TEXT        NOT NULL,
+    secret     VARCHAR(64) NOT NULL,
+    events     TEXT[]      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT webhooks_user_fk FOREIGN KEY (user_id) REFERENCES users (uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_user_idx ON webhooks (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              uuid primary key default gen_random_uuid(),
+    webhook_id      uuid        NOT NULL,
+    event_type      VARCHAR(20) NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          VARCHAR(10) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    response_status INTEGER,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ,
+
+    CONSTRAINT webhook_deliveries_webhook_fk FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, created_at DESC);
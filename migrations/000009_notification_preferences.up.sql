@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS notification_preferences
+(
+    user_id      uuid primary key,
+    email_alerts BOOLEAN     NOT NULL DEFAULT true,
+    email_digest BOOLEAN     NOT NULL DEFAULT false,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT notification_preferences_user_fk FOREIGN KEY (user_id) REFERENCES users (uuid) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS notification_preferences_digest_idx;
+
+ALTER TABLE notification_preferences
+    DROP COLUMN IF EXISTS last_digest_at,
+    DROP COLUMN IF EXISTS digest_hours,
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS digest_time;
@@ -0,0 +1,7 @@
+ALTER TABLE notification_preferences
+    ADD COLUMN IF NOT EXISTS digest_time    TIME        NOT NULL DEFAULT '07:00',
+    ADD COLUMN IF NOT EXISTS timezone       TEXT        NOT NULL DEFAULT 'UTC',
+    ADD COLUMN IF NOT EXISTS digest_hours   INT         NOT NULL DEFAULT 24,
+    ADD COLUMN IF NOT EXISTS last_digest_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS notification_preferences_digest_idx ON notification_preferences (user_id) WHERE email_digest;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id            uuid primary key default gen_random_uuid(),
+    user_id       uuid        NOT NULL,
+    name          TEXT        NOT NULL DEFAULT '',
+    prefix        TEXT        NOT NULL UNIQUE,
+    key_hash      TEXT        NOT NULL,
+    scopes        TEXT[]      NOT NULL,
+    rate_limit    INT         NOT NULL,
+    request_count BIGINT      NOT NULL DEFAULT 0,
+    last_used_at  TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at    TIMESTAMPTZ,
+
+    CONSTRAINT api_keys_user_fk FOREIGN KEY (user_id) REFERENCES users (uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_idx ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS api_key_usage
+(
+    key_id   uuid   NOT NULL,
+    day      DATE   NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (key_id, day),
+    CONSTRAINT api_key_usage_key_fk FOREIGN KEY (key_id) REFERENCES api_keys (id) ON DELETE CASCADE
+);
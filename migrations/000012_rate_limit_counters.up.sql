@@ -0,0 +1,9 @@
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_counters
+(
+    key          TEXT primary key,
+    window_start TIMESTAMPTZ NOT NULL,
+    count        INTEGER     NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_counters_expires_idx ON rate_limit_counters (expires_at);
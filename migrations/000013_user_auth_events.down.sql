@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_auth_events;
+
+ALTER TABLE users DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS failed_logins;
@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_logins INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS user_auth_events
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid        NOT NULL,
+    action     TEXT        NOT NULL,
+    success    BOOLEAN     NOT NULL,
+    reason     TEXT        NOT NULL DEFAULT '',
+    ip         TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT user_auth_events_user_fk FOREIGN KEY (user_id) REFERENCES users (uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_auth_events_user_idx ON user_auth_events (user_id, created_at DESC);
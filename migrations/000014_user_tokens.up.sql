@@ -0,0 +1,19 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- accounts created before verification was introduced keep working
+UPDATE users SET email_verified_at = now() WHERE email_verified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS user_tokens
+(
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid        NOT NULL,
+    purpose    TEXT        NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT user_tokens_user_fk FOREIGN KEY (user_id) REFERENCES users (uuid) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_idx ON user_tokens (user_id, purpose);
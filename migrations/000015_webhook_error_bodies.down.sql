@@ -0,0 +1,2 @@
+-- the removed response bodies cannot be restored
+SELECT 1;
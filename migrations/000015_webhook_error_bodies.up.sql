@@ -0,0 +1,5 @@
+-- response bodies are no longer kept in the delivery log, drop the ones
+-- recorded before
+UPDATE webhook_deliveries
+SET last_error = substring(last_error FROM '^unexpected status [0-9]+')
+WHERE last_error ~ '^unexpected status [0-9]+: ';
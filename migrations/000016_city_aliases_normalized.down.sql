@@ -0,0 +1,2 @@
+-- the normalized aliases are valid for the previous schema as well
+SELECT 1;
@@ -0,0 +1,30 @@
+-- 000002 backfilled the aliases with lower(name), but lookups use the form of
+-- cityClient.NormalizeName: diacritics stripped, runs of spaces, '+', '-' and
+-- '_' collapsed into one space
+CREATE TEMPORARY TABLE city_aliases_fixed AS
+SELECT city_id,
+       normalized AS old,
+       btrim(regexp_replace(
+               normalize(regexp_replace(normalize(lower(alias), NFD), '[\u0300-\u036f]', '', 'g'), NFC),
+               '[[:space:]+_-]+', ' ', 'g')) AS new
+FROM city_aliases
+WHERE lang = ''
+  AND normalized = lower(alias);
+
+DELETE FROM city_aliases_fixed
+WHERE new = old;
+
+-- the normalized alias already exists for the city, the stale row is redundant
+DELETE FROM city_aliases a
+USING city_aliases_fixed f
+WHERE a.city_id = f.city_id
+  AND a.normalized = f.old
+  AND EXISTS (SELECT 1 FROM city_aliases c WHERE c.city_id = f.city_id AND c.normalized = f.new);
+
+UPDATE city_aliases a
+SET normalized = f.new
+FROM city_aliases_fixed f
+WHERE a.city_id = f.city_id
+  AND a.normalized = f.old;
+
+DROP TABLE city_aliases_fixed;
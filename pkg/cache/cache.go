@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"WeatherServiceAPI/pkg/i18n"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	contentType string
+	body        []byte
+	etag        string
+}
+
+// Cache keeps successful GET responses in memory until Invalidate is called.
+// Concurrent misses for the same key are collapsed into a single handler call.
+type Cache struct {
+	mu          sync.RWMutex
+	entries     map[string]entry
+	maxEntries  int
+	generation  uint64
+	group       singleflight.Group
+	nextRefresh func() time.Time
+}
+
+// New creates a cache of at most maxEntries responses. nextRefresh reports
+// when cached data is expected to change and is used to derive Cache-Control
+// max-age.
+func New(nextRefresh func() time.Time, maxEntries int) *Cache {
+	return &Cache{
+		entries:     make(map[string]entry),
+		maxEntries:  maxEntries,
+		nextRefresh: nextRefresh,
+	}
+}
+
+// Invalidate drops every cached response.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]entry)
+	c.generation++
+}
+
+// Middleware serves GET requests from the cache, keyed by path, query and the
+// response language.
+func (c *Cache) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+
+		c.mu.RLock()
+		e, ok := c.entries[key]
+		generation := c.generation
+		c.mu.RUnlock()
+
+		if ok {
+			w.Header().Set("X-Cache", "HIT")
+			c.write(w, r, e)
+			return
+		}
+
+		var rec *recorder
+		v, _, _ := c.group.Do(key, func() (interface{}, error) {
+			rec = newRecorder()
+			next(rec, r)
+			if rec.status != http.StatusOK {
+				return rec, nil
+			}
+
+			e := entry{
+				contentType: rec.header.Get("Content-Type"),
+				body:        rec.body.Bytes(),
+				etag:        etag(rec.body.Bytes()),
+			}
+
+			c.mu.Lock()
+			if c.generation == generation {
+				c.store(key, e)
+			}
+			c.mu.Unlock()
+
+			return e, nil
+		})
+
+		w.Header().Set("X-Cache", "MISS")
+		switch res := v.(type) {
+		case entry:
+			c.write(w, r, res)
+		case *recorder:
+			if rec == nil {
+				// the response could not be cached and was produced for
+				// another request, so build our own
+				next(w, r)
+				return
+			}
+			res.copyTo(w)
+		}
+	}
+}
+
+// store adds the entry, evicting an arbitrary one when the cache is full, so
+// requests with ever new query parameters cannot grow it without bound.
+func (c *Cache) store(key string, e entry) {
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = e
+}
+
+func (c *Cache) write(w http.ResponseWriter, r *http.Request, e entry) {
+	w.Header().Set("ETag", e.etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", c.maxAge()))
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, e.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", e.contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(e.body)
+}
+
+func (c *Cache) maxAge() int {
+	if c.nextRefresh == nil {
+		return 0
+	}
+
+	next := c.nextRefresh()
+	if next.IsZero() {
+		return 0
+	}
+
+	seconds := math.Ceil(time.Until(next).Seconds())
+	if seconds < 0 {
+		return 0
+	}
+	return int(seconds)
+}
+
+func cacheKey(r *http.Request) string {
+	return i18n.FromContext(r.Context()) + ":" + r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder captures a handler response so it can be stored and replayed.
+type recorder struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) copyTo(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
@@ -0,0 +1,174 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are kept in golang-migrate layout (000001_name.up.sql) and the
+// applied version is tracked in the same schema_migrations table, so the CLI
+// can still be used against a database migrated by the service.
+const migrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL);`
+
+type migration struct {
+	version int64
+	path    string
+}
+
+// Migrate applies all up migrations from dir that are newer than the version
+// recorded in the database and returns the resulting version.
+func Migrate(ctx context.Context, client Client, dir string) (int64, error) {
+	migrations, err := readMigrations(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = client.Exec(ctx, migrationsTable); err != nil {
+		return 0, fmt.Errorf("failed to create migrations table. error: %w", err)
+	}
+
+	current, dirty, err := migrationVersion(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return current, fmt.Errorf("database is dirty at migration version %d. fix it manually", current)
+	}
+
+	if current == 0 && len(migrations) > 0 && migrations[0].version == 1 {
+		if current, err = baseline(ctx, client); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		sql, err := os.ReadFile(m.path)
+		if err != nil {
+			return current, fmt.Errorf("failed to read migration %s. error: %w", m.path, err)
+		}
+
+		err = applyMigration(ctx, client, m.version, string(sql))
+		if err != nil {
+			return current, fmt.Errorf("failed to apply migration %s. error: %w", filepath.Base(m.path), err)
+		}
+		current = m.version
+	}
+
+	return current, nil
+}
+
+// MigrationVersion returns the version recorded in schema_migrations.
+func MigrationVersion(ctx context.Context, client Client) (int64, error) {
+	version, dirty, err := migrationVersion(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return version, fmt.Errorf("database is dirty at migration version %d", version)
+	}
+	return version, nil
+}
+
+// LatestMigrationVersion returns the version of the newest up migration in dir.
+func LatestMigrationVersion(dir string) (int64, error) {
+	migrations, err := readMigrations(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].version, nil
+}
+
+func migrationVersion(ctx context.Context, client Client) (version int64, dirty bool, err error) {
+	q := `SELECT version, dirty FROM schema_migrations LIMIT 1;`
+
+	err = client.QueryRow(ctx, q).Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to read migration version. error: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// baseline records the initial schema as applied for databases created from
+// it before the service applied migrations, which have its tables but no
+// recorded version. It returns the resulting version.
+func baseline(ctx context.Context, client Client) (int64, error) {
+	var exists bool
+	q := `SELECT to_regclass('cities') IS NOT NULL;`
+	if err := client.QueryRow(ctx, q).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to check existing schema. error: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	if _, err := client.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES (1, false);`); err != nil {
+		return 0, fmt.Errorf("failed to record the initial schema. error: %w", err)
+	}
+	return 1, nil
+}
+
+func applyMigration(ctx context.Context, client Client, version int64, sql string) error {
+	tx, err := client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM schema_migrations;`); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false);`, version); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func readMigrations(dir string) ([]migration, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(files))
+	for _, file := range files {
+		name := filepath.Base(file)
+		prefix, _, found := strings.Cut(name, "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name %s. expected: 000001_name.up.sql", name)
+		}
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s. error: %w", name, err)
+		}
+
+		migrations = append(migrations, migration{version: version, path: file})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
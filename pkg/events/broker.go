@@ -0,0 +1,243 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a message published on a topic. IDs increase monotonically within
+// a process and are used by clients to resume after a reconnect.
+type Event struct {
+	ID      uint64      `json:"id"`
+	Topic   string      `json:"topic"`
+	Type    string      `json:"type"`
+	Time    time.Time   `json:"time"`
+	Payload interface{} `json:"payload"`
+}
+
+// Broker is an in-process publish/subscribe hub. It keeps the latest events so
+// subscribers can replay what they missed. A subscriber that does not keep up
+// with its buffer is dropped instead of slowing down publishers.
+type Broker struct {
+	mu         sync.RWMutex
+	nextID     uint64
+	history    []Event
+	historyCap int
+	bufferSize int
+	subs       map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker keeping historySize events for replay and
+// buffering bufferSize events per subscriber.
+func NewBroker(historySize, bufferSize int) *Broker {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &Broker{
+		nextID:     1,
+		history:    make([]Event, 0, historySize),
+		historyCap: historySize,
+		bufferSize: bufferSize,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends an event to every subscriber of topic.
+func (b *Broker) Publish(topic, eventType string, payload interface{}) Event {
+	b.mu.Lock()
+	e := Event{ID: b.nextID, Topic: topic, Type: eventType, Time: time.Now().UTC(), Payload: payload}
+	b.nextID++
+	if b.historyCap > 0 {
+		if len(b.history) == b.historyCap {
+			copy(b.history, b.history[1:])
+			b.history = b.history[:len(b.history)-1]
+		}
+		b.history = append(b.history, e)
+	}
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		if s.matches(topic) {
+			s.send(e)
+		}
+	}
+
+	return e
+}
+
+// Subscribe registers a subscription to topics. A topic ending with '*'
+// matches every topic with that prefix.
+func (b *Broker) Subscribe(topics ...string) *Subscription {
+	s := &Subscription{
+		broker: b,
+		topics: make(map[string]struct{}),
+		events: make(chan Event, b.bufferSize),
+	}
+	s.Add(topics...)
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+// Replay returns the kept events on topics published after afterID, oldest
+// first. complete is false when events after afterID were already dropped
+// from the history.
+func (b *Broker) Replay(afterID uint64, topics ...string) (events []Event, complete bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if afterID >= b.nextID {
+		// the id was issued by an earlier process, nothing can be replayed
+		return nil, false
+	}
+
+	complete = len(b.history) == 0 && afterID+1 == b.nextID ||
+		len(b.history) > 0 && b.history[0].ID <= afterID+1
+
+	for _, e := range b.history {
+		if e.ID > afterID && matchAny(topics, e.Topic) {
+			events = append(events, e)
+		}
+	}
+
+	return events, complete
+}
+
+// LastID returns the id of the latest published event, 0 if none.
+func (b *Broker) LastID() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.nextID - 1
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+func (b *Broker) remove(s *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// Subscription receives the events of its topics on C. The channel is closed
+// when the subscription is closed or dropped for lagging behind.
+type Subscription struct {
+	broker *Broker
+	mu     sync.Mutex
+	topics map[string]struct{}
+	events chan Event
+	closed bool
+	lagged bool
+}
+
+// C returns the channel of events.
+func (s *Subscription) C() <-chan Event {
+	return s.events
+}
+
+// Add subscribes to more topics.
+func (s *Subscription) Add(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+	}
+}
+
+// Remove unsubscribes from topics.
+func (s *Subscription) Remove(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		delete(s.topics, t)
+	}
+}
+
+// Topics returns the subscribed topics.
+func (s *Subscription) Topics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topics := make([]string, 0, len(s.topics))
+	for t := range s.topics {
+		topics = append(topics, t)
+	}
+	return topics
+}
+
+// Lagged reports whether the subscription was dropped because its buffer was
+// full.
+func (s *Subscription) Lagged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lagged
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.broker.remove(s)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+func (s *Subscription) matches(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t := range s.topics {
+		if matchTopic(t, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscription) send(e Event) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+
+	select {
+	case s.events <- e:
+		s.mu.Unlock()
+	default:
+		s.lagged = true
+		s.closed = true
+		close(s.events)
+		s.mu.Unlock()
+		s.broker.remove(s)
+	}
+}
+
+func matchAny(patterns []string, topic string) bool {
+	for _, p := range patterns {
+		if matchTopic(p, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTopic(pattern, topic string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(topic, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == topic
+}
@@ -0,0 +1,33 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Cell is a point snapped to a grid of Precision decimal places. Cells are used
+// as cache keys for data fetched for arbitrary coordinates.
+type Cell struct {
+	Precision int
+	LatKey    int
+	LonKey    int
+}
+
+func NewCell(lat, lon float64, precision int) Cell {
+	scale := math.Pow10(precision)
+	return Cell{
+		Precision: precision,
+		LatKey:    int(math.Round(lat * scale)),
+		LonKey:    int(math.Round(lon * scale)),
+	}
+}
+
+// Center returns the coordinates of the grid point.
+func (c Cell) Center() (lat, lon float64) {
+	scale := math.Pow10(c.Precision)
+	return float64(c.LatKey) / scale, float64(c.LonKey) / scale
+}
+
+func (c Cell) String() string {
+	return fmt.Sprintf("%d:%d:%d", c.Precision, c.LatKey, c.LonKey)
+}
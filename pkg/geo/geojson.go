@@ -0,0 +1,37 @@
+package geo
+
+// GeoJSON types (RFC 7946) used by the map feeds.
+
+type FeatureCollection struct {
+	Type     string    `json:"type" example:"FeatureCollection"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string      `json:"type" example:"Feature"`
+	ID         string      `json:"id,omitempty"`
+	Geometry   Point       `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+// Point is a GeoJSON point. Coordinates are longitude first.
+type Point struct {
+	Type        string     `json:"type" example:"Point"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = make([]Feature, 0)
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+func NewPointFeature(id string, lat, lon float64, properties interface{}) Feature {
+	return Feature{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   Point{Type: "Point", Coordinates: [2]float64{lon, lat}},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,154 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/language"
+	"net/http"
+	"strconv"
+)
+
+const (
+	English = "en"
+	Russian = "ru"
+
+	// Default is used when the request does not ask for a supported language.
+	Default = English
+)
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalog holds the translations of one language.
+type catalog struct {
+	// Conditions are OpenWeather condition descriptions keyed by condition id.
+	Conditions map[string]string `json:"conditions"`
+	// Errors are generic error messages keyed by WeatherService-XXXXXX code.
+	Errors map[string]string `json:"errors"`
+	// Messages translate specific English error messages.
+	Messages map[string]string `json:"messages"`
+	// Texts are other user facing strings keyed by name.
+	Texts map[string]string `json:"texts"`
+}
+
+var (
+	supported = []language.Tag{language.English, language.Russian}
+	catalogs  = make(map[string]catalog)
+	matcher   = language.NewMatcher(supported)
+)
+
+func init() {
+	for _, tag := range supported {
+		lang := tag.String()
+		data, err := catalogFiles.ReadFile(fmt.Sprintf("catalogs/%s.json", lang))
+		if err != nil {
+			panic(err)
+		}
+
+		var c catalog
+		if err = json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Errorf("failed to parse %s catalog. error: %w", lang, err))
+		}
+		catalogs[lang] = c
+	}
+}
+
+type ctxKey struct{}
+
+// ContextWithLang stores the response language in ctx.
+func ContextWithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// FromContext returns the response language stored by Middleware, or Default.
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(ctxKey{}).(string); ok {
+		return lang
+	}
+	return Default
+}
+
+// FromRequest picks the response language from ?lang= or, failing that, from
+// the Accept-Language header.
+func FromRequest(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return Match(lang)
+	}
+	return Match(r.Header.Get("Accept-Language"))
+}
+
+// Match returns the supported language closest to an Accept-Language value
+// such as "ru-RU,ru;q=0.9,en;q=0.8", or Default.
+func Match(accept string) string {
+	if accept == "" {
+		return Default
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(accept)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return supported[index].String()
+}
+
+// Middleware stores the negotiated language in the request context and
+// reports it in Content-Language.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := FromRequest(r)
+		w.Header().Set("Content-Language", lang)
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(ContextWithLang(r.Context(), lang)))
+	})
+}
+
+// Condition translates the description of an OpenWeather condition id.
+// fallback is returned for unknown ids.
+func Condition(lang string, id int, fallback string) string {
+	if description, ok := catalogs[lang].Conditions[strconv.Itoa(id)]; ok {
+		return description
+	}
+	return fallback
+}
+
+// Error translates an error message. Known messages are translated as a
+// whole, otherwise the generic message of the code is prefixed to the original
+// one so details like city names are kept.
+func Error(lang, code, message string) string {
+	if lang == English {
+		return message
+	}
+
+	c := catalogs[lang]
+	if translated, ok := c.Messages[message]; ok {
+		return translated
+	}
+
+	generic, ok := c.Errors[code]
+	if !ok {
+		return message
+	}
+	if message == "" {
+		return generic
+	}
+	return fmt.Sprintf("%s: %s", generic, message)
+}
+
+// Text returns the text named key in lang, falling back to English and then
+// to the key itself.
+func Text(lang, key string) string {
+	if text, ok := catalogs[lang].Texts[key]; ok {
+		return text
+	}
+	if text, ok := catalogs[Default].Texts[key]; ok {
+		return text
+	}
+	return key
+}
@@ -0,0 +1,96 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Calendar is an RFC 5545 calendar of all-day events.
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event is an all-day event. Clients match events by UID, so the UID of an
+// event must not change when it is regenerated.
+type Event struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	Modified    time.Time
+}
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+
+	// maxLineOctets is the longest content line allowed before folding.
+	maxLineOctets = 75
+)
+
+// Encode writes the calendar with CRLF line endings and folded lines.
+func (c Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format(dateTimeFormat)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+escape(c.ProdID))
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "METHOD:PUBLISH")
+	if c.Name != "" {
+		writeLine(bw, "X-WR-CALNAME:"+escape(c.Name))
+	}
+
+	for _, e := range c.Events {
+		modified := stamp
+		if !e.Modified.IsZero() {
+			modified = e.Modified.UTC().Format(dateTimeFormat)
+		}
+
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+escape(e.UID))
+		writeLine(bw, "DTSTAMP:"+stamp)
+		writeLine(bw, "LAST-MODIFIED:"+modified)
+		writeLine(bw, "DTSTART;VALUE=DATE:"+e.Date.Format(dateFormat))
+		writeLine(bw, "DTEND;VALUE=DATE:"+e.Date.AddDate(0, 0, 1).Format(dateFormat))
+		writeLine(bw, "SUMMARY:"+escape(e.Summary))
+		if e.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escape(e.Description))
+		}
+		writeLine(bw, "TRANSP:TRANSPARENT")
+		writeLine(bw, "END:VEVENT")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// escape escapes TEXT values as described in RFC 5545 section 3.3.11.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeLine folds the line into chunks of at most maxLineOctets octets
+// without splitting UTF-8 sequences; continuation lines start with a space.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space counts towards the length of continuation lines
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
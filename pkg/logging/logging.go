@@ -1,14 +1,32 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
+	"strings"
 )
 
+type Config struct {
+	Level   string     `yaml:"level" env-default:"info"`
+	Format  string     `yaml:"format" env-default:"text"`
+	Outputs []string   `yaml:"outputs" env-default:"stdout"`
+	File    FileConfig `yaml:"file"`
+}
+
+type FileConfig struct {
+	Path       string `yaml:"path" env-default:"logs/all.log"`
+	MaxSizeMB  int    `yaml:"max_size_mb" env-default:"100"`
+	MaxAgeDays int    `yaml:"max_age_days" env-default:"7"`
+	MaxBackups int    `yaml:"max_backups" env-default:"5"`
+}
+
 type writerHook struct {
 	Writer    []io.Writer
 	LogLevels []logrus.Level
@@ -33,6 +51,7 @@ func (hook *writerHook) Levels() []logrus.Level {
 	return hook.LogLevels
 }
 
+var l *logrus.Logger
 var e *logrus.Entry
 
 type Logger struct {
@@ -48,36 +67,105 @@ func (l *Logger) GetLoggerWithField(k string, v interface{}) *Logger {
 
 }
 
-func init() {
-	l := logrus.New()
-	l.SetReportCaller(true)
-	l.Formatter = &logrus.TextFormatter{
-		CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
-			filename := path.Base(frame.File)
-			return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
-		},
-		DisableColors: false,
-		FullTimestamp: true,
+type ctxKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying logger, so code further down
+// the call chain logs with the same fields (request id etc.).
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the application logger if
+// there is none.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*Logger); ok {
+		return logger
 	}
+	return GetLogger()
+}
 
-	err := os.MkdirAll("logs", 0644)
+// Init reconfigures the application logger. Loggers obtained earlier through
+// GetLogger share the same underlying logrus instance and pick the changes up.
+func Init(cfg Config) error {
+	level, err := logrus.ParseLevel(cfg.Level)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("invalid log level %q. error: %w", cfg.Level, err)
 	}
 
-	allFile, err := os.OpenFile("logs/all.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	formatter, err := newFormatter(cfg.Format)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	writers := make([]io.Writer, 0, len(cfg.Outputs))
+	for _, output := range cfg.Outputs {
+		switch strings.ToLower(strings.TrimSpace(output)) {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		case "file":
+			if err = os.MkdirAll(filepath.Dir(cfg.File.Path), 0755); err != nil {
+				return fmt.Errorf("failed to create log directory. error: %w", err)
+			}
+			writers = append(writers, &lumberjack.Logger{
+				Filename:   cfg.File.Path,
+				MaxSize:    cfg.File.MaxSizeMB,
+				MaxAge:     cfg.File.MaxAgeDays,
+				MaxBackups: cfg.File.MaxBackups,
+			})
+		default:
+			return fmt.Errorf("unknown log output %q. expected: stdout, stderr or file", output)
+		}
 	}
 
+	hooks := make(logrus.LevelHooks)
+	hooks.Add(&writerHook{
+		Writer:    writers,
+		LogLevels: logrus.AllLevels,
+	})
+
+	l.SetFormatter(&redactingFormatter{formatter})
+	l.ReplaceHooks(hooks)
+	l.SetLevel(level)
+
+	return nil
+}
+
+func newFormatter(format string) (logrus.Formatter, error) {
+	callerPrettyfier := func(frame *runtime.Frame) (function string, file string) {
+		filename := path.Base(frame.File)
+		return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return &logrus.JSONFormatter{CallerPrettyfier: callerPrettyfier}, nil
+	case "text", "":
+		return &logrus.TextFormatter{
+			CallerPrettyfier: callerPrettyfier,
+			DisableColors:    false,
+			FullTimestamp:    true,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q. expected: json or text", format)
+	}
+}
+
+func init() {
+	l = logrus.New()
+	l.SetReportCaller(true)
+	formatter, _ := newFormatter("text")
+	l.SetFormatter(&redactingFormatter{formatter})
+
 	l.SetOutput(io.Discard)
 
 	l.AddHook(&writerHook{
-		Writer:    []io.Writer{allFile, os.Stdout},
+		Writer:    []io.Writer{os.Stdout},
 		LogLevels: logrus.AllLevels,
 	})
 
-	l.SetLevel(logrus.TraceLevel)
+	l.SetLevel(logrus.InfoLevel)
 
 	e = logrus.NewEntry(l)
 }
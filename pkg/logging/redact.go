@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "api_key", "apikey"}
+
+// sensitivePairs matches key=value and "key":"value" pairs with a sensitive key,
+// as found in query strings, DTO dumps and JSON bodies.
+var sensitivePairs = regexp.MustCompile(`(?i)("?[a-z_]*(?:password|token|secret|api_?key|appid)"?\s*[=:]\s*"?)([^&\s",}]+)`)
+
+// sensitivePaths matches secrets carried in URL paths, such as calendar feed
+// tokens.
+var sensitivePaths = regexp.MustCompile(`(/calendar/)[^/?\s"]+`)
+
+// apiKeys matches API keys anywhere, keeping their public prefix.
+var apiKeys = regexp.MustCompile(`(wsk_[0-9a-f]{12}_)[A-Za-z0-9_-]+`)
+
+// redactingFormatter hides passwords, tokens and similar values in both the
+// entry fields and the message before handing the entry to the real formatter.
+type redactingFormatter struct {
+	logrus.Formatter
+}
+
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	clone := *entry
+	clone.Message = Redact(entry.Message)
+
+	clone.Data = make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		if isSensitiveKey(k) {
+			clone.Data[k] = redacted
+			continue
+		}
+		if s, ok := v.(string); ok {
+			v = Redact(s)
+		}
+		clone.Data[k] = v
+	}
+
+	return f.Formatter.Format(&clone)
+}
+
+// Redact replaces values of sensitive key/value pairs in s.
+func Redact(s string) string {
+	s = sensitivePaths.ReplaceAllString(s, "${1}"+redacted)
+	s = apiKeys.ReplaceAllString(s, "${1}"+redacted)
+	return sensitivePairs.ReplaceAllString(s, "${1}"+redacted)
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
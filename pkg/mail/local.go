@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Senders for local testing. They print secrets like password reset tokens,
+// so they must not be used in production.
+
+type writerSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogSender creates a sender printing the plain-text part of messages to
+// w. The output bypasses the logger, whose redaction would hide the links.
+func NewLogSender(w io.Writer) Sender {
+	return &writerSender{w: w}
+}
+
+func (s *writerSender) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "----- email %s -----\nTo: %s\nSubject: %s\n\n%s\n----- end of email -----\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Text)
+	return err
+}
+
+type fileSender struct {
+	dir  string
+	from string
+}
+
+// NewFileSender creates a sender writing messages as .eml files to dir, which
+// is created when missing.
+func NewFileSender(dir, from string) (Sender, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory %q. error: %w", dir, err)
+	}
+	return &fileSender{dir: dir, from: from}, nil
+}
+
+func (s *fileSender) Send(ctx context.Context, msg Message) error {
+	body, err := Build(s.from, msg)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 4)
+	rand.Read(b)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), hex.EncodeToString(b))
+	if err = os.WriteFile(filepath.Join(s.dir, name), body, 0o640); err != nil {
+		return fmt.Errorf("failed to write message. error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is an email with plain-text and HTML alternatives.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are added to the message, e.g. List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Sender sends messages.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig configures an SMTP server. Without Username the server is used
+// without authentication, as local stand-ins like MailHog expect.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+type smtpSender struct {
+	cfg  SMTPConfig
+	from *netmail.Address
+}
+
+// NewSMTPSender creates a sender delivering through the SMTP server. STARTTLS
+// is used when the server offers it.
+func NewSMTPSender(cfg SMTPConfig) (Sender, error) {
+	from, err := netmail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q. error: %w", cfg.From, err)
+	}
+	return &smtpSender{cfg: cfg, from: from}, nil
+}
+
+func (s *smtpSender) Send(ctx context.Context, msg Message) error {
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address %q. error: %w", msg.To, err)
+	}
+
+	body, err := Build(s.from.String(), msg)
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, s.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server. error: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session. error: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start tls. error: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate on smtp server. error: %w", err)
+		}
+	}
+
+	if err = client.Mail(s.from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL command failed. error: %w", err)
+	}
+	if err = client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("smtp RCPT command failed. error: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA command failed. error: %w", err)
+	}
+	if _, err = w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message. error: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("failed to send message. error: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// Build renders msg as a MIME multipart/alternative message.
+func Build(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	headers := map[string]string{
+		"From":         from,
+		"To":           msg.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-ID":   messageID(from),
+		"MIME-Version": "1.0",
+	}
+	for k, v := range msg.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	headers["Content-Type"] = fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary())
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\r\n", k, headers[k])
+	}
+	w.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		qw.Write([]byte(strings.ReplaceAll(part.content, "\n", "\r\n")))
+		qw.Close()
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	w.Write(body.Bytes())
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := netmail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(addr.Address, "@"); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
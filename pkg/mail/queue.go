@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"WeatherServiceAPI/pkg/logging"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRateLimited is returned for messages over the limit of the recipient.
+	ErrRateLimited = errors.New("recipient rate limit exceeded")
+	// ErrQueueFull is returned when the queue cannot take more messages.
+	ErrQueueFull = errors.New("mail queue is full")
+)
+
+// Queue sends messages in the background, so callers do not wait for the SMTP
+// server, and limits the messages per recipient to limit in window.
+type Queue struct {
+	sender   Sender
+	logger   *logging.Logger
+	messages chan Message
+
+	limit  int
+	window time.Duration
+	mu     sync.Mutex
+	sent   map[string][]time.Time
+}
+
+var _ Sender = &Queue{}
+
+// NewQueue creates a queue of size messages sending with sender. A limit of
+// zero disables rate limiting.
+func NewQueue(sender Sender, logger *logging.Logger, size, limit int, window time.Duration) *Queue {
+	return &Queue{
+		sender:   sender,
+		logger:   logger,
+		messages: make(chan Message, size),
+		limit:    limit,
+		window:   window,
+		sent:     make(map[string][]time.Time),
+	}
+}
+
+// Send queues the message. It fails with ErrRateLimited or ErrQueueFull
+// instead of blocking.
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	if !q.allow(msg.To, time.Now()) {
+		return ErrRateLimited
+	}
+
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends queued messages until ctx is done.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-q.messages:
+			if err := q.sender.Send(ctx, msg); err != nil {
+				q.logger.Errorf("failed to send email %q to %s. error: %v", msg.Subject, msg.To, err)
+			}
+		}
+	}
+}
+
+// allow records a message to the recipient unless it is over the limit.
+func (q *Queue) allow(recipient string, now time.Time) bool {
+	if q.limit <= 0 {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	recipient = strings.ToLower(recipient)
+	since := now.Add(-q.window)
+	for key, times := range q.sent {
+		if len(times) > 0 && times[len(times)-1].Before(since) {
+			delete(q.sent, key)
+		}
+	}
+
+	times := q.sent[recipient]
+	i := 0
+	for i < len(times) && times[i].Before(since) {
+		i++
+	}
+	times = times[i:]
+	if len(times) >= q.limit {
+		q.sent[recipient] = times
+		return false
+	}
+	q.sent[recipient] = append(times, now)
+	return true
+}
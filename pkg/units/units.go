@@ -0,0 +1,164 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Weather data is stored in metric units: °C, m/s, hPa, metres and millimetres.
+// Units converts these values for responses.
+
+type System string
+
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial"
+	Standard System = "standard"
+)
+
+type PressureUnit string
+
+const (
+	HPa  PressureUnit = "hPa"
+	InHg PressureUnit = "inHg"
+	MmHg PressureUnit = "mmHg"
+)
+
+// Labels name the unit of every converted quantity in a response.
+type Labels struct {
+	System        System       `json:"system"`
+	Temperature   string       `json:"temperature"`
+	WindSpeed     string       `json:"wind_speed"`
+	Pressure      PressureUnit `json:"pressure"`
+	Visibility    string       `json:"visibility"`
+	Precipitation string       `json:"precipitation"`
+}
+
+type Units struct {
+	System       System
+	PressureUnit PressureUnit
+}
+
+// Parse reads a unit system and an optional pressure unit overriding the
+// system default. Empty system means metric.
+func Parse(system, pressure string) (Units, error) {
+	u := Units{System: Metric}
+
+	switch System(strings.ToLower(system)) {
+	case "", Metric:
+	case Imperial:
+		u.System = Imperial
+	case Standard:
+		u.System = Standard
+	default:
+		return u, fmt.Errorf("unknown unit system %q. expected: metric, imperial or standard", system)
+	}
+
+	u.PressureUnit = u.System.defaultPressure()
+	if pressure != "" {
+		switch strings.ToLower(pressure) {
+		case "hpa":
+			u.PressureUnit = HPa
+		case "inhg":
+			u.PressureUnit = InHg
+		case "mmhg":
+			u.PressureUnit = MmHg
+		default:
+			return u, fmt.Errorf("unknown pressure unit %q. expected: hPa, inHg or mmHg", pressure)
+		}
+	}
+
+	return u, nil
+}
+
+// Valid reports whether s is a known unit system.
+func Valid(s string) bool {
+	switch System(s) {
+	case Metric, Imperial, Standard:
+		return true
+	}
+	return false
+}
+
+func (s System) defaultPressure() PressureUnit {
+	if s == Imperial {
+		return InHg
+	}
+	return HPa
+}
+
+func (u Units) Labels() Labels {
+	labels := Labels{System: u.System, Pressure: u.PressureUnit}
+
+	switch u.System {
+	case Imperial:
+		labels.Temperature = "°F"
+		labels.WindSpeed = "mph"
+		labels.Visibility = "mi"
+		labels.Precipitation = "in"
+	case Standard:
+		labels.Temperature = "K"
+		labels.WindSpeed = "m/s"
+		labels.Visibility = "m"
+		labels.Precipitation = "mm"
+	default:
+		labels.Temperature = "°C"
+		labels.WindSpeed = "m/s"
+		labels.Visibility = "m"
+		labels.Precipitation = "mm"
+	}
+
+	return labels
+}
+
+// Temperature converts degrees Celsius.
+func (u Units) Temperature(celsius float64) float64 {
+	switch u.System {
+	case Imperial:
+		return round(celsius*9/5+32, 2)
+	case Standard:
+		return round(celsius+273.15, 2)
+	}
+	return celsius
+}
+
+// WindSpeed converts metres per second.
+func (u Units) WindSpeed(ms float64) float64 {
+	if u.System == Imperial {
+		return round(ms*2.236936, 2)
+	}
+	return ms
+}
+
+// Pressure converts hectopascals.
+func (u Units) Pressure(hPa float64) float64 {
+	switch u.PressureUnit {
+	case InHg:
+		return round(hPa*0.02953, 2)
+	case MmHg:
+		return round(hPa*0.750062, 1)
+	}
+	return hPa
+}
+
+// Visibility converts metres.
+func (u Units) Visibility(metres float64) float64 {
+	if u.System == Imperial {
+		return round(metres/1609.344, 2)
+	}
+	return metres
+}
+
+// Precipitation converts millimetres.
+func (u Units) Precipitation(mm float64) float64 {
+	if u.System == Imperial {
+		return round(mm/25.4, 3)
+	}
+	return mm
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(v*scale) / scale
+}
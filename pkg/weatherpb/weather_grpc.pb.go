@@ -0,0 +1,412 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: weather.proto
+
+package weatherpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WeatherService_ListCities_FullMethodName    = "/weather.v1.WeatherService/ListCities"
+	WeatherService_GetBriefInfo_FullMethodName  = "/weather.v1.WeatherService/GetBriefInfo"
+	WeatherService_GetForecast_FullMethodName   = "/weather.v1.WeatherService/GetForecast"
+	WeatherService_WatchForecast_FullMethodName = "/weather.v1.WeatherService/WatchForecast"
+)
+
+// WeatherServiceClient is the client API for WeatherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WeatherServiceClient interface {
+	ListCities(ctx context.Context, in *ListCitiesRequest, opts ...grpc.CallOption) (*ListCitiesResponse, error)
+	GetBriefInfo(ctx context.Context, in *GetBriefInfoRequest, opts ...grpc.CallOption) (*BriefInfo, error)
+	GetForecast(ctx context.Context, in *GetForecastRequest, opts ...grpc.CallOption) (*GetForecastResponse, error)
+	WatchForecast(ctx context.Context, in *WatchForecastRequest, opts ...grpc.CallOption) (WeatherService_WatchForecastClient, error)
+}
+
+type weatherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWeatherServiceClient(cc grpc.ClientConnInterface) WeatherServiceClient {
+	return &weatherServiceClient{cc}
+}
+
+func (c *weatherServiceClient) ListCities(ctx context.Context, in *ListCitiesRequest, opts ...grpc.CallOption) (*ListCitiesResponse, error) {
+	out := new(ListCitiesResponse)
+	err := c.cc.Invoke(ctx, WeatherService_ListCities_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) GetBriefInfo(ctx context.Context, in *GetBriefInfoRequest, opts ...grpc.CallOption) (*BriefInfo, error) {
+	out := new(BriefInfo)
+	err := c.cc.Invoke(ctx, WeatherService_GetBriefInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) GetForecast(ctx context.Context, in *GetForecastRequest, opts ...grpc.CallOption) (*GetForecastResponse, error) {
+	out := new(GetForecastResponse)
+	err := c.cc.Invoke(ctx, WeatherService_GetForecast_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) WatchForecast(ctx context.Context, in *WatchForecastRequest, opts ...grpc.CallOption) (WeatherService_WatchForecastClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WeatherService_ServiceDesc.Streams[0], WeatherService_WatchForecast_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &weatherServiceWatchForecastClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WeatherService_WatchForecastClient interface {
+	Recv() (*ForecastUpdate, error)
+	grpc.ClientStream
+}
+
+type weatherServiceWatchForecastClient struct {
+	grpc.ClientStream
+}
+
+func (x *weatherServiceWatchForecastClient) Recv() (*ForecastUpdate, error) {
+	m := new(ForecastUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WeatherServiceServer is the server API for WeatherService service.
+// All implementations must embed UnimplementedWeatherServiceServer
+// for forward compatibility
+type WeatherServiceServer interface {
+	ListCities(context.Context, *ListCitiesRequest) (*ListCitiesResponse, error)
+	GetBriefInfo(context.Context, *GetBriefInfoRequest) (*BriefInfo, error)
+	GetForecast(context.Context, *GetForecastRequest) (*GetForecastResponse, error)
+	WatchForecast(*WatchForecastRequest, WeatherService_WatchForecastServer) error
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+// UnimplementedWeatherServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWeatherServiceServer struct {
+}
+
+func (UnimplementedWeatherServiceServer) ListCities(context.Context, *ListCitiesRequest) (*ListCitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCities not implemented")
+}
+func (UnimplementedWeatherServiceServer) GetBriefInfo(context.Context, *GetBriefInfoRequest) (*BriefInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBriefInfo not implemented")
+}
+func (UnimplementedWeatherServiceServer) GetForecast(context.Context, *GetForecastRequest) (*GetForecastResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetForecast not implemented")
+}
+func (UnimplementedWeatherServiceServer) WatchForecast(*WatchForecastRequest, WeatherService_WatchForecastServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchForecast not implemented")
+}
+func (UnimplementedWeatherServiceServer) mustEmbedUnimplementedWeatherServiceServer() {}
+
+// UnsafeWeatherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WeatherServiceServer will
+// result in compilation errors.
+type UnsafeWeatherServiceServer interface {
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+func RegisterWeatherServiceServer(s grpc.ServiceRegistrar, srv WeatherServiceServer) {
+	s.RegisterService(&WeatherService_ServiceDesc, srv)
+}
+
+func _WeatherService_ListCities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).ListCities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_ListCities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).ListCities(ctx, req.(*ListCitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_GetBriefInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBriefInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetBriefInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetBriefInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetBriefInfo(ctx, req.(*GetBriefInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_GetForecast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetForecastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetForecast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetForecast_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetForecast(ctx, req.(*GetForecastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_WatchForecast_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchForecastRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WeatherServiceServer).WatchForecast(m, &weatherServiceWatchForecastServer{stream})
+}
+
+type WeatherService_WatchForecastServer interface {
+	Send(*ForecastUpdate) error
+	grpc.ServerStream
+}
+
+type weatherServiceWatchForecastServer struct {
+	grpc.ServerStream
+}
+
+func (x *weatherServiceWatchForecastServer) Send(m *ForecastUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WeatherService_ServiceDesc is the grpc.ServiceDesc for WeatherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WeatherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.WeatherService",
+	HandlerType: (*WeatherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCities",
+			Handler:    _WeatherService_ListCities_Handler,
+		},
+		{
+			MethodName: "GetBriefInfo",
+			Handler:    _WeatherService_GetBriefInfo_Handler,
+		},
+		{
+			MethodName: "GetForecast",
+			Handler:    _WeatherService_GetForecast_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchForecast",
+			Handler:       _WeatherService_WatchForecast_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "weather.proto",
+}
+
+const (
+	UserService_ListFavourites_FullMethodName  = "/weather.v1.UserService/ListFavourites"
+	UserService_AddFavourite_FullMethodName    = "/weather.v1.UserService/AddFavourite"
+	UserService_RemoveFavourite_FullMethodName = "/weather.v1.UserService/RemoveFavourite"
+)
+
+// UserServiceClient is the client API for UserService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UserServiceClient interface {
+	ListFavourites(ctx context.Context, in *ListFavouritesRequest, opts ...grpc.CallOption) (*ListCitiesResponse, error)
+	AddFavourite(ctx context.Context, in *FavouriteRequest, opts ...grpc.CallOption) (*FavouriteResponse, error)
+	RemoveFavourite(ctx context.Context, in *FavouriteRequest, opts ...grpc.CallOption) (*FavouriteResponse, error)
+}
+
+type userServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserServiceClient(cc grpc.ClientConnInterface) UserServiceClient {
+	return &userServiceClient{cc}
+}
+
+func (c *userServiceClient) ListFavourites(ctx context.Context, in *ListFavouritesRequest, opts ...grpc.CallOption) (*ListCitiesResponse, error) {
+	out := new(ListCitiesResponse)
+	err := c.cc.Invoke(ctx, UserService_ListFavourites_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) AddFavourite(ctx context.Context, in *FavouriteRequest, opts ...grpc.CallOption) (*FavouriteResponse, error) {
+	out := new(FavouriteResponse)
+	err := c.cc.Invoke(ctx, UserService_AddFavourite_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) RemoveFavourite(ctx context.Context, in *FavouriteRequest, opts ...grpc.CallOption) (*FavouriteResponse, error) {
+	out := new(FavouriteResponse)
+	err := c.cc.Invoke(ctx, UserService_RemoveFavourite_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserServiceServer is the server API for UserService service.
+// All implementations must embed UnimplementedUserServiceServer
+// for forward compatibility
+type UserServiceServer interface {
+	ListFavourites(context.Context, *ListFavouritesRequest) (*ListCitiesResponse, error)
+	AddFavourite(context.Context, *FavouriteRequest) (*FavouriteResponse, error)
+	RemoveFavourite(context.Context, *FavouriteRequest) (*FavouriteResponse, error)
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+// UnimplementedUserServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedUserServiceServer struct {
+}
+
+func (UnimplementedUserServiceServer) ListFavourites(context.Context, *ListFavouritesRequest) (*ListCitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFavourites not implemented")
+}
+func (UnimplementedUserServiceServer) AddFavourite(context.Context, *FavouriteRequest) (*FavouriteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddFavourite not implemented")
+}
+func (UnimplementedUserServiceServer) RemoveFavourite(context.Context, *FavouriteRequest) (*FavouriteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFavourite not implemented")
+}
+func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
+
+// UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserServiceServer will
+// result in compilation errors.
+type UnsafeUserServiceServer interface {
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+func RegisterUserServiceServer(s grpc.ServiceRegistrar, srv UserServiceServer) {
+	s.RegisterService(&UserService_ServiceDesc, srv)
+}
+
+func _UserService_ListFavourites_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFavouritesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).ListFavourites(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_ListFavourites_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).ListFavourites(ctx, req.(*ListFavouritesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_AddFavourite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FavouriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).AddFavourite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_AddFavourite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).AddFavourite(ctx, req.(*FavouriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_RemoveFavourite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FavouriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).RemoveFavourite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_RemoveFavourite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).RemoveFavourite(ctx, req.(*FavouriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UserService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.UserService",
+	HandlerType: (*UserServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListFavourites",
+			Handler:    _UserService_ListFavourites_Handler,
+		},
+		{
+			MethodName: "AddFavourite",
+			Handler:    _UserService_AddFavourite_Handler,
+		},
+		{
+			MethodName: "RemoveFavourite",
+			Handler:    _UserService_RemoveFavourite_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "weather.proto",
+}